@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/account"
+	"github.com/soapboxsocial/soapbox/pkg/images"
+	// aliased, as the twitter command declares notifications.
+	notifs "github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+	"github.com/soapboxsocial/soapbox/pkg/sql"
+	"github.com/soapboxsocial/soapbox/pkg/stories"
+)
+
+var purgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "permanently deletes accounts whose deletion grace period has ended",
+	RunE:  runPurge,
+}
+
+func runPurge(*cobra.Command, []string) error {
+	db, err := sql.Open(config.DB)
+	if err != nil {
+		return errors.Wrap(err, "failed to open db")
+	}
+
+	rdb := redis.NewRedis(config.Redis)
+
+	purger := account.NewPurger(
+		account.NewBackend(db),
+		images.NewImagesBackend(config.Data.Images),
+		stories.NewFileBackend(config.Data.Stories),
+		notifs.NewLimiter(rdb, nil),
+		notifs.NewDigests(rdb),
+		pubsub.NewQueue(rdb),
+	)
+
+	purged, err := purger.Purge(time.Now())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("purged %d accounts", purged)
+
+	return nil
+}
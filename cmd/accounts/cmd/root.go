@@ -20,7 +20,12 @@ var (
 )
 
 type Conf struct {
-	DB      conf.PostgresConf `mapstructure:"db"`
+	DB    conf.PostgresConf `mapstructure:"db"`
+	Redis conf.RedisConf    `mapstructure:"redis"`
+	Data  struct {
+		Images  string `mapstructure:"images"`
+		Stories string `mapstructure:"stories"`
+	} `mapstructure:"data"`
 	Twitter struct {
 		Key    string `mapstructure:"key"`
 		Secret string `mapstructure:"secret"`
@@ -32,6 +37,8 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&file, "config", "c", "config.toml", "config file")
 
 	rootCmd.AddCommand(twitterCmd)
+	rootCmd.AddCommand(purgeCmd)
+	rootCmd.AddCommand(sessionsCmd)
 }
 
 // Execute executes the root command.
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+	"github.com/soapboxsocial/soapbox/pkg/sessions"
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "index-sessions",
+	Short: "records sessions created before refresh tokens, so they are closed with the other sessions of a user",
+	RunE:  runIndexSessions,
+}
+
+func runIndexSessions(*cobra.Command, []string) error {
+	sm := sessions.NewSessionManager(redis.NewRedis(config.Redis))
+
+	indexed, err := sm.IndexLegacySessions()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("indexed %d sessions", indexed)
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/lib/pq"
+	"github.com/sendgrid/sendgrid-go"
+
+	"github.com/soapboxsocial/soapbox/pkg/conf"
+	"github.com/soapboxsocial/soapbox/pkg/exports"
+	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+	"github.com/soapboxsocial/soapbox/pkg/sql"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+type Conf struct {
+	Sendgrid struct {
+		Key string `mapstructure:"key"`
+	} `mapstructure:"sendgrid"`
+	Data struct {
+		Images  string `mapstructure:"images"`
+		Stories string `mapstructure:"stories"`
+		Exports string `mapstructure:"exports"`
+	} `mapstructure:"data"`
+
+	// URL is the public URL exports are downloaded from.
+	URL   string            `mapstructure:"url"`
+	Redis conf.RedisConf    `mapstructure:"redis"`
+	DB    conf.PostgresConf `mapstructure:"db"`
+}
+
+func parse() (*Conf, error) {
+	var file string
+	flag.StringVar(&file, "c", "config.toml", "config file")
+	flag.Parse()
+
+	config := &Conf{}
+	err := conf.Load(file, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func main() {
+	fmt.Printf("Exports starting...\n\n")
+	config, err := parse()
+	if err != nil {
+		log.Fatal("failed to parse config")
+	}
+
+	db, err := sql.Open(config.DB)
+	if err != nil {
+		log.Fatalf("failed to open db: %s", err)
+	}
+
+	rdb := redis.NewRedis(config.Redis)
+
+	worker := exports.NewWorker(
+		exports.NewQueue(rdb),
+		exports.NewExporter(exports.NewBackend(db), config.Data.Images, config.Data.Stories),
+		exports.NewLinks(rdb),
+		users.NewBackend(db),
+		mail.NewMailService(sendgrid.NewSendClient(config.Sendgrid.Key)),
+		config.Data.Exports,
+		config.URL,
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	err = worker.Run(ctx)
+	if err != nil {
+		log.Fatalf("worker.Run err: %v", err)
+	}
+}
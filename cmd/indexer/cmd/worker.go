@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	sqldb "database/sql"
 	"encoding/json"
 	"errors"
 	"log"
@@ -90,6 +91,15 @@ func userUpdateRequest(event *pubsub.Event) (esapi.Request, error) {
 	}
 
 	user, err := userBackend.GetUserForSearchEngine(int(id))
+	if err == sqldb.ErrNoRows {
+		// users scheduled for deletion are hidden from search until they are restored.
+		return esapi.DeleteRequest{
+			Index:      "users",
+			DocumentID: strconv.Itoa(int(id)),
+			Refresh:    "true",
+		}, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
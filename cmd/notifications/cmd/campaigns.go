@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/campaigns"
+)
+
+var campaignsCmd = &cobra.Command{
+	Use:   "campaigns",
+	Short: "manages notification campaigns",
+}
+
+var createCampaign = &cobra.Command{
+	Use:   "create [name]",
+	Short: "schedules a notification to a segment",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCreateCampaign,
+}
+
+var listCampaigns = &cobra.Command{
+	Use:   "list",
+	Short: "lists all campaigns",
+	RunE:  runListCampaigns,
+}
+
+var cancelCampaign = &cobra.Command{
+	Use:   "cancel [id]",
+	Short: "stops a scheduled or running campaign",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCancelCampaign,
+}
+
+var reportCampaign = &cobra.Command{
+	Use:   "report [id]",
+	Short: "reports how many notifications of a campaign were sent and opened",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runReportCampaign,
+}
+
+var (
+	campaignSegment  string
+	campaignBody     string
+	campaignCategory string
+	campaignAt       string
+	campaignRate     int
+	dryRun           bool
+)
+
+func init() {
+	createCampaign.Flags().StringVarP(&campaignSegment, "segment", "s", "", "name of the segment to send to")
+	createCampaign.Flags().StringVarP(&campaignBody, "body", "", "", "notification body")
+	createCampaign.Flags().StringVarP(&campaignCategory, "category", "", string(notifications.INFO), "notification category")
+	createCampaign.Flags().StringVarP(&campaignAt, "at", "", "", "time to start sending in RFC 3339, defaults to now")
+	createCampaign.Flags().IntVarP(&campaignRate, "rate", "r", 1000, "maximum notifications sent per minute")
+	createCampaign.Flags().BoolVarP(&dryRun, "dry-run", "", false, "only report the audience size")
+
+	campaignsCmd.AddCommand(createCampaign)
+	campaignsCmd.AddCommand(listCampaigns)
+	campaignsCmd.AddCommand(cancelCampaign)
+	campaignsCmd.AddCommand(reportCampaign)
+}
+
+func runCreateCampaign(_ *cobra.Command, args []string) error {
+	if campaignBody == "" {
+		return errors.New("body cannot be empty")
+	}
+
+	category := notifications.NotificationCategory(campaignCategory)
+	if !notifications.IsConfigurableCategory(category) {
+		return fmt.Errorf("invalid category \"%s\"", campaignCategory)
+	}
+
+	if campaignRate <= 0 {
+		return errors.New("rate must be positive")
+	}
+
+	scheduled := time.Now()
+	if campaignAt != "" {
+		var err error
+		scheduled, err = time.Parse(time.RFC3339, campaignAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	segment, err := backend.GetSegmentByName(campaignSegment)
+	if err != nil {
+		return fmt.Errorf("failed to get segment \"%s\": %w", campaignSegment, err)
+	}
+
+	size, err := backend.AudienceSize(*segment)
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration((size+campaignRate-1)/campaignRate) * time.Minute
+	fmt.Printf("audience of %d users, sending takes about %s\n", size, duration)
+
+	if dryRun {
+		return nil
+	}
+
+	id, err := backend.CreateCampaign(campaigns.Campaign{
+		Name:      args[0],
+		Segment:   *segment,
+		Category:  category,
+		Body:      campaignBody,
+		Scheduled: scheduled,
+		Rate:      campaignRate,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("scheduled campaign %d for %s\n", id, scheduled.Format(time.RFC3339))
+	return nil
+}
+
+func runListCampaigns(*cobra.Command, []string) error {
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	list, err := backend.ListCampaigns()
+	if err != nil {
+		return err
+	}
+
+	for _, campaign := range list {
+		fmt.Printf(
+			"%d\t%s\t%s\t%s\t%s\t%d/min\n",
+			campaign.ID,
+			campaign.Name,
+			campaign.Status,
+			campaign.Scheduled.Format(time.RFC3339),
+			campaign.Segment.Name,
+			campaign.Rate,
+		)
+	}
+
+	return nil
+}
+
+func runCancelCampaign(_ *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	return backend.CancelCampaign(id)
+}
+
+func runReportCampaign(_ *cobra.Command, args []string) error {
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	report, err := backend.Report(id)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("audience: %d\nsent: %d\nopened: %d\nopen rate: %.2f%%\n", report.Audience, report.Sent, report.Opened, report.OpenRate()*100)
+	return nil
+}
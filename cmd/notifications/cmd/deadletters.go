@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications/worker"
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+)
+
+var deadLetters = &cobra.Command{
+	Use:   "deadletters",
+	Short: "inspects notification jobs that failed too often",
+}
+
+var listDeadLetters = &cobra.Command{
+	Use:   "list",
+	Short: "lists dead-lettered notification jobs",
+	RunE:  runListDeadLetters,
+}
+
+var replayDeadLetters = &cobra.Command{
+	Use:   "replay [ids]",
+	Short: "moves dead-lettered notification jobs back onto the queue",
+	RunE:  runReplayDeadLetters,
+}
+
+var (
+	count     int
+	replayAll bool
+)
+
+func init() {
+	listDeadLetters.Flags().IntVarP(&count, "count", "n", 100, "maximum amount of jobs to list")
+	replayDeadLetters.Flags().BoolVarP(&replayAll, "all", "", false, "replay all dead-lettered jobs")
+
+	deadLetters.AddCommand(listDeadLetters)
+	deadLetters.AddCommand(replayDeadLetters)
+}
+
+func runListDeadLetters(*cobra.Command, []string) error {
+	queue := worker.NewQueue(redis.NewRedis(config.Redis), "cli")
+
+	letters, err := queue.DeadLetters(count)
+	if err != nil {
+		return err
+	}
+
+	for _, letter := range letters {
+		fmt.Printf(
+			"%s\t%s\t%s\t%d targets\t%s\n",
+			letter.ID,
+			letter.Failed.Format(time.RFC3339),
+			letter.Job.Notification.Category,
+			len(letter.Job.Targets),
+			letter.Error,
+		)
+	}
+
+	return nil
+}
+
+func runReplayDeadLetters(_ *cobra.Command, args []string) error {
+	queue := worker.NewQueue(redis.NewRedis(config.Redis), "cli")
+
+	ids := args
+	if replayAll {
+		letters, err := queue.DeadLetters(0)
+		if err != nil {
+			return err
+		}
+
+		ids = make([]string, 0, len(letters))
+		for _, letter := range letters {
+			ids = append(ids, letter.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return errors.New("no jobs to replay")
+	}
+
+	for _, id := range ids {
+		err := queue.Replay(id)
+		if err != nil {
+			return fmt.Errorf("failed to replay %s: %w", id, err)
+		}
+	}
+
+	fmt.Printf("replayed %d jobs\n", len(ids))
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+	"github.com/soapboxsocial/soapbox/pkg/sql"
+)
+
+// inboxKeyRegex matches the redis lists notifications used to be stored in.
+var inboxKeyRegex = regexp.MustCompile(`^notifications_(\d+)$`)
+
+var migrateInbox = &cobra.Command{
+	Use:   "migrate-inbox",
+	Short: "moves notifications stored in redis into the postgres inbox",
+	RunE:  runMigrateInbox,
+}
+
+func runMigrateInbox(*cobra.Command, []string) error {
+	rdb := redis.NewRedis(config.Redis)
+
+	db, err := sql.Open(config.DB)
+	if err != nil {
+		return err
+	}
+
+	storage := notifications.NewStorage(db)
+	ctx := rdb.Context()
+
+	users, migrated := 0, 0
+
+	iter := rdb.Scan(ctx, 0, "notifications_*", 1000).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		match := inboxKeyRegex.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+
+		user, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+
+		count, err := migrateUserInbox(rdb, storage, user, key)
+		if err != nil {
+			log.Printf("failed to migrate %s err: %v", key, err)
+			continue
+		}
+
+		users++
+		migrated += count
+	}
+
+	if err := iter.Err(); err != nil {
+		return err
+	}
+
+	fmt.Printf("migrated %d notifications for %d users\n", migrated, users)
+
+	return nil
+}
+
+func migrateUserInbox(rdb *goredis.Client, storage *notifications.Storage, user int, key string) (int, error) {
+	ctx := rdb.Context()
+
+	data, err := rdb.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	hasNew := fmt.Sprintf("has_new_notifications_%d", user)
+	unread := rdb.Get(ctx, hasNew).Val() != ""
+
+	// the list is newest first, we insert the oldest first so IDs keep the order.
+	count := 0
+	for i := len(data) - 1; i >= 0; i-- {
+		n := &notifications.Notification{}
+		err := json.Unmarshal([]byte(data[i]), n)
+		if err != nil {
+			log.Printf("failed to unmarshal notification err: %v\n", err)
+			continue
+		}
+
+		// welcome rooms were stored with a separate room argument.
+		if room, ok := n.Arguments["room"]; ok {
+			n.Arguments = map[string]interface{}{"id": room, "from": n.From}
+		}
+
+		n.Read = !unread
+
+		err = storage.Store(user, n)
+		if err != nil {
+			return count, err
+		}
+
+		count++
+	}
+
+	return count, rdb.Del(ctx, key, hasNew).Err()
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/reengagement"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/worker"
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+	roompb "github.com/soapboxsocial/soapbox/pkg/rooms/pb"
+	"github.com/soapboxsocial/soapbox/pkg/sql"
+)
+
+var reengage = &cobra.Command{
+	Use:   "reengage",
+	Short: "asks inactive users to come back",
+	Long:  "Sends users that have been inactive a notification about friends that are live, new followers or popular rooms. The worker sends each user at most one re-engagement notification a week.",
+	RunE:  runReEngage,
+}
+
+var (
+	inactiveDays    int
+	maxInactiveDays int
+)
+
+func init() {
+	reengage.Flags().IntVarP(&inactiveDays, "days", "d", 7, "minimum amount of days users have been inactive")
+	reengage.Flags().IntVarP(&maxInactiveDays, "max-days", "", 60, "maximum amount of days users have been inactive")
+}
+
+func runReEngage(*cobra.Command, []string) error {
+	if inactiveDays >= maxInactiveDays {
+		return errors.New("days must be less than max-days")
+	}
+
+	db, err := sql.Open(config.DB)
+	if err != nil {
+		return errors.Wrap(err, "failed to open db")
+	}
+
+	conn, err := grpc.Dial(fmt.Sprintf("%s:%d", config.Rooms.Host, config.Rooms.Port), grpc.WithInsecure())
+	if err != nil {
+		return errors.Wrap(err, "failed to dial rooms")
+	}
+
+	defer conn.Close()
+
+	// jobs are only queued, they are sent by the running workers.
+	dispatch := worker.NewDispatcher(0, worker.NewQueue(redis.NewRedis(config.Redis), "cli"), nil)
+
+	job := reengagement.NewJob(
+		reengagement.NewBackend(db),
+		notifications.NewSettings(db),
+		roompb.NewRoomServiceClient(conn),
+		dispatch,
+	)
+
+	sent, err := job.Run(inactiveDays, maxInactiveDays)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("queued re-engagement notifications for %d users", sent)
+
+	return nil
+}
@@ -25,6 +25,13 @@ func init() {
 
 	rootCmd.AddCommand(workerCmd)
 	rootCmd.AddCommand(send)
+	rootCmd.AddCommand(vapid)
+	rootCmd.AddCommand(deadLetters)
+	rootCmd.AddCommand(migrateInbox)
+	rootCmd.AddCommand(segments)
+	rootCmd.AddCommand(campaignsCmd)
+	rootCmd.AddCommand(stats)
+	rootCmd.AddCommand(reengage)
 }
 
 // Execute executes the root command.
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications/campaigns"
+	"github.com/soapboxsocial/soapbox/pkg/sql"
+)
+
+var segments = &cobra.Command{
+	Use:   "segments",
+	Short: "manages audience segments for campaigns",
+}
+
+var createSegment = &cobra.Command{
+	Use:   "create [name]",
+	Short: "creates a segment from filters",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runCreateSegment,
+}
+
+var listSegments = &cobra.Command{
+	Use:   "list",
+	Short: "lists all segments",
+	RunE:  runListSegments,
+}
+
+var segmentSize = &cobra.Command{
+	Use:   "size [name]",
+	Short: "reports the amount of users in a segment",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSegmentSize,
+}
+
+var deleteSegment = &cobra.Command{
+	Use:   "delete [name]",
+	Short: "deletes a segment that is not used by a campaign",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDeleteSegment,
+}
+
+var (
+	activeWithinDays int
+	follows          int
+	minRoomMinutes   int
+	maxRoomMinutes   int
+)
+
+func init() {
+	createSegment.Flags().IntVarP(&activeWithinDays, "active-within", "", 0, "users active within the amount of days")
+	createSegment.Flags().IntVarP(&follows, "follows", "", 0, "users following the user ID")
+	createSegment.Flags().IntVarP(&minRoomMinutes, "min-room-minutes", "", 0, "users with at least the amount of minutes in rooms in the last 30 days")
+	createSegment.Flags().IntVarP(&maxRoomMinutes, "max-room-minutes", "", 0, "users with less than the amount of minutes in rooms in the last 30 days")
+
+	segments.AddCommand(createSegment)
+	segments.AddCommand(listSegments)
+	segments.AddCommand(segmentSize)
+	segments.AddCommand(deleteSegment)
+}
+
+func runCreateSegment(_ *cobra.Command, args []string) error {
+	segment := campaigns.Segment{Name: args[0]}
+
+	values := []struct {
+		filter campaigns.FilterType
+		value  int
+	}{
+		{campaigns.FilterActiveWithinDays, activeWithinDays},
+		{campaigns.FilterFollows, follows},
+		{campaigns.FilterMinRoomMinutes, minRoomMinutes},
+		{campaigns.FilterMaxRoomMinutes, maxRoomMinutes},
+	}
+
+	for _, v := range values {
+		if v.value == 0 {
+			continue
+		}
+
+		segment.Filters = append(segment.Filters, campaigns.Filter{Type: v.filter, Value: v.value})
+	}
+
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	size, err := backend.AudienceSize(segment)
+	if err != nil {
+		return err
+	}
+
+	id, err := backend.CreateSegment(segment)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created segment %d with %d users\n", id, size)
+	return nil
+}
+
+func runListSegments(*cobra.Command, []string) error {
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	list, err := backend.ListSegments()
+	if err != nil {
+		return err
+	}
+
+	for _, segment := range list {
+		filters := make([]string, 0, len(segment.Filters))
+		for _, f := range segment.Filters {
+			filters = append(filters, f.String())
+		}
+
+		fmt.Printf("%d\t%s\t%s\n", segment.ID, segment.Name, strings.Join(filters, " "))
+	}
+
+	return nil
+}
+
+func runSegmentSize(_ *cobra.Command, args []string) error {
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	segment, err := backend.GetSegmentByName(args[0])
+	if err != nil {
+		return err
+	}
+
+	size, err := backend.AudienceSize(*segment)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d users\n", size)
+	return nil
+}
+
+func runDeleteSegment(_ *cobra.Command, args []string) error {
+	backend, err := campaignsBackend()
+	if err != nil {
+		return err
+	}
+
+	return backend.DeleteSegment(args[0])
+}
+
+func campaignsBackend() (*campaigns.Backend, error) {
+	db, err := sql.Open(config.DB)
+	if err != nil {
+		return nil, errors.New("failed to open db")
+	}
+
+	return campaigns.NewBackend(db), nil
+}
@@ -7,8 +7,8 @@ import (
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/notifications/pb"
-	"github.com/soapboxsocial/soapbox/pkg/sql"
 )
 
 var send = &cobra.Command{
@@ -22,7 +22,7 @@ var (
 
 	// Related to who to send the notification to
 	targets []int64
-	query   string
+	segment string
 
 	// The actual notification data
 	body     string
@@ -32,21 +32,22 @@ var (
 func init() {
 	send.Flags().StringVarP(&addr, "addr", "a", "127.0.0.1:50053", "grpc address")
 	send.Flags().Int64SliceVarP(&targets, "targets", "t", []int64{}, "target user IDs")
-	send.Flags().StringVarP(&query, "query", "q", "", "a query for target users")
+	send.Flags().StringVarP(&segment, "segment", "s", "", "name of a segment of target users")
 
 	send.Flags().StringVarP(&body, "body", "", "", "notification body")
 	send.Flags().StringVarP(&category, "category", "", "", "notification category")
 }
 
 func runSend(*cobra.Command, []string) error {
-	if body == "" {
-		return errors.New("body cannot be empty")
-	}
-
 	if category == "" {
 		return errors.New("category cannot be empty")
 	}
 
+	// silent notifications such as feed refreshes are not shown, they have no body.
+	if body == "" && !notifications.IsSilent(notifications.NotificationCategory(category)) {
+		return errors.New("body cannot be empty")
+	}
+
 	notification := &pb.Notification{
 		Category: category,
 		Alert: &pb.Notification_Alert{
@@ -81,31 +82,19 @@ func getTargets() ([]int64, error) {
 		return targets, nil
 	}
 
-	if query == "" {
-		return nil, errors.New("query not supplied")
+	if segment == "" {
+		return nil, errors.New("segment not supplied")
 	}
 
-	db, err := sql.Open(config.DB)
+	backend, err := campaignsBackend()
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := db.Query(query)
+	s, err := backend.GetSegmentByName(segment)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]int64, 0)
-
-	for rows.Next() {
-		var id int64
-		err := rows.Scan(&id)
-		if err != nil {
-			continue
-		}
-
-		result = append(result, id)
-	}
-
-	return result, nil
+	return backend.Audience(*s)
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/analytics"
+	"github.com/soapboxsocial/soapbox/pkg/sql"
+)
+
+var stats = &cobra.Command{
+	Use:   "stats",
+	Short: "reports how many notifications were sent, opened and converted",
+	Long:  "Groups notification analytics by category, hour, handler or variant. Variants are compared against the control variant of their handler.",
+	RunE:  runStats,
+}
+
+var (
+	statsBy    string
+	statsSince time.Duration
+)
+
+func init() {
+	stats.Flags().StringVarP(&statsBy, "by", "b", string(analytics.DimensionCategory), "category, hour, handler or variant")
+	stats.Flags().DurationVarP(&statsSince, "since", "", 7*24*time.Hour, "how far back to report")
+}
+
+func runStats(*cobra.Command, []string) error {
+	db, err := sql.Open(config.DB)
+	if err != nil {
+		return err
+	}
+
+	dimension := analytics.Dimension(statsBy)
+
+	now := time.Now()
+	list, err := analytics.NewBackend(db).Stats(dimension, now.Add(-statsSince), now)
+	if err != nil {
+		return err
+	}
+
+	controls := variantControls(list)
+
+	for _, stat := range list {
+		low, high := stat.Interval()
+		line := fmt.Sprintf("%s\tsent: %d\topened: %d\topen rate: %.2f%% (%.2f%% - %.2f%%)", stat.Group, stat.Sent, stat.Opened, stat.OpenRate()*100, low*100, high*100)
+
+		if stat.Converted > 0 {
+			line += fmt.Sprintf("\tconverted: %d", stat.Converted)
+		}
+
+		if dimension == analytics.DimensionVariant {
+			control, ok := controls[handlerOf(stat)]
+			if ok && control.Group != stat.Group {
+				line += fmt.Sprintf("\tz: %.2f vs %s", stat.ZScore(control), control.Group)
+			}
+		}
+
+		fmt.Println(line)
+	}
+
+	return nil
+}
+
+// variantControls returns the variant named control for every handler, or its first variant if there is none.
+func variantControls(list []analytics.Stat) map[string]analytics.Stat {
+	controls := make(map[string]analytics.Stat)
+
+	for _, stat := range list {
+		handler := handlerOf(stat)
+
+		_, ok := controls[handler]
+		if !ok || strings.HasSuffix(stat.Group, "/control") {
+			controls[handler] = stat
+		}
+	}
+
+	return controls
+}
+
+func handlerOf(stat analytics.Stat) string {
+	return strings.SplitN(stat.Group, "/", 2)[0]
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/soapboxsocial/soapbox/pkg/webpush"
+)
+
+var vapid = &cobra.Command{
+	Use:   "vapid",
+	Short: "generates a VAPID key pair for web push",
+	RunE:  runVapid,
+}
+
+func runVapid(*cobra.Command, []string) error {
+	keys, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("public = \"%s\"\n", keys.PublicKey())
+	fmt.Printf("private = \"%s\"\n", keys.PrivateKey())
+
+	return nil
+}
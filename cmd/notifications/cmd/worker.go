@@ -5,8 +5,14 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	netmail "net/mail"
+	"os"
+	"time"
 
+	goredis "github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
+	"github.com/sendgrid/sendgrid-go"
 	"github.com/sideshow/apns2"
 	"github.com/sideshow/apns2/token"
 	"github.com/spf13/cobra"
@@ -16,10 +22,14 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/apple"
 	"github.com/soapboxsocial/soapbox/pkg/conf"
 	"github.com/soapboxsocial/soapbox/pkg/devices"
+	"github.com/soapboxsocial/soapbox/pkg/mail"
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/campaigns"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/email"
 	notificationsGRPC "github.com/soapboxsocial/soapbox/pkg/notifications/grpc"
 	"github.com/soapboxsocial/soapbox/pkg/notifications/handlers"
 	"github.com/soapboxsocial/soapbox/pkg/notifications/pb"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
 	"github.com/soapboxsocial/soapbox/pkg/notifications/worker"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 
@@ -28,18 +38,25 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/rooms"
 	roompb "github.com/soapboxsocial/soapbox/pkg/rooms/pb"
 	"github.com/soapboxsocial/soapbox/pkg/sql"
+	"github.com/soapboxsocial/soapbox/pkg/stories"
 	"github.com/soapboxsocial/soapbox/pkg/users"
+	"github.com/soapboxsocial/soapbox/pkg/webpush"
 )
 
 type Conf struct {
 	Notifications struct {
 		Environment string `mapstructure:"environment"`
+
+		// Images is the URL profile pictures are served from, they are shown in push notifications.
+		Images string `mapstructure:"images"`
 	} `mapstructure:"notifications"`
-	APNS  conf.AppleConf    `mapstructure:"apns"`
-	Redis conf.RedisConf    `mapstructure:"redis"`
-	DB    conf.PostgresConf `mapstructure:"db"`
-	Rooms conf.AddrConf     `mapstructure:"rooms"`
-	GRPC  conf.AddrConf     `mapstructure:"GRPC"`
+	APNS    conf.AppleConf    `mapstructure:"apns"`
+	WebPush conf.VAPIDConf    `mapstructure:"webpush"`
+	Email   conf.EmailConf    `mapstructure:"email"`
+	Redis   conf.RedisConf    `mapstructure:"redis"`
+	DB      conf.PostgresConf `mapstructure:"db"`
+	Rooms   conf.AddrConf     `mapstructure:"rooms"`
+	GRPC    conf.AddrConf     `mapstructure:"GRPC"`
 }
 
 var workerCmd = &cobra.Command{
@@ -82,59 +99,126 @@ func runWorker(*cobra.Command, []string) error {
 	}
 
 	settings := notifications.NewSettings(db)
-	notificationHandlers := setupHandlers(db, config.Rooms, settings)
+	notificationHandlers := setupHandlers(db, rdb, config.Rooms, settings)
+
+	events := queue.Subscribe(pubsub.RoomTopic, pubsub.UserTopic, pubsub.StoryTopic)
 
-	events := queue.Subscribe(pubsub.RoomTopic, pubsub.UserTopic)
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		return errors.Wrap(err, "failed to load templates")
+	}
 
-	dispatch := worker.NewDispatcher(5, &worker.Config{
-		APNS:      apple.NewAPNS(config.APNS.Bundle, client),
+	wc := &worker.Config{
+		APNS:      apple.NewAPNS(config.APNS.Bundle, config.Notifications.Images, client),
 		Limiter:   notifications.NewLimiter(rdb, currentRoom),
 		Devices:   devices.NewBackend(db),
-		Store:     notifications.NewStorage(rdb),
+		Store:     notifications.NewStorage(db),
+		Digests:   notifications.NewDigests(rdb),
 		Analytics: analytics.NewBackend(db),
-	})
-	dispatch.Run()
+		Templates: registry,
+	}
+
+	if config.WebPush.PrivateKey != "" {
+		keys, err := webpush.NewVAPIDKeys(config.WebPush.PrivateKey)
+		if err != nil {
+			return errors.Wrap(err, "failed to load vapid keys")
+		}
+
+		wc.WebPush = webpush.NewClient(&http.Client{Timeout: 10 * time.Second}, keys, config.WebPush.Subject)
+	}
+
+	if config.Email.From != "" {
+		sender, err := newSender(config.Email)
+		if err != nil {
+			return err
+		}
+
+		wc.Email = email.NewClient(
+			sender,
+			email.NewBackend(db),
+			registry,
+			netmail.Address{Name: config.Email.Name, Address: config.Email.From},
+			config.Email.Unsubscribe,
+		)
+	}
+
+	dispatch := worker.NewDispatcher(5, worker.NewQueue(rdb, consumer()), wc)
+	err = dispatch.Run()
+	if err != nil {
+		return errors.Wrap(err, "failed to start dispatcher")
+	}
+
+	runner := campaigns.NewRunner(campaigns.NewBackend(db), settings, dispatch)
+	go runner.Run(10 * time.Second)
 
 	go func() {
 		for event := range events {
-			go func(event *pubsub.Event) {
-				h := notificationHandlers[event.Type]
-				if h == nil {
-					return
-				}
-
-				targets, err := h.Targets(event)
-				if err != nil {
-					log.Printf("failed to get targets: %s", err)
-					return
-				}
-
-				if len(targets) == 0 {
-					log.Printf("no targets for: %d", event.Type)
-					return
-				}
-
-				notification, err := h.Build(event)
-				if err != nil {
-					log.Printf("failed to build notifcation: %s", err)
-					return
-				}
-
-				id, err := h.Origin(event)
-				if err != nil {
-					if err != handlers.ErrNoCreator {
-						log.Printf("failed to get origin: %s", err)
-					}
-				}
-
-				dispatch.Dispatch(id, targets, notification)
-			}(event)
+			// multiple handlers can build notifications for the same event.
+			for _, h := range notificationHandlers[event.Type] {
+				go handleEvent(h, event, dispatch)
+			}
 		}
 	}()
 
 	return runServer(config.GRPC, dispatch, settings)
 }
 
+func handleEvent(h handlers.Handler, event *pubsub.Event, dispatch *worker.Dispatcher) {
+	targets, err := h.Targets(event)
+	if err != nil {
+		log.Printf("failed to get targets: %s", err)
+		return
+	}
+
+	if len(targets) == 0 {
+		log.Printf("no targets for: %d", event.Type)
+		return
+	}
+
+	notification, err := h.Build(event)
+	if err != nil {
+		log.Printf("failed to build notifcation: %s", err)
+		return
+	}
+
+	notification.Handler = handlers.Name(h)
+	if v, ok := h.(handlers.VariantHandler); ok {
+		notification.Variants = v.Variants(notification)
+	}
+
+	id, err := h.Origin(event)
+	if err != nil {
+		if err != handlers.ErrNoCreator {
+			log.Printf("failed to get origin: %s", err)
+		}
+	}
+
+	dispatch.Dispatch(id, targets, notification)
+}
+
+// newSender returns an SMTP sender when a server is configured, this allows using a local sink during development.
+func newSender(config conf.EmailConf) (mail.Sender, error) {
+	if config.SMTP != "" {
+		return mail.NewSMTPSender(config.SMTP, config.Username, config.Password), nil
+	}
+
+	if config.SendGrid != "" {
+		return mail.NewSendGridSender(sendgrid.NewSendClient(config.SendGrid)), nil
+	}
+
+	return nil, errors.New("no email sender configured")
+}
+
+// consumer returns a name identifying this process on the job queue.
+func consumer() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
 func runServer(addr conf.AddrConf, dispatcher *worker.Dispatcher, settings *notifications.Settings) error {
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", addr.Host, addr.Port))
 	if err != nil {
@@ -155,13 +239,13 @@ func runServer(addr conf.AddrConf, dispatcher *worker.Dispatcher, settings *noti
 	return nil
 }
 
-func setupHandlers(db *sqldb.DB, roomsAddr conf.AddrConf, settings *notifications.Settings) map[pubsub.EventType]handlers.Handler {
+func setupHandlers(db *sqldb.DB, rdb *goredis.Client, roomsAddr conf.AddrConf, settings *notifications.Settings) map[pubsub.EventType][]handlers.Handler {
 	userBackend := users.NewBackend(db)
 
-	notificationHandlers := make(map[pubsub.EventType]handlers.Handler)
+	notificationHandlers := make(map[pubsub.EventType][]handlers.Handler)
 
 	followers := handlers.NewFollowerNotificationHandler(settings, userBackend)
-	notificationHandlers[followers.Type()] = followers
+	notificationHandlers[followers.Type()] = append(notificationHandlers[followers.Type()], followers)
 
 	conn, err := grpc.Dial(fmt.Sprintf("%s:%d", roomsAddr.Host, roomsAddr.Port), grpc.WithInsecure())
 	if err != nil {
@@ -171,19 +255,31 @@ func setupHandlers(db *sqldb.DB, roomsAddr conf.AddrConf, settings *notification
 	metadata := roompb.NewRoomServiceClient(conn)
 
 	creation := handlers.NewRoomCreationNotificationHandler(settings, userBackend, metadata)
-	notificationHandlers[creation.Type()] = creation
+	notificationHandlers[creation.Type()] = append(notificationHandlers[creation.Type()], creation)
 
 	invite := handlers.NewRoomInviteNotificationHandler(settings, userBackend)
-	notificationHandlers[invite.Type()] = invite
+	notificationHandlers[invite.Type()] = append(notificationHandlers[invite.Type()], invite)
 
 	join := handlers.NewRoomJoinNotificationHandler(settings, metadata)
-	notificationHandlers[join.Type()] = join
+	notificationHandlers[join.Type()] = append(notificationHandlers[join.Type()], join)
+
+	trending := handlers.NewTrendingRoomNotificationHandler(settings, metadata, rdb)
+	notificationHandlers[trending.Type()] = append(notificationHandlers[trending.Type()], trending)
 
 	welcome := handlers.NewWelcomeRoomNotificationHandler(userBackend, settings)
-	notificationHandlers[welcome.Type()] = welcome
+	notificationHandlers[welcome.Type()] = append(notificationHandlers[welcome.Type()], welcome)
+
+	story := handlers.NewStoryNotificationHandler(settings, userBackend)
+	notificationHandlers[story.Type()] = append(notificationHandlers[story.Type()], story)
+
+	reaction := handlers.NewStoryReactionNotificationHandler(settings, userBackend, stories.NewBackend(db))
+	notificationHandlers[reaction.Type()] = append(notificationHandlers[reaction.Type()], reaction)
+
+	security := handlers.NewAccountSecurityNotificationHandler(settings)
+	notificationHandlers[security.Type()] = append(notificationHandlers[security.Type()], security)
 
 	// recommendations := handlers.NewFollowRecommendationsNotificationHandler(settings, follows.NewBackend(db))
-	// notificationHandlers[recommendations.Type()] = recommendations
+	// notificationHandlers[recommendations.Type()] = append(notificationHandlers[recommendations.Type()], recommendations)
 
 	return notificationHandlers
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	"github.com/soapboxsocial/soapbox/pkg/blocks"
 	"github.com/soapboxsocial/soapbox/pkg/conf"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
@@ -111,7 +112,7 @@ func runServer(*cobra.Command, []string) error {
 	endpoint := rooms.NewEndpoint(repository, server, auth)
 	router := endpoint.Router()
 
-	amw := middlewares.NewAuthenticationMiddleware(sm)
+	amw := middlewares.NewAuthenticationMiddleware(sm, accesstokens.NewBackend(db))
 	router.Use(amw.Middleware)
 
 	return http.ListenAndServe(fmt.Sprintf(":%d", config.API.Port), httputil.CORS(router))
@@ -7,6 +7,7 @@ import (
 	"github.com/dukex/mixpanel"
 
 	"github.com/soapboxsocial/soapbox/pkg/activeusers"
+	"github.com/soapboxsocial/soapbox/pkg/analytics"
 	"github.com/soapboxsocial/soapbox/pkg/conf"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 	"github.com/soapboxsocial/soapbox/pkg/redis"
@@ -20,6 +21,7 @@ type Conf struct {
 		RoomTimeLog bool `mapstructure:"roomtimelog"`
 		Mixpanel    bool `mapstructure:"mixpanel"`
 		LastActive  bool `mapstructure:"lastactive"`
+		Conversions bool `mapstructure:"conversions"`
 	} `mapstructure:"trackers"`
 	Mixpanel struct {
 		Token string `mapstructure:"token"`
@@ -77,6 +79,11 @@ func main() {
 		t = append(t, at)
 	}
 
+	if config.Trackers.Conversions {
+		ct := trackers.NewConversionTracker(analytics.NewBackend(db), redis.NewTimeoutStore(rdb))
+		t = append(t, ct)
+	}
+
 	events := queue.Subscribe(pubsub.RoomTopic, pubsub.UserTopic, pubsub.StoryTopic)
 
 	for evt := range events {
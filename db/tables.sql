@@ -4,22 +4,87 @@ CREATE TABLE IF NOT EXISTS users (
     id SERIAL PRIMARY KEY,
     display_name VARCHAR(256) NOT NULL,
     username VARCHAR(100) NOT NULL,
-    email VARCHAR(254) NOT NULL,
+    email VARCHAR(254),
+    phone VARCHAR(16),
     image VARCHAR(100) NOT NULL DEFAULT '',
     bio TEXT NOT NULL DEFAULT '',
-    joined TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    joined TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (email IS NOT NULL OR phone IS NOT NULL)
 );
 
 CREATE UNIQUE INDEX idx_email ON users (email);
 CREATE UNIQUE INDEX idx_username ON users (username);
 
-CREATE TABLE IF NOT EXISTS apple_authentication (
+-- users created before phone logins were supported.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone VARCHAR(16);
+ALTER TABLE users ALTER COLUMN email DROP NOT NULL;
+
+CREATE UNIQUE INDEX idx_phone ON users (phone);
+
+CREATE TABLE IF NOT EXISTS access_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires TIMESTAMPTZ,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used TIMESTAMPTZ,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_access_tokens_hash ON access_tokens (token_hash);
+CREATE INDEX idx_access_tokens_user ON access_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS user_identities (
     user_id INT NOT NULL,
-    apple_user TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE UNIQUE INDEX idx_user_identities_user_provider ON user_identities (user_id, provider);
+
+-- identities were previously only stored for apple.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'apple_authentication') THEN
+        INSERT INTO user_identities (user_id, provider, subject) SELECT user_id, 'apple', apple_user FROM apple_authentication ON CONFLICT DO NOTHING;
+        DROP TABLE apple_authentication;
+    END IF;
+END $$;
+
+-- users of login providers can also log in with their email once it is verified.
+CREATE TABLE IF NOT EXISTS email_logins (
+    user_id INT NOT NULL PRIMARY KEY,
+    verified TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- accounts are purged once their grace period ends, logging in before cancels the deletion.
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id INT NOT NULL PRIMARY KEY,
+    scheduled TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    purge_at TIMESTAMPTZ NOT NULL,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
-CREATE UNIQUE INDEX idx_apple_authentication ON apple_authentication (user_id);
+CREATE INDEX idx_account_deletions_purge_at ON account_deletions (purge_at);
+
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id INT NOT NULL,
+    role TEXT NOT NULL,
+    CHECK (role IN ('staff', 'tester', 'moderator', 'greeter')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role)
+);
+
+CREATE INDEX idx_user_roles_role ON user_roles (role);
+
+-- the users previously special-cased by ID.
+INSERT INTO user_roles (user_id, role) SELECT id, 'staff' FROM users WHERE id IN (1, 75) ON CONFLICT DO NOTHING;
+INSERT INTO user_roles (user_id, role) SELECT id, 'greeter' FROM users WHERE id IN (1, 75, 962) ON CONFLICT DO NOTHING;
 
 CREATE TABLE IF NOT EXISTS followers (
     follower INT NOT NULL,
@@ -30,6 +95,20 @@ CREATE TABLE IF NOT EXISTS followers (
     PRIMARY KEY (follower, user_id)
 );
 
+-- creators whose room alerts a user receives on every go-live, or has muted while still following them.
+CREATE TABLE IF NOT EXISTS creator_subscriptions (
+    user_id INT NOT NULL,
+    creator INT NOT NULL,
+    level TEXT NOT NULL,
+    CHECK (user_id != creator),
+    CHECK (level IN ('live', 'muted')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (creator) REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, creator)
+);
+
+CREATE INDEX idx_creator_subscriptions_creator ON creator_subscriptions (creator);
+
 -- Check token length
 CREATE TABLE IF NOT EXISTS devices (
     token VARCHAR(64) PRIMARY KEY,
@@ -37,6 +116,16 @@ CREATE TABLE IF NOT EXISTS devices (
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS webpush_subscriptions (
+    endpoint TEXT PRIMARY KEY,
+    user_id INT NOT NULL,
+    p256dh VARCHAR(100) NOT NULL,
+    auth VARCHAR(30) NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webpush_subscriptions_user_id ON webpush_subscriptions (user_id);
+
 CREATE TABLE IF NOT EXISTS linked_accounts (
     user_id INT NOT NULL,
     provider VARCHAR(7) NOT NULL,
@@ -178,10 +267,35 @@ CREATE TABLE IF NOT EXISTS notification_settings (
     room_frequency INT NOT NULL DEFAULT 2,
     follows BOOLEAN NOT NULL DEFAULT true,
     welcome_rooms BOOLEAN NOT NULL DEFAULT true,
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    quiet_hours BOOLEAN NOT NULL DEFAULT false,
+    quiet_hours_start SMALLINT NOT NULL DEFAULT 1320, -- minutes after midnight in the users timezone
+    quiet_hours_end SMALLINT NOT NULL DEFAULT 420,
+    locale TEXT NOT NULL DEFAULT 'en',
     CHECK (room_frequency IN (0, 1, 2, 3)), -- 0 = off, 1 - infrequent, 2 - normal, 3 - frequent
+    CHECK (quiet_hours_start >= 0 AND quiet_hours_start < 1440),
+    CHECK (quiet_hours_end >= 0 AND quiet_hours_end < 1440),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INT NOT NULL,
+    category TEXT NOT NULL,
+    push BOOLEAN NOT NULL,
+    inbox BOOLEAN NOT NULL,
+    email BOOLEAN NOT NULL,
+    PRIMARY KEY (user_id, category),
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS notification_email_tokens (
+    user_id INT NOT NULL PRIMARY KEY,
+    token TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_notification_email_tokens_token ON notification_email_tokens (token);
+
 CREATE OR REPLACE FUNCTION insert_notification_settings() RETURNS TRIGGER AS
     $notification_settings$
     BEGIN
@@ -196,6 +310,41 @@ CREATE TRIGGER insert_notification_settings_trigger
     FOR EACH ROW
     EXECUTE PROCEDURE insert_notification_settings();
 
+CREATE TABLE IF NOT EXISTS notification_segments (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    filters JSONB NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_notification_segments_name ON notification_segments (name);
+
+CREATE TABLE IF NOT EXISTS notification_campaigns (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    segment_id INT NOT NULL,
+    category TEXT NOT NULL,
+    body TEXT NOT NULL,
+    scheduled TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    rate INT NOT NULL, -- notifications per minute
+    status TEXT NOT NULL DEFAULT 'scheduled',
+    CHECK (rate > 0),
+    CHECK (status IN ('scheduled', 'running', 'completed', 'cancelled')),
+    FOREIGN KEY (segment_id) REFERENCES notification_segments(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX idx_notification_campaigns_status ON notification_campaigns (status, scheduled);
+
+CREATE TABLE IF NOT EXISTS notification_campaign_targets (
+    campaign_id INT NOT NULL,
+    user_id INT NOT NULL,
+    sent TIMESTAMPTZ,
+    PRIMARY KEY (campaign_id, user_id),
+    FOREIGN KEY (campaign_id) REFERENCES notification_campaigns(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notification_campaign_targets_sent ON notification_campaign_targets (campaign_id, sent);
+
 CREATE TABLE IF NOT EXISTS notification_analytics (
     id VARCHAR(36) NOT NULL,
     target INT NOT NULL,
@@ -203,13 +352,39 @@ CREATE TABLE IF NOT EXISTS notification_analytics (
     category TEXT NOT NULL,
     sent TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     opened TIMESTAMPTZ,
+    converted TIMESTAMPTZ,
     room VARCHAR(27),
+    campaign INT,
+    handler TEXT,
+    variant TEXT,
     FOREIGN KEY (target) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (origin) REFERENCES users(id) ON DELETE CASCADE
+    FOREIGN KEY (origin) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (campaign) REFERENCES notification_campaigns(id) ON DELETE SET NULL
 );
 
+CREATE INDEX idx_notification_analytics_campaign ON notification_analytics (campaign);
+
+CREATE INDEX idx_notification_analytics_sent ON notification_analytics (sent);
+
 CREATE UNIQUE INDEX idx_notification_analytics ON notification_analytics (id, target);
 
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    from_id INT,
+    category TEXT NOT NULL,
+    alert JSONB NOT NULL DEFAULT '{}',
+    arguments JSONB,
+    actors JSONB,
+    created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read BOOLEAN NOT NULL DEFAULT false,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (from_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_user_id ON notifications (user_id, id DESC);
+CREATE INDEX idx_notifications_unread ON notifications (user_id) WHERE read = false;
+
 CREATE TABLE IF NOT EXISTS follow_recommendations (
     user_id INT NOT NULL,
     recommendation INT NOT NULL,
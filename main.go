@@ -1,18 +1,22 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/elastic/go-elasticsearch/v7"
 	"github.com/gorilla/mux"
 	"github.com/sendgrid/sendgrid-go"
 
 	signinwithapple "github.com/Timothylock/go-signin-with-apple/apple"
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	"github.com/soapboxsocial/soapbox/pkg/account"
 	"github.com/soapboxsocial/soapbox/pkg/activeusers"
 	"github.com/soapboxsocial/soapbox/pkg/analytics"
@@ -20,6 +24,7 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/blocks"
 	"github.com/soapboxsocial/soapbox/pkg/conf"
 	"github.com/soapboxsocial/soapbox/pkg/devices"
+	"github.com/soapboxsocial/soapbox/pkg/exports"
 	"github.com/soapboxsocial/soapbox/pkg/followers"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
 	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
@@ -30,15 +35,21 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/me"
 	"github.com/soapboxsocial/soapbox/pkg/minis"
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/email"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
+	"github.com/soapboxsocial/soapbox/pkg/oidc"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 
 	// "github.com/soapboxsocial/soapbox/pkg/recommendations/follows"
 	"github.com/soapboxsocial/soapbox/pkg/redis"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
 	"github.com/soapboxsocial/soapbox/pkg/search"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
+	"github.com/soapboxsocial/soapbox/pkg/sms"
 	"github.com/soapboxsocial/soapbox/pkg/sql"
 	"github.com/soapboxsocial/soapbox/pkg/stories"
+	"github.com/soapboxsocial/soapbox/pkg/subscriptions"
 	"github.com/soapboxsocial/soapbox/pkg/users"
 	"google.golang.org/grpc"
 )
@@ -54,14 +65,18 @@ type Conf struct {
 	CDN struct {
 		Images  string `mapstructure:"images"`
 		Stories string `mapstructure:"stories"`
+		Exports string `mapstructure:"exports"`
 	} `mapstructure:"cdn"`
-	Apple  conf.AppleConf    `mapstructure:"apple"`
-	Redis  conf.RedisConf    `mapstructure:"redis"`
-	DB     conf.PostgresConf `mapstructure:"db"`
-	GRPC   conf.AddrConf     `mapstructure:"grpc"`
-	Listen conf.AddrConf     `mapstructure:"listen"`
-	Login  login.Config      `mapstructure:"login"`
-	Minis  []struct {
+	Apple   conf.AppleConf    `mapstructure:"apple"`
+	Google  conf.GoogleConf   `mapstructure:"google"`
+	Redis   conf.RedisConf    `mapstructure:"redis"`
+	SMS     conf.SMSConf      `mapstructure:"sms"`
+	DB      conf.PostgresConf `mapstructure:"db"`
+	GRPC    conf.AddrConf     `mapstructure:"grpc"`
+	Listen  conf.AddrConf     `mapstructure:"listen"`
+	Login   login.Config      `mapstructure:"login"`
+	WebPush conf.VAPIDConf    `mapstructure:"webpush"`
+	Minis   []struct {
 		Key string `mapstructure:"key"`
 		ID  int    `mapstructure:"id"`
 	} `mapstructure:"mini"`
@@ -110,7 +125,7 @@ func main() {
 	s := sessions.NewSessionManager(rdb)
 	ub := users.NewBackend(db)
 	fb := followers.NewFollowersBackend(db)
-	ns := notifications.NewStorage(rdb)
+	ns := notifications.NewStorage(db)
 	fmt.Printf("s: %+v\n\n", s)
 	fmt.Printf("ub: %+v\n\n", ub)
 	fmt.Printf("fb: %+v\n\n", fb)
@@ -125,9 +140,13 @@ func main() {
 	devicesBackend := devices.NewBackend(db)
 	fmt.Printf("devicesBackend: %+v\n\n", devicesBackend)
 
-	amw := middlewares.NewAuthenticationMiddleware(s)
+	tokens := accesstokens.NewBackend(db)
+	amw := middlewares.NewAuthenticationMiddleware(s, tokens)
 	fmt.Printf("amw: %+v\n\n", amw)
 
+	rolesBackend := roles.NewBackend(db)
+	pmw := middlewares.NewPermissionMiddleware(rolesBackend)
+
 	r := mux.NewRouter()
 	fmt.Printf("r: %+v\n\n", r)
 
@@ -166,6 +185,10 @@ func main() {
 		panic(err)
 	}
 
+	providers := map[string]oidc.Validator{
+		oidc.Google: oidc.NewGoogleProvider(&http.Client{Timeout: 10 * time.Second}, config.Google.ClientIDs),
+	}
+
 	conn, err := grpc.Dial(fmt.Sprintf("%s:%d", config.GRPC.Host, config.GRPC.Port), grpc.WithInsecure())
 	if err != nil {
 		log.Fatal(err)
@@ -177,7 +200,12 @@ func main() {
 	roomService := pb.NewRoomServiceClient(conn)
 	fmt.Printf("roomService: %+v\n\n", roomService)
 
-	loginEndpoints := login.NewEndpoint(ub, loginState, s, ms, ib, queue, appleClient, roomService, config.Login)
+	smsSender, err := newSMSSender(config.SMS)
+	if err != nil {
+		log.Fatalf("failed to create sms sender: %s", err)
+	}
+
+	loginEndpoints := login.NewEndpoint(ub, loginState, login.NewLimiter(rdb), s, ms, smsSender, ib, queue, appleClient, providers, roomService, config.Login)
 	loginRouter := loginEndpoints.Router()
 	fmt.Printf("loginRouter: %+v\n\n", loginRouter)
 	mount(r, "/v1/login", loginRouter)
@@ -192,6 +220,7 @@ func main() {
 		ib,
 		queue,
 		storiesBackend,
+		subscriptions.NewBackend(db),
 	)
 	usersRouter := usersEndpoints.Router()
 	usersRouter.Use(amw.Middleware)
@@ -202,16 +231,35 @@ func main() {
 	storiesRouter.Use(amw.Middleware)
 	mount(r, "/v1/stories", storiesRouter)
 
-	devicesEndpoint := devices.NewEndpoint(devicesBackend)
+	devicesEndpoint := devices.NewEndpoint(devicesBackend, config.WebPush.PublicKey)
 	devicesRoutes := devicesEndpoint.Router()
 	devicesRoutes.Use(amw.Middleware)
 	mount(r, "/v1/devices", devicesRoutes)
 
-	accountEndpoint := account.NewEndpoint(account.NewBackend(db), queue, s)
+	// unsubscribing from emails works without being logged in, users are identified by their unsubscribe token.
+	emailEndpoint := email.NewEndpoint(email.NewBackend(db), notifications.NewSettings(db))
+	mount(r, "/v1/notifications/email", emailEndpoint.Router())
+
+	accountEndpoint := account.NewEndpoint(account.NewBackend(db), ub, account.NewEmailVerification(rdb), exports.NewQueue(rdb), ms, queue, s)
 	accountRouter := accountEndpoint.Router()
 	accountRouter.Use(amw.Middleware)
 	mount(r, "/v1/account", accountRouter)
 
+	// exports are downloaded with the token of the emailed link.
+	exportsEndpoint := exports.NewEndpoint(exports.NewLinks(rdb), config.CDN.Exports)
+	mount(r, "/v1/exports", exportsEndpoint.Router())
+
+	sessionsEndpoint := sessions.NewEndpoint(s)
+	sessionsRouter := sessionsEndpoint.Router()
+	sessionsRouter.Use(amw.Middleware)
+	mount(r, "/v1/sessions", sessionsRouter)
+
+	// access tokens can only be managed with a session.
+	tokensEndpoint := accesstokens.NewEndpoint(tokens)
+	tokensRouter := tokensEndpoint.Router()
+	tokensRouter.Use(amw.Middleware)
+	mount(r, "/v1/tokens", tokensRouter)
+
 	blocksBackend := blocks.NewBackend(db)
 	blocksEndpoint := blocks.NewEndpoint(blocksBackend)
 	blocksRouter := blocksEndpoint.Router()
@@ -227,7 +275,12 @@ func main() {
 
 	pb := linkedaccounts.NewLinkedAccountsBackend(db)
 
-	meEndpoint := me.NewEndpoint(ub, ns, pb, storiesBackend, queue, activeusers.NewBackend(db), notifications.NewSettings(db))
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		log.Fatalf("failed to load notification templates: %s", err)
+	}
+
+	meEndpoint := me.NewEndpoint(ub, ns, pb, storiesBackend, queue, activeusers.NewBackend(db), notifications.NewSettings(db), registry)
 	meRoutes := meEndpoint.Router()
 
 	meRoutes.Use(amw.Middleware)
@@ -251,11 +304,16 @@ func main() {
 	mount(r, "/v1/minis", minisRouter)
 
 	analyticsBackend := analytics.NewBackend(db)
-	analyticsEndpoint := analytics.NewEndpoint(analyticsBackend)
+	analyticsEndpoint := analytics.NewEndpoint(analyticsBackend, pmw)
 	analyticsRouter := analyticsEndpoint.Router()
 	analyticsRouter.Use(amw.Middleware)
 	mount(r, "/v1/analytics", analyticsRouter)
 
+	rolesEndpoint := roles.NewEndpoint(rolesBackend)
+	rolesRouter := rolesEndpoint.Router()
+	rolesRouter.Use(amw.Middleware, pmw.Require(roles.PermissionAdmin))
+	mount(r, "/v1/roles", rolesRouter)
+
 	err = http.ListenAndServe(fmt.Sprintf(":%d", config.Listen.Port), httputil.CORS(r))
 	if err != nil {
 		log.Print(err)
@@ -285,3 +343,20 @@ func AddSlashForRoot(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+func newSMSSender(config conf.SMSConf) (sms.Sender, error) {
+	if config.Account != "" {
+		return sms.NewTwilioSender(&http.Client{Timeout: 10 * time.Second}, config.Account, config.Token, config.From), nil
+	}
+
+	if config.Log != "" {
+		file, err := os.OpenFile(config.Log, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		return sms.NewLogSender(file), nil
+	}
+
+	return nil, errors.New("no sms sender configured")
+}
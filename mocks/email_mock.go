@@ -0,0 +1,49 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/notifications/email.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	notifications "github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// MockEmail is a mock of Email interface.
+type MockEmail struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmailMockRecorder
+}
+
+// MockEmailMockRecorder is the mock recorder for MockEmail.
+type MockEmailMockRecorder struct {
+	mock *MockEmail
+}
+
+// NewMockEmail creates a new mock instance.
+func NewMockEmail(ctrl *gomock.Controller) *MockEmail {
+	mock := &MockEmail{ctrl: ctrl}
+	mock.recorder = &MockEmailMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmail) EXPECT() *MockEmailMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockEmail) Send(target notifications.Target, notification notifications.PushNotification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", target, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockEmailMockRecorder) Send(target, notification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockEmail)(nil).Send), target, notification)
+}
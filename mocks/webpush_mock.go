@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/notifications/webpush.go
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	devices "github.com/soapboxsocial/soapbox/pkg/devices"
+	notifications "github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// MockWebPush is a mock of WebPush interface.
+type MockWebPush struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebPushMockRecorder
+}
+
+// MockWebPushMockRecorder is the mock recorder for MockWebPush.
+type MockWebPushMockRecorder struct {
+	mock *MockWebPush
+}
+
+// NewMockWebPush creates a new mock instance.
+func NewMockWebPush(ctrl *gomock.Controller) *MockWebPush {
+	mock := &MockWebPush{ctrl: ctrl}
+	mock.recorder = &MockWebPushMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebPush) EXPECT() *MockWebPushMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockWebPush) Send(subscription devices.WebPushSubscription, notification notifications.PushNotification) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", subscription, notification)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockWebPushMockRecorder) Send(subscription, notification interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockWebPush)(nil).Send), subscription, notification)
+}
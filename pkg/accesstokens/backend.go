@@ -0,0 +1,213 @@
+// Package accesstokens manages personal access tokens, scoped credentials used by bots and integrations.
+package accesstokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Prefix starts every access token, it distinguishes them from session tokens.
+const Prefix = "sbx_"
+
+var (
+	ErrInvalidToken  = errors.New("invalid access token")
+	ErrTokenNotFound = errors.New("access token not found")
+)
+
+// AccessToken describes a token, the token itself is only known when it is created.
+type AccessToken struct {
+	ID       int        `json:"id"`
+	UserID   int        `json:"-"`
+	Name     string     `json:"name"`
+	Scopes   []Scope    `json:"scopes"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	Created  time.Time  `json:"created"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// HasScope returns whether the token was granted the scope.
+func (t *AccessToken) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAccessToken returns whether the credential is an access token rather than a session token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, Prefix)
+}
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+// Create stores a new token for the user and returns it, only its hash is stored.
+func (b *Backend) Create(user int, name string, scopes []Scope, expires *time.Time) (string, *AccessToken, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt, err := b.db.Prepare("INSERT INTO access_tokens (user_id, name, token_hash, scopes, expires) VALUES ($1, $2, $3, $4, $5) RETURNING id, created;")
+	if err != nil {
+		return "", nil, err
+	}
+
+	at := &AccessToken{UserID: user, Name: name, Scopes: scopes, Expires: expires}
+	err = stmt.QueryRow(user, name, hash(token), pq.Array(toStrings(scopes)), expires).Scan(&at.ID, &at.Created)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, at, nil
+}
+
+// Authenticate returns the token, expired tokens and tokens of accounts scheduled for deletion are invalid.
+func (b *Backend) Authenticate(token string) (*AccessToken, error) {
+	stmt, err := b.db.Prepare(
+		"SELECT id, user_id, name, scopes, expires, created, last_used FROM access_tokens " +
+			"WHERE token_hash = $1 AND (expires IS NULL OR expires > NOW()) " +
+			"AND user_id NOT IN (SELECT user_id FROM account_deletions);",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := scan(stmt.QueryRow(hash(token)))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+
+	return at, err
+}
+
+// Touch records that the token was used, it is only updated once a minute.
+func (b *Backend) Touch(id int) error {
+	stmt, err := b.db.Prepare("UPDATE access_tokens SET last_used = NOW() WHERE id = $1 AND (last_used IS NULL OR last_used < NOW() - INTERVAL '1 minute');")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(id)
+	return err
+}
+
+// List returns the tokens of the user, including expired ones.
+func (b *Backend) List(user int) ([]AccessToken, error) {
+	stmt, err := b.db.Prepare("SELECT id, user_id, name, scopes, expires, created, last_used FROM access_tokens WHERE user_id = $1 ORDER BY created DESC;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(user)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := make([]AccessToken, 0)
+	for rows.Next() {
+		at, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, *at)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Revoke deletes a token of the user.
+func (b *Backend) Revoke(user, id int) error {
+	stmt, err := b.db.Prepare("DELETE FROM access_tokens WHERE id = $1 AND user_id = $2;")
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(id, user)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrTokenNotFound
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*AccessToken, error) {
+	at := &AccessToken{}
+
+	var scopes []string
+	var expires, lastUsed sql.NullTime
+	err := row.Scan(&at.ID, &at.UserID, &at.Name, pq.Array(&scopes), &expires, &at.Created, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+
+	at.Scopes = make([]Scope, 0, len(scopes))
+	for _, s := range scopes {
+		at.Scopes = append(at.Scopes, Scope(s))
+	}
+
+	if expires.Valid {
+		at.Expires = &expires.Time
+	}
+
+	if lastUsed.Valid {
+		at.LastUsed = &lastUsed.Time
+	}
+
+	return at, nil
+}
+
+func toStrings(scopes []Scope) []string {
+	values := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		values = append(values, string(s))
+	}
+
+	return values
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + hex.EncodeToString(b), nil
+}
+
+func hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
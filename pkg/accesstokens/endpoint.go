@@ -0,0 +1,157 @@
+package accesstokens
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+)
+
+const (
+	// maxNameLength is the maximum amount of characters in a token name.
+	maxNameLength = 64
+
+	// maxExpiration is the longest a token can be valid for, tokens without an expiry never expire.
+	maxExpiration = 365 * 24 * time.Hour
+)
+
+type Endpoint struct {
+	backend *Backend
+}
+
+func NewEndpoint(backend *Backend) *Endpoint {
+	return &Endpoint{backend: backend}
+}
+
+// Router returns the token routes, they declare no scopes so they can only be used with a session.
+func (e *Endpoint) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/", e.list).Methods("GET")
+	r.HandleFunc("/", e.create).Methods("POST")
+	r.HandleFunc("/{id:[0-9]+}", e.revoke).Methods("DELETE")
+
+	return r
+}
+
+func (e *Endpoint) list(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	tokens, err := e.backend.List(id)
+	if err != nil {
+		log.Printf("accesstokens.List err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to get tokens")
+		return
+	}
+
+	err = httputil.JsonEncode(w, tokens)
+	if err != nil {
+		log.Printf("failed to write tokens response: %s\n", err.Error())
+	}
+}
+
+func (e *Endpoint) create(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	name := strings.TrimSpace(r.Form.Get("name"))
+	if name == "" {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeMissingParameter, "missing parameter: name")
+		return
+	}
+
+	if len([]rune(name)) > maxNameLength {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "name too long")
+		return
+	}
+
+	if r.Form.Get("scopes") == "" {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeMissingParameter, "missing parameter: scopes")
+		return
+	}
+
+	scopes := make([]Scope, 0)
+	for _, value := range strings.Split(r.Form.Get("scopes"), ",") {
+		scope := Scope(strings.TrimSpace(value))
+		if !IsValidScope(scope) {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidScope, "invalid scope: "+string(scope))
+			return
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	var expires *time.Time
+	if value := r.Form.Get("expires_in"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxExpiration {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid parameter: expires_in")
+			return
+		}
+
+		at := time.Now().Add(time.Duration(seconds) * time.Second)
+		expires = &at
+	}
+
+	token, at, err := e.backend.Create(id, name, scopes, expires)
+	if err != nil {
+		log.Printf("accesstokens.Create err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to create token")
+		return
+	}
+
+	// the token is only returned once, it cannot be retrieved later.
+	err = httputil.JsonEncode(w, struct {
+		*AccessToken
+		Token string `json:"token"`
+	}{AccessToken: at, Token: token})
+	if err != nil {
+		log.Printf("failed to write token response: %s\n", err.Error())
+	}
+}
+
+func (e *Endpoint) revoke(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	token, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = e.backend.Revoke(id, token)
+	if err == ErrTokenNotFound {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "token not found")
+		return
+	}
+
+	if err != nil {
+		log.Printf("accesstokens.Revoke err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to revoke token")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
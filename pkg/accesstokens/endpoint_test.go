@@ -0,0 +1,167 @@
+package accesstokens_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+)
+
+func request(t *testing.T, method, path string, form url.Values) *http.Request {
+	r, err := http.NewRequest(method, path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.WithContext(httputil.WithUserID(r.Context(), 1))
+}
+
+func TestEndpoint_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := accesstokens.NewEndpoint(accesstokens.NewBackend(db)).Router()
+
+	mock.ExpectPrepare("^INSERT INTO access_tokens (.+)").ExpectQuery().
+		WithArgs(1, "bot", sqlmock.AnyArg(), `{"profile:read","rooms:join"}`, sqlmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"id", "created"}).AddRow(3, time.Now()))
+
+	form := url.Values{"name": {"bot"}, "scopes": {"profile:read, rooms:join"}, "expires_in": {"3600"}}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request(t, "POST", "/", form))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var result struct {
+		ID      int                  `json:"id"`
+		Token   string               `json:"token"`
+		Scopes  []accesstokens.Scope `json:"scopes"`
+		Expires *time.Time           `json:"expires"`
+	}
+
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.ID != 3 || !accesstokens.IsAccessToken(result.Token) || len(result.Scopes) != 2 || result.Expires == nil {
+		t.Fatalf("unexpected token %+v", result)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEndpoint_CreateInvalid(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := accesstokens.NewEndpoint(accesstokens.NewBackend(db)).Router()
+
+	tests := []url.Values{
+		{"scopes": {"profile:read"}},
+		{"name": {"bot"}},
+		{"name": {"bot"}, "scopes": {"admin"}},
+		{"name": {"bot"}, "scopes": {"follow"}, "expires_in": {"-1"}},
+	}
+
+	for _, form := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, request(t, "POST", "/", form))
+
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Fatalf("handler returned wrong status code for %v: got %v want %v", form, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestEndpoint_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := accesstokens.NewEndpoint(accesstokens.NewBackend(db)).Router()
+
+	mock.ExpectPrepare("^SELECT (.+) FROM access_tokens WHERE user_id").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(
+			mock.NewRows([]string{"id", "user_id", "name", "scopes", "expires", "created", "last_used"}).
+				AddRow(2, 1, "bot", "{follow}", nil, time.Now(), time.Now()),
+		)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request(t, "GET", "/", nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var result []map[string]interface{}
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0]["name"] != "bot" || result[0]["last_used"] == nil {
+		t.Fatalf("unexpected tokens %v", result)
+	}
+
+	if _, ok := result[0]["token"]; ok {
+		t.Fatal("listed tokens must not contain the token")
+	}
+}
+
+func TestEndpoint_Revoke(t *testing.T) {
+	tests := []struct {
+		affected int64
+		expected int
+	}{
+		{1, http.StatusOK},
+		{0, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.expected), func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			handler := accesstokens.NewEndpoint(accesstokens.NewBackend(db)).Router()
+
+			mock.ExpectPrepare("^DELETE FROM access_tokens").ExpectExec().
+				WithArgs(2, 1).
+				WillReturnResult(sqlmock.NewResult(0, tt.affected))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request(t, "DELETE", "/2", nil))
+
+			if status := rr.Code; status != tt.expected {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package accesstokens
+
+// Scope grants an access token the use of a group of routes.
+type Scope string
+
+const (
+	ScopeProfileRead  Scope = "profile:read"
+	ScopeFollow       Scope = "follow"
+	ScopeRoomsJoin    Scope = "rooms:join"
+	ScopeStoriesWrite Scope = "stories:write"
+)
+
+// Scopes are all scopes tokens can be created with.
+var Scopes = []Scope{ScopeProfileRead, ScopeFollow, ScopeRoomsJoin, ScopeStoriesWrite}
+
+// IsValidScope returns whether the scope exists.
+func IsValidScope(scope Scope) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,6 +1,10 @@
 package account
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
 
 type Backend struct {
 	db *sql.DB
@@ -12,12 +16,177 @@ func NewBackend(db *sql.DB) *Backend {
 	}
 }
 
-func (b *Backend) DeleteAccount(id int) error {
-	stmt, err := b.db.Prepare("DELETE FROM users WHERE id = $1")
+// ScheduleDeletion marks the account to be purged at the given time, unless the user logs in before.
+// The devices and web push subscriptions of the user are removed, clients register them again after logging in.
+func (b *Backend) ScheduleDeletion(id int, at time.Time) error {
+	ctx := context.Background()
+	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(id)
-	return err
+	_, err = tx.ExecContext(ctx, "INSERT INTO account_deletions (user_id, purge_at) VALUES ($1, $2) ON CONFLICT (user_id) DO NOTHING;", id, at)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM devices WHERE user_id = $1;", id)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM webpush_subscriptions WHERE user_id = $1;", id)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DueDeletions returns the accounts whose grace period ended.
+func (b *Backend) DueDeletions(now time.Time) ([]int, error) {
+	stmt, err := b.db.Prepare("SELECT user_id FROM account_deletions WHERE purge_at <= $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(now)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Files returns the profile image and story IDs of the user, whose files are removed with the account.
+func (b *Backend) Files(id int) (string, []string, error) {
+	stmt, err := b.db.Prepare("SELECT image FROM users WHERE id = $1;")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var image string
+	err = stmt.QueryRow(id).Scan(&image)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt, err = b.db.Prepare("SELECT id FROM stories WHERE user_id = $1;")
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := stmt.Query(id)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defer rows.Close()
+
+	stories := make([]string, 0)
+	for rows.Next() {
+		var story string
+		err := rows.Scan(&story)
+		if err != nil {
+			return "", nil, err
+		}
+
+		stories = append(stories, story)
+	}
+
+	return image, stories, rows.Err()
+}
+
+// DeleteAccount permanently deletes the user and all their data, it returns false if the deletion is no longer due.
+// This is the case when the user restored their account by logging in after the due deletions were read.
+func (b *Backend) DeleteAccount(id int) (bool, error) {
+	ctx := context.Background()
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := tx.ExecContext(
+		ctx,
+		"DELETE FROM users WHERE id = $1 AND EXISTS (SELECT 1 FROM account_deletions WHERE user_id = $1 AND purge_at <= NOW());",
+		id,
+	)
+
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// HasEmailLogin returns whether the user can log in with their email, users of login providers have to verify it first.
+func (b *Backend) HasEmailLogin(id int) (bool, error) {
+	stmt, err := b.db.Prepare("SELECT NOT EXISTS (SELECT 1 FROM user_identities WHERE user_id = $1) OR EXISTS (SELECT 1 FROM email_logins WHERE user_id = $1);")
+	if err != nil {
+		return false, err
+	}
+
+	var result bool
+	err = stmt.QueryRow(id).Scan(&result)
+	if err != nil {
+		return false, err
+	}
+
+	return result, nil
+}
+
+// ChangeEmail sets the verified email of the user, which they can then log in with.
+func (b *Backend) ChangeEmail(id int, email string) error {
+	ctx := context.Background()
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET email = $1 WHERE id = $2;", email, id)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO email_logins (user_id) VALUES ($1) ON CONFLICT (user_id) DO UPDATE SET verified = NOW();",
+		id,
+	)
+
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
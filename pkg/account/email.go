@@ -0,0 +1,137 @@
+package account
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/pins"
+)
+
+const (
+	emailPinExpiration = 15 * time.Minute
+
+	// emailResendCooldown is how long users wait before requesting another code.
+	emailResendCooldown = time.Minute
+
+	// maxEmailPinAttempts is the amount of incorrect pins after which the email change is cancelled.
+	maxEmailPinAttempts = 5
+)
+
+var (
+	ErrNoEmailChange      = errors.New("no email change")
+	ErrIncorrectPin       = errors.New("incorrect pin")
+	ErrTooManyPinAttempts = errors.New("too many pin attempts")
+	ErrResendCooldown     = errors.New("resend cooldown")
+)
+
+// emailChange is an email a user wants to use, it is only applied once the pin sent to it is entered.
+type emailChange struct {
+	Email string `json:"email"`
+	Pin   string `json:"pin"`
+}
+
+// EmailVerification keeps the email changes waiting to be verified.
+type EmailVerification struct {
+	rdb *redis.Client
+}
+
+func NewEmailVerification(rdb *redis.Client) *EmailVerification {
+	return &EmailVerification{rdb: rdb}
+}
+
+// Start replaces the pending email change of the user and returns the pin to send to the email.
+func (v *EmailVerification) Start(user int, email string) (string, error) {
+	ctx := v.rdb.Context()
+
+	ok, err := v.rdb.SetNX(ctx, cooldownKey(user), true, emailResendCooldown).Result()
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", ErrResendCooldown
+	}
+
+	pin, err := pins.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(&emailChange{Email: email, Pin: pin})
+	if err != nil {
+		return "", err
+	}
+
+	// the attempts of a previous change do not count against the new pin.
+	pipe := v.rdb.TxPipeline()
+	pipe.Set(ctx, emailChangeKey(user), data, emailPinExpiration)
+	pipe.Del(ctx, attemptsKey(user))
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return pin, nil
+}
+
+// Verify returns the email once the correct pin is entered, the change is cancelled after too many incorrect pins.
+func (v *EmailVerification) Verify(user int, pin string) (string, error) {
+	ctx := v.rdb.Context()
+	key := emailChangeKey(user)
+
+	data, err := v.rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNoEmailChange
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	change := &emailChange{}
+	err = json.Unmarshal([]byte(data), change)
+	if err != nil {
+		return "", err
+	}
+
+	if change.Pin == pin {
+		return change.Email, v.rdb.Del(ctx, key, attemptsKey(user)).Err()
+	}
+
+	pipe := v.rdb.TxPipeline()
+	attempts := pipe.Incr(ctx, attemptsKey(user))
+	pipe.Expire(ctx, attemptsKey(user), emailPinExpiration)
+
+	_, err = pipe.Exec(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if attempts.Val() >= maxEmailPinAttempts {
+		err = v.rdb.Del(ctx, key, attemptsKey(user)).Err()
+		if err != nil {
+			return "", err
+		}
+
+		return "", ErrTooManyPinAttempts
+	}
+
+	return "", ErrIncorrectPin
+}
+
+func emailChangeKey(user int) string {
+	return fmt.Sprintf("email_change_%d", user)
+}
+
+func attemptsKey(user int) string {
+	return fmt.Sprintf("email_change_attempts_%d", user)
+}
+
+func cooldownKey(user int) string {
+	return fmt.Sprintf("email_change_cooldown_%d", user)
+}
@@ -0,0 +1,96 @@
+package account_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/account"
+)
+
+func TestEmailVerification_TooManyPinAttempts(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	verification := account.NewEmailVerification(rdb)
+
+	pin, err := verification.Start(1, "foo@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := "abcdef"
+
+	for i := 0; i < 4; i++ {
+		_, err = verification.Verify(1, wrong)
+		if err != account.ErrIncorrectPin {
+			t.Fatalf("expected %v actual %v", account.ErrIncorrectPin, err)
+		}
+	}
+
+	_, err = verification.Verify(1, wrong)
+	if err != account.ErrTooManyPinAttempts {
+		t.Fatalf("expected %v actual %v", account.ErrTooManyPinAttempts, err)
+	}
+
+	// the change is cancelled, so the correct pin no longer works.
+	_, err = verification.Verify(1, pin)
+	if err != account.ErrNoEmailChange {
+		t.Fatalf("expected %v actual %v", account.ErrNoEmailChange, err)
+	}
+}
+
+func TestEmailVerification_StartResetsPinAttempts(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	verification := account.NewEmailVerification(rdb)
+
+	_, err = verification.Start(1, "foo@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		_, err = verification.Verify(1, "abcdef")
+		if err != account.ErrIncorrectPin {
+			t.Fatalf("expected %v actual %v", account.ErrIncorrectPin, err)
+		}
+	}
+
+	// requesting another code is possible once the cooldown passed.
+	mr.FastForward(2 * time.Minute)
+
+	pin, err := verification.Start(1, "foo@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = verification.Verify(1, "abcdef")
+	if err != account.ErrIncorrectPin {
+		t.Fatalf("expected %v actual %v", account.ErrIncorrectPin, err)
+	}
+
+	email, err := verification.Verify(1, pin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if email != "foo@example.com" {
+		t.Fatalf("expected foo@example.com actual %s", email)
+	}
+}
@@ -3,25 +3,47 @@ package account
 import (
 	"log"
 	"net/http"
+	netmail "net/mail"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/soapboxsocial/soapbox/pkg/exports"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/mail"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
+	"github.com/soapboxsocial/soapbox/pkg/users"
 )
 
 type Endpoint struct {
-	backend  *Backend
-	queue    *pubsub.Queue
-	sessions *sessions.SessionManager
+	backend      *Backend
+	users        *users.Backend
+	verification *EmailVerification
+	exports      *exports.Queue
+	mail         *mail.Service
+	queue        *pubsub.Queue
+	sessions     *sessions.SessionManager
 }
 
-func NewEndpoint(backend *Backend, queue *pubsub.Queue, sessions *sessions.SessionManager) *Endpoint {
+func NewEndpoint(
+	backend *Backend,
+	ub *users.Backend,
+	verification *EmailVerification,
+	exports *exports.Queue,
+	mail *mail.Service,
+	queue *pubsub.Queue,
+	sessions *sessions.SessionManager,
+) *Endpoint {
 	return &Endpoint{
-		backend:  backend,
-		queue:    queue,
-		sessions: sessions,
+		backend:      backend,
+		users:        ub,
+		verification: verification,
+		exports:      exports,
+		mail:         mail,
+		queue:        queue,
+		sessions:     sessions,
 	}
 }
 
@@ -29,10 +51,14 @@ func (e *Endpoint) Router() *mux.Router {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/", e.delete).Methods("DELETE")
+	r.HandleFunc("/email", e.changeEmail).Methods("POST")
+	r.HandleFunc("/email/verify", e.verifyEmail).Methods("POST")
+	r.HandleFunc("/export", e.export).Methods("POST")
 
 	return r
 }
 
+// delete schedules the account to be purged once the grace period ends, logging in before restores it.
 func (e *Endpoint) delete(w http.ResponseWriter, r *http.Request) {
 	id, ok := httputil.GetUserIDFromContext(r.Context())
 	if !ok {
@@ -40,24 +66,208 @@ func (e *Endpoint) delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := e.backend.DeleteAccount(id)
+	err := e.backend.ScheduleDeletion(id, time.Now().Add(DeletionGracePeriod))
 	if err != nil {
-		log.Printf("backend.DeleteAccount err: %s", err)
+		log.Printf("backend.ScheduleDeletion err: %s", err)
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeNotFound, "failed to delete")
 		return
 	}
 
-	log.Printf("deleted user %d", id)
+	log.Printf("scheduled deletion of user %d", id)
 
-	err = e.queue.Publish(pubsub.UserTopic, pubsub.NewDeleteUserEvent(id))
+	err = e.sessions.CloseAllSessions(id)
 	if err != nil {
-		log.Printf("failed to write delete event: %v", err)
+		log.Printf("failed to close sessions: %v", err)
 	}
 
-	err = e.sessions.CloseSession(r.Header.Get("Authorization"))
+	// the search index drops users that are scheduled for deletion when they are updated.
+	err = e.queue.Publish(pubsub.UserTopic, pubsub.NewUserUpdateEvent(id))
 	if err != nil {
-		log.Printf("failed to close session: %v", err)
+		log.Printf("queue.Publish err: %v\n", err)
 	}
 
 	httputil.JsonSuccess(w)
 }
+
+// changeEmail sends a pin to the new email, the email is only changed once it is verified.
+// Users of login providers can verify their current email to log in with it.
+func (e *Endpoint) changeEmail(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(r.Form.Get("email")))
+	if !validateEmail(email) {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidEmail, "invalid email")
+		return
+	}
+
+	user, err := e.users.FindByID(id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToGetUser, "")
+		return
+	}
+
+	if user.Email != nil && *user.Email == email {
+		hasEmailLogin, err := e.backend.HasEmailLogin(id)
+		if err != nil {
+			log.Printf("backend.HasEmailLogin err: %v\n", err)
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+			return
+		}
+
+		if hasEmailLogin {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidEmail, "email unchanged")
+			return
+		}
+	} else {
+		isRegistered, err := e.users.IsRegistered(email)
+		if err != nil {
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+			return
+		}
+
+		if isRegistered {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeEmailAlreadyExists, "email already exists")
+			return
+		}
+	}
+
+	pin, err := e.verification.Start(id, email)
+	if err == ErrResendCooldown {
+		httputil.JsonRetryError(w, http.StatusTooManyRequests, httputil.ErrorCodeResendCooldown, "wait before requesting another code", emailResendCooldown)
+		return
+	}
+
+	if err != nil {
+		log.Printf("verification.Start err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	err = e.mail.SendPinEmail(email, pin)
+	if err != nil {
+		log.Printf("failed to send code: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to send code")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (e *Endpoint) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	user, err := e.users.FindByID(id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToGetUser, "")
+		return
+	}
+
+	email, err := e.verification.Verify(id, r.Form.Get("pin"))
+	switch err {
+	case nil:
+	case ErrNoEmailChange:
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "no email change")
+		return
+	case ErrIncorrectPin:
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeIncorrectPin, "")
+		return
+	case ErrTooManyPinAttempts:
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeTooManyPinAttempts, "too many attempts, request a new code")
+		return
+	default:
+		log.Printf("verification.Verify err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	err = e.backend.ChangeEmail(id, email)
+	if err != nil {
+		if strings.Contains(err.Error(), "idx_email") {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeEmailAlreadyExists, "email already exists")
+			return
+		}
+
+		log.Printf("backend.ChangeEmail err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to change email")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+
+	// security alerts go to the previous email, so the owner notices if the account was taken over.
+	if user.Email != nil && *user.Email != "" {
+		err = e.queue.Publish(pubsub.UserTopic, pubsub.NewEmailChangedEvent(id, *user.Email, email))
+		if err != nil {
+			log.Printf("queue.Publish err: %v\n", err)
+		}
+	}
+
+	err = e.queue.Publish(pubsub.UserTopic, pubsub.NewUserUpdateEvent(id))
+	if err != nil {
+		log.Printf("queue.Publish err: %v\n", err)
+	}
+}
+
+// export queues an export of the users data, the link to download it is emailed once it is ready.
+// Users without an email, like those logging in with their phone, must add one first.
+func (e *Endpoint) export(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	user, err := e.users.FindByID(id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToGetUser, "")
+		return
+	}
+
+	if user.Email == nil || *user.Email == "" {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeMissingEmail, "an email is required to send the export to")
+		return
+	}
+
+	retry, err := e.exports.Request(id)
+	if err == exports.ErrRequestCooldown {
+		httputil.JsonRetryError(w, http.StatusTooManyRequests, httputil.ErrorCodeTooManyRequests, "export requested recently", retry)
+		return
+	}
+
+	if err != nil {
+		log.Printf("exports.Request err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to request export")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func validateEmail(email string) bool {
+	address, err := netmail.ParseAddress(email)
+	if err != nil {
+		return false
+	}
+
+	return address.Address == email && len(email) < 254
+}
@@ -1,6 +1,7 @@
 package account_test
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -8,16 +9,21 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/golang/mock/gomock"
+	"github.com/sendgrid/sendgrid-go"
 
 	"github.com/soapboxsocial/soapbox/pkg/account"
+	"github.com/soapboxsocial/soapbox/pkg/exports"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/mail"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
+	"github.com/soapboxsocial/soapbox/pkg/users"
 )
 
 func TestMain(m *testing.M) {
@@ -48,6 +54,10 @@ func TestAccountEndpoint_Delete(t *testing.T) {
 
 	endpoint := account.NewEndpoint(
 		account.NewBackend(db),
+		users.NewBackend(db),
+		account.NewEmailVerification(rdb),
+		exports.NewQueue(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
 		pubsub.NewQueue(rdb),
 		sm,
 	)
@@ -58,7 +68,13 @@ func TestAccountEndpoint_Delete(t *testing.T) {
 	session := "1234"
 	userID := 1
 
-	err = sm.NewSession(session, userID, 0)
+	_, err = sm.NewSession(session, userID, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := "5678"
+	_, err = sm.NewSession(other, userID, sessions.Metadata{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -71,10 +87,17 @@ func TestAccountEndpoint_Delete(t *testing.T) {
 	req := r.WithContext(httputil.WithUserID(r.Context(), userID))
 	req.Header.Set("Authorization", session)
 
-	smock.ExpectPrepare("^DELETE (.+)").
-		ExpectExec().
+	smock.ExpectBegin()
+	smock.ExpectExec("^INSERT INTO account_deletions (.+)").
+		WithArgs(userID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	smock.ExpectExec("^DELETE FROM devices (.+)").
 		WithArgs(userID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	smock.ExpectExec("^DELETE FROM webpush_subscriptions (.+)").
+		WithArgs(userID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	smock.ExpectCommit()
 
 	handler.ServeHTTP(rr, req)
 
@@ -82,4 +105,259 @@ func TestAccountEndpoint_Delete(t *testing.T) {
 		print(rr.Body.String())
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
+
+	for _, token := range []string{session, other} {
+		_, err = sm.GetUserIDForSession(token)
+		if err == nil {
+			t.Fatalf("expected session %s to be closed", token)
+		}
+	}
+
+	err = smock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sendGridStub records the emails sent through sendgrid.
+type sendGridStub struct {
+	server *httptest.Server
+	emails []map[string]interface{}
+}
+
+func newSendGridStub(t *testing.T) *sendGridStub {
+	stub := &sendGridStub{}
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email := make(map[string]interface{})
+		_ = json.NewDecoder(r.Body).Decode(&email)
+		stub.emails = append(stub.emails, email)
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+func (s *sendGridStub) client() *sendgrid.Client {
+	client := sendgrid.NewSendClient("key")
+	client.BaseURL = s.server.URL
+	return client
+}
+
+func recipient(email map[string]interface{}) string {
+	personalization := email["personalizations"].([]interface{})[0].(map[string]interface{})
+	return personalization["to"].([]interface{})[0].(map[string]interface{})["email"].(string)
+}
+
+func TestAccountEndpoint_ChangeEmail(t *testing.T) {
+	db, smock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	stub := newSendGridStub(t)
+
+	endpoint := account.NewEndpoint(
+		account.NewBackend(db),
+		users.NewBackend(db),
+		account.NewEmailVerification(rdb),
+		exports.NewQueue(rdb),
+		mail.NewMailService(stub.client()),
+		pubsub.NewQueue(rdb),
+		sessions.NewSessionManager(rdb),
+	)
+
+	handler := endpoint.Router()
+	userID := 1
+
+	request := func(path, body string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest("POST", path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := r.WithContext(httputil.WithUserID(r.Context(), userID))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	expectUser := func() {
+		smock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+			WithArgs(userID).
+			WillReturnRows(smock.NewRows([]string{"id", "display_name", "username", "image", "bio", "email"}).FromCSVString("1,foo,foo,,,old@example.com"))
+	}
+
+	expectUser()
+	smock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs("new@example.com").
+		WillReturnRows(smock.NewRows([]string{"count"}).FromCSVString("0"))
+
+	rr := request("/email", "email=New@example.com")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	if len(stub.emails) != 1 || recipient(stub.emails[0]) != "new@example.com" {
+		t.Fatalf("expected pin to be sent to the new email, sent %v", stub.emails)
+	}
+
+	personalization := stub.emails[0]["personalizations"].([]interface{})[0].(map[string]interface{})
+	pin := personalization["dynamic_template_data"].(map[string]interface{})["pin"].(string)
+
+	expectUser()
+	rr = request("/email/verify", "pin=wrong")
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	expectUser()
+	smock.ExpectBegin()
+	smock.ExpectExec("^UPDATE users (.+)").WithArgs("new@example.com", userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	smock.ExpectExec("^INSERT INTO email_logins (.+)").WithArgs(userID).WillReturnResult(sqlmock.NewResult(0, 1))
+	smock.ExpectCommit()
+
+	events := pubsub.NewQueue(rdb).Subscribe(pubsub.UserTopic)
+
+	rr = request("/email/verify", "pin="+pin)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	// security alerts are sent by the notifications worker.
+	select {
+	case event := <-events:
+		if event.Type != pubsub.EventTypeEmailChanged || event.Params["previous"] != "old@example.com" || event.Params["email"] != "new@example.com" {
+			t.Fatalf("unexpected event %v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected email changed event")
+	}
+
+	if len(stub.emails) != 1 {
+		t.Fatalf("expected no alert to be sent directly, sent %v", stub.emails)
+	}
+
+	err = smock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccountEndpoint_ChangeEmailAlreadyExists(t *testing.T) {
+	db, smock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	endpoint := account.NewEndpoint(
+		account.NewBackend(db),
+		users.NewBackend(db),
+		account.NewEmailVerification(rdb),
+		exports.NewQueue(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
+		pubsub.NewQueue(rdb),
+		sessions.NewSessionManager(rdb),
+	)
+
+	smock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WillReturnRows(smock.NewRows([]string{"id", "display_name", "username", "image", "bio", "email"}).FromCSVString("1,foo,foo,,,old@example.com"))
+
+	smock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs("new@example.com").
+		WillReturnRows(smock.NewRows([]string{"count"}).FromCSVString("1"))
+
+	r, err := http.NewRequest("POST", "/email", strings.NewReader("email=new@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	endpoint.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAccountEndpoint_ExportWithoutEmail(t *testing.T) {
+	db, smock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	endpoint := account.NewEndpoint(
+		account.NewBackend(db),
+		users.NewBackend(db),
+		account.NewEmailVerification(rdb),
+		exports.NewQueue(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
+		pubsub.NewQueue(rdb),
+		sessions.NewSessionManager(rdb),
+	)
+
+	// users logging in with their phone have no email to send the export to.
+	smock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(smock.NewRows([]string{"id", "display_name", "username", "image", "bio", "email"}).AddRow(1, "foo", "foo", "", "", nil))
+
+	r, err := http.NewRequest("POST", "/export", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+
+	rr := httptest.NewRecorder()
+	endpoint.Router().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+
+	if len(mr.Keys()) != 0 {
+		t.Fatalf("expected no export to be queued, found %v", mr.Keys())
+	}
+
+	err = smock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,116 @@
+package account
+
+import (
+	"log"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/images"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/stories"
+)
+
+// DeletionGracePeriod is how long a deleted account can be restored by logging in.
+const DeletionGracePeriod = 30 * 24 * time.Hour
+
+// Purger permanently deletes accounts once their grace period ended.
+type Purger struct {
+	backend *Backend
+	images  *images.Backend
+	stories *stories.FileBackend
+	limiter *notifications.Limiter
+	digests *notifications.Digests
+	queue   *pubsub.Queue
+}
+
+func NewPurger(
+	backend *Backend,
+	images *images.Backend,
+	stories *stories.FileBackend,
+	limiter *notifications.Limiter,
+	digests *notifications.Digests,
+	queue *pubsub.Queue,
+) *Purger {
+	return &Purger{
+		backend: backend,
+		images:  images,
+		stories: stories,
+		limiter: limiter,
+		digests: digests,
+		queue:   queue,
+	}
+}
+
+// Purge deletes all accounts that are due, it returns the amount of deleted accounts.
+func (p *Purger) Purge(now time.Time) (int, error) {
+	ids, err := p.backend.DueDeletions(now)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		deleted, err := p.purge(id)
+		if err != nil {
+			log.Printf("failed to purge %d err: %v\n", id, err)
+			continue
+		}
+
+		if deleted {
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// purge deletes an account and its data, it returns false if the account was restored in the meantime.
+func (p *Purger) purge(id int) (bool, error) {
+	image, ids, err := p.backend.Files(id)
+	if err != nil {
+		return false, err
+	}
+
+	deleted, err := p.backend.DeleteAccount(id)
+	if err != nil {
+		return false, err
+	}
+
+	// files and limits of restored accounts are still in use.
+	if !deleted {
+		return false, nil
+	}
+
+	// the account is gone, remaining cleanup failures only leave orphaned data.
+	if image != "" {
+		err = p.images.Remove(image)
+		if err != nil {
+			log.Printf("images.Remove err: %v\n", err)
+		}
+	}
+
+	for _, story := range ids {
+		err = p.stories.Remove(story + ".aac")
+		if err != nil {
+			log.Printf("stories.Remove err: %v\n", err)
+		}
+	}
+
+	err = p.limiter.Reset(id)
+	if err != nil {
+		log.Printf("limiter.Reset err: %v\n", err)
+	}
+
+	err = p.digests.Remove(id)
+	if err != nil {
+		log.Printf("digests.Remove err: %v\n", err)
+	}
+
+	// the search index and analytics remove the user once they are deleted.
+	err = p.queue.Publish(pubsub.UserTopic, pubsub.NewDeleteUserEvent(id))
+	if err != nil {
+		log.Printf("queue.Publish err: %v\n", err)
+	}
+
+	return true, nil
+}
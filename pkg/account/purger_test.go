@@ -0,0 +1,203 @@
+package account_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/account"
+	"github.com/soapboxsocial/soapbox/pkg/images"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/stories"
+)
+
+func TestPurger_Purge(t *testing.T) {
+	db, smock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	imagesPath := t.TempDir()
+	storiesPath := t.TempDir()
+
+	files := []string{filepath.Join(imagesPath, "foo.png"), filepath.Join(storiesPath, "1.aac")}
+	for _, f := range files {
+		err = ioutil.WriteFile(f, []byte("data"), os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys := []string{"notifications_limit_1_trending_room", "notifications_limit_1_room_123"}
+	for _, key := range keys {
+		err = mr.Set(key, "placeholder")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// limits of other users are kept.
+	err = mr.Set("notifications_limit_12_trending_room", "placeholder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purger := account.NewPurger(
+		account.NewBackend(db),
+		images.NewImagesBackend(imagesPath),
+		stories.NewFileBackend(storiesPath),
+		notifications.NewLimiter(rdb, nil),
+		notifications.NewDigests(rdb),
+		pubsub.NewQueue(rdb),
+	)
+
+	now := time.Now()
+
+	smock.ExpectPrepare("^SELECT user_id FROM account_deletions (.+)").ExpectQuery().
+		WithArgs(now).
+		WillReturnRows(smock.NewRows([]string{"user_id"}).AddRow(1))
+
+	smock.ExpectPrepare("^SELECT image FROM users (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(smock.NewRows([]string{"image"}).AddRow("foo.png"))
+
+	smock.ExpectPrepare("^SELECT id FROM stories (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(smock.NewRows([]string{"id"}).AddRow("1"))
+
+	smock.ExpectBegin()
+	smock.ExpectExec("^DELETE FROM users (.+) account_deletions (.+)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	smock.ExpectCommit()
+
+	purged, err := purger.Purge(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purged != 1 {
+		t.Fatalf("expected 1 purged account actual %d", purged)
+	}
+
+	for _, f := range files {
+		_, err := os.Stat(f)
+		if !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed", f)
+		}
+	}
+
+	for _, key := range keys {
+		if mr.Exists(key) {
+			t.Fatalf("expected %s to be removed", key)
+		}
+	}
+
+	if !mr.Exists("notifications_limit_12_trending_room") {
+		t.Fatal("expected limits of other users to be kept")
+	}
+
+	err = smock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPurger_PurgeSkipsRestoredAccounts(t *testing.T) {
+	db, smock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	imagesPath := t.TempDir()
+	image := filepath.Join(imagesPath, "foo.png")
+
+	err = ioutil.WriteFile(image, []byte("data"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mr.Set("notifications_limit_1_trending_room", "placeholder")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purger := account.NewPurger(
+		account.NewBackend(db),
+		images.NewImagesBackend(imagesPath),
+		stories.NewFileBackend(t.TempDir()),
+		notifications.NewLimiter(rdb, nil),
+		notifications.NewDigests(rdb),
+		pubsub.NewQueue(rdb),
+	)
+
+	now := time.Now()
+
+	smock.ExpectPrepare("^SELECT user_id FROM account_deletions (.+)").ExpectQuery().
+		WithArgs(now).
+		WillReturnRows(smock.NewRows([]string{"user_id"}).AddRow(1))
+
+	smock.ExpectPrepare("^SELECT image FROM users (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(smock.NewRows([]string{"image"}).AddRow("foo.png"))
+
+	smock.ExpectPrepare("^SELECT id FROM stories (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(smock.NewRows([]string{"id"}))
+
+	// the user logged in after the due deletions were read.
+	smock.ExpectBegin()
+	smock.ExpectExec("^DELETE FROM users (.+) account_deletions (.+)").
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	smock.ExpectCommit()
+
+	purged, err := purger.Purge(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purged != 0 {
+		t.Fatalf("expected no purged accounts actual %d", purged)
+	}
+
+	if _, err := os.Stat(image); err != nil {
+		t.Fatalf("expected %s to be kept", image)
+	}
+
+	if !mr.Exists("notifications_limit_1_trending_room") {
+		t.Fatal("expected limits to be kept")
+	}
+
+	err = smock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@ package analytics
 
 import (
 	"database/sql"
+	"time"
 )
 
 type Backend struct {
@@ -13,12 +14,12 @@ func NewBackend(db *sql.DB) *Backend {
 }
 
 func (b *Backend) AddSentNotification(user int, notification Notification) error {
-	stmt, err := b.db.Prepare("INSERT INTO notification_analytics (id, target, origin, category, sent, room) VALUES($1, $2, $3, $4, NOW(), $5);")
+	stmt, err := b.db.Prepare("INSERT INTO notification_analytics (id, target, origin, category, sent, room, campaign, handler, variant) VALUES($1, $2, $3, $4, NOW(), $5, $6, NULLIF($7, ''), NULLIF($8, ''));")
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(notification.ID, user, notification.Origin, notification.Category, notification.Room)
+	_, err = stmt.Exec(notification.ID, user, notification.Origin, notification.Category, notification.Room, notification.Campaign, notification.Handler, notification.Variant)
 	return err
 }
 
@@ -31,3 +32,14 @@ func (b *Backend) MarkNotificationRead(user int, uuid string) error {
 	_, err = stmt.Exec(user, uuid)
 	return err
 }
+
+// MarkConverted records that the user returned to the app after notifications of a category sent within the window.
+func (b *Backend) MarkConverted(user int, category string, window time.Duration) error {
+	stmt, err := b.db.Prepare("UPDATE notification_analytics SET converted = NOW() WHERE target = $1 AND category = $2 AND converted IS NULL AND sent > NOW() - make_interval(secs => $3);")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user, category, window.Seconds())
+	return err
+}
@@ -3,19 +3,24 @@ package analytics
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 )
 
 type Endpoint struct {
-	backend *Backend
+	backend     *Backend
+	permissions *middlewares.PermissionMiddleware
 }
 
-func NewEndpoint(backend *Backend) *Endpoint {
+func NewEndpoint(backend *Backend, permissions *middlewares.PermissionMiddleware) *Endpoint {
 	return &Endpoint{
-		backend: backend,
+		backend:     backend,
+		permissions: permissions,
 	}
 }
 
@@ -24,6 +29,9 @@ func (e *Endpoint) Router() *mux.Router {
 
 	r.HandleFunc("/notifications/{id}/opened", e.openedNotification).Methods("POST")
 
+	admin := e.permissions.Require(roles.PermissionAdmin)
+	r.Path("/notifications/stats").Methods("GET").Handler(admin(http.HandlerFunc(e.notificationStats)))
+
 	return r
 }
 
@@ -51,3 +59,45 @@ func (e *Endpoint) openedNotification(w http.ResponseWriter, r *http.Request) {
 
 	httputil.JsonSuccess(w)
 }
+
+func (e *Endpoint) notificationStats(w http.ResponseWriter, r *http.Request) {
+	type stat struct {
+		Group     string  `json:"group"`
+		Sent      int     `json:"sent"`
+		Opened    int     `json:"opened"`
+		OpenRate  float64 `json:"open_rate"`
+		Converted int     `json:"converted"`
+	}
+
+	query := r.URL.Query()
+
+	dimension := Dimension(query.Get("by"))
+	if dimension == "" {
+		dimension = DimensionCategory
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Duration(httputil.GetInt(query, "hours", 24*7)) * time.Hour)
+
+	stats, err := e.backend.Stats(dimension, from, to)
+	if err == ErrUnknownDimension {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid dimension")
+		return
+	}
+
+	if err != nil {
+		log.Printf("backend.Stats err: %s", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to get stats")
+		return
+	}
+
+	res := make([]stat, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, stat{Group: s.Group, Sent: s.Sent, Opened: s.Opened, OpenRate: s.OpenRate(), Converted: s.Converted})
+	}
+
+	err = httputil.JsonEncode(w, res)
+	if err != nil {
+		log.Printf("failed to write stats response: %s", err)
+	}
+}
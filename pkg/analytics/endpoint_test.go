@@ -14,6 +14,8 @@ import (
 
 	"github.com/soapboxsocial/soapbox/pkg/analytics"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 )
 
 func TestMain(m *testing.M) {
@@ -30,6 +32,7 @@ func TestEndpoint_OpenedNotification(t *testing.T) {
 
 	endpoint := analytics.NewEndpoint(
 		analytics.NewBackend(db),
+		middlewares.NewPermissionMiddleware(roles.NewBackend(db)),
 	)
 
 	rr := httptest.NewRecorder()
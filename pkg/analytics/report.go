@@ -0,0 +1,116 @@
+package analytics
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Dimension is what notification stats are grouped by.
+type Dimension string
+
+const (
+	DimensionCategory Dimension = "category"
+	DimensionHour     Dimension = "hour"
+	DimensionHandler  Dimension = "handler"
+	DimensionVariant  Dimension = "variant"
+)
+
+// ErrUnknownDimension is returned when stats are requested for a dimension that does not exist.
+var ErrUnknownDimension = errors.New("unknown dimension")
+
+// dimensions maps every dimension to the column expression it groups by, only these are used in queries.
+var dimensions = map[Dimension]string{
+	DimensionCategory: "category",
+	DimensionHour:     "to_char(date_trunc('hour', sent AT TIME ZONE 'UTC'), 'YYYY-MM-DD HH24:00')",
+	DimensionHandler:  "COALESCE(handler, '')",
+	DimensionVariant:  "COALESCE(handler, '') || '/' || COALESCE(variant, '')",
+}
+
+// Stat is the amount of notifications sent, opened and converted for a group.
+type Stat struct {
+	Group  string
+	Sent   int
+	Opened int
+
+	// Converted are notifications after which the target returned to the app, only tracked for re-engagement.
+	Converted int
+}
+
+// OpenRate returns the share of sent notifications that were opened.
+func (s Stat) OpenRate() float64 {
+	if s.Sent == 0 {
+		return 0
+	}
+
+	return float64(s.Opened) / float64(s.Sent)
+}
+
+// Interval returns the 95% Wilson score interval of the open rate.
+func (s Stat) Interval() (float64, float64) {
+	if s.Sent == 0 {
+		return 0, 0
+	}
+
+	const z = 1.96
+
+	n := float64(s.Sent)
+	p := s.OpenRate()
+
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	margin := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// ZScore returns the two-proportion z-test statistic of the open rate compared to a control,
+// an absolute value above 1.96 means the difference is significant at 95% confidence.
+func (s Stat) ZScore(control Stat) float64 {
+	if s.Sent == 0 || control.Sent == 0 {
+		return 0
+	}
+
+	pooled := float64(s.Opened+control.Opened) / float64(s.Sent+control.Sent)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(s.Sent) + 1/float64(control.Sent)))
+	if se == 0 {
+		return 0
+	}
+
+	return (s.OpenRate() - control.OpenRate()) / se
+}
+
+// Stats returns the sent and open counts of notifications sent between from and to grouped by a dimension.
+func (b *Backend) Stats(dimension Dimension, from, to time.Time) ([]Stat, error) {
+	group, ok := dimensions[dimension]
+	if !ok {
+		return nil, ErrUnknownDimension
+	}
+
+	query := "SELECT " + group + " AS dimension, COUNT(*), COUNT(opened), COUNT(converted) FROM notification_analytics WHERE sent >= $1 AND sent < $2"
+	if dimension == DimensionVariant {
+		query += " AND variant IS NOT NULL"
+	}
+
+	stmt, err := b.db.Prepare(query + " GROUP BY dimension ORDER BY dimension;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]Stat, 0)
+	for rows.Next() {
+		stat := Stat{}
+		err := rows.Scan(&stat.Group, &stat.Sent, &stat.Opened, &stat.Converted)
+		if err != nil {
+			return nil, err
+		}
+
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,80 @@
+package analytics_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/analytics"
+)
+
+func TestBackend_Stats(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := analytics.NewBackend(db)
+
+	to := time.Now()
+	from := to.Add(-time.Hour)
+
+	mock.ExpectPrepare("^SELECT (.+) AND variant IS NOT NULL GROUP BY dimension").
+		ExpectQuery().
+		WithArgs(from, to).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"dimension", "sent", "opened", "converted"}).
+				AddRow("RoomJoin/control", 100, 10, 0).
+				AddRow("RoomJoin/short", 100, 20, 0),
+		)
+
+	stats, err := backend.Stats(analytics.DimensionVariant, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []analytics.Stat{{Group: "RoomJoin/control", Sent: 100, Opened: 10}, {Group: "RoomJoin/short", Sent: 100, Opened: 20}}
+	if !reflect.DeepEqual(stats, expected) {
+		t.Fatalf("expected %v actual %v", expected, stats)
+	}
+}
+
+func TestBackend_StatsWithUnknownDimension(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	_, err = analytics.NewBackend(db).Stats("target; DROP TABLE users", time.Now(), time.Now())
+	if err != analytics.ErrUnknownDimension {
+		t.Fatalf("expected %v actual %v", analytics.ErrUnknownDimension, err)
+	}
+}
+
+func TestStat(t *testing.T) {
+	control := analytics.Stat{Sent: 1000, Opened: 100}
+	variant := analytics.Stat{Sent: 1000, Opened: 150}
+
+	if variant.OpenRate() != 0.15 {
+		t.Fatalf("unexpected open rate %f", variant.OpenRate())
+	}
+
+	low, high := control.Interval()
+	if math.Abs(low-0.0829) > 0.001 || math.Abs(high-0.1202) > 0.001 {
+		t.Fatalf("unexpected interval %f - %f", low, high)
+	}
+
+	z := variant.ZScore(control)
+	if math.Abs(z-3.38) > 0.01 {
+		t.Fatalf("unexpected z score %f", z)
+	}
+
+	if (analytics.Stat{}).ZScore(control) != 0 {
+		t.Fatal("expected z score of 0 without notifications")
+	}
+}
@@ -5,4 +5,7 @@ type Notification struct {
 	Origin   *int
 	Category string
 	Room     *string
+	Campaign *int
+	Handler  string
+	Variant  string
 }
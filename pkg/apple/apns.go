@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/sideshow/apns2"
 
@@ -13,6 +14,9 @@ import (
 type APNS struct {
 	topic string
 
+	// images is the URL profile pictures are served from.
+	images string
+
 	client *apns2.Client
 
 	// maxConcurrentPushes limits the amount of notification pushes
@@ -20,16 +24,79 @@ type APNS struct {
 	maxConcurrentPushes chan struct{}
 }
 
-func NewAPNS(topic string, client *apns2.Client) *APNS {
+// aps is the dictionary iOS reads a notification from, it also contains our notification fields which the app reads.
+type aps struct {
+	Category          notifications.NotificationCategory `json:"category"`
+	Alert             interface{}                        `json:"alert,omitempty"`
+	Arguments         map[string]interface{}             `json:"arguments"`
+	UUID              string                             `json:"uuid"`
+	Badge             *int                               `json:"badge,omitempty"`
+	ThreadID          string                             `json:"thread-id,omitempty"`
+	InterruptionLevel notifications.InterruptionLevel    `json:"interruption-level,omitempty"`
+	RelevanceScore    float64                            `json:"relevance-score,omitempty"`
+	MutableContent    int                                `json:"mutable-content,omitempty"`
+	ContentAvailable  int                                `json:"content-available,omitempty"`
+}
+
+// renderedAlert is the alert of notifications rendered on the server, it has no key since the app does not ship it.
+type renderedAlert struct {
+	Body string `json:"body"`
+}
+
+type payload struct {
+	APS aps `json:"aps"`
+
+	// Image is downloaded by the notification service extension, which requires mutable content.
+	Image string `json:"image,omitempty"`
+}
+
+func NewAPNS(topic, images string, client *apns2.Client) *APNS {
 	return &APNS{
 		topic:               topic,
+		images:              images,
 		client:              client,
 		maxConcurrentPushes: make(chan struct{}, 100),
 	}
 }
 
+// Payload returns the JSON payload pushed for a notification.
+// Silent notifications are sent as background pushes without an alert or badge.
+func (a *APNS) Payload(notification notifications.PushNotification) ([]byte, error) {
+	p := payload{
+		APS: aps{
+			Category:  notification.Category,
+			Arguments: notification.Arguments,
+			UUID:      notification.UUID,
+		},
+	}
+
+	if notifications.IsSilent(notification.Category) {
+		p.APS.ContentAvailable = 1
+		return json.Marshal(p)
+	}
+
+	if notification.Rendered {
+		p.APS.Alert = &renderedAlert{Body: notification.Alert.Body}
+	} else {
+		alert := notification.Alert
+		p.APS.Alert = &alert
+	}
+
+	p.APS.Badge = notification.Badge
+	p.APS.ThreadID = notification.ThreadID()
+	p.APS.InterruptionLevel = notification.InterruptionLevel()
+	p.APS.RelevanceScore = notification.RelevanceScore()
+
+	if notification.Image != "" && a.images != "" {
+		p.APS.MutableContent = 1
+		p.Image = strings.TrimSuffix(a.images, "/") + "/" + notification.Image
+	}
+
+	return json.Marshal(p)
+}
+
 func (a *APNS) Send(target string, notification notifications.PushNotification) error {
-	data, err := json.Marshal(map[string]interface{}{"aps": notification})
+	data, err := a.Payload(notification)
 	if err != nil {
 		return err
 	}
@@ -39,6 +106,14 @@ func (a *APNS) Send(target string, notification notifications.PushNotification)
 		Topic:       a.topic,
 		Payload:     data,
 		CollapseID:  notification.CollapseID,
+		PushType:    apns2.PushTypeAlert,
+		Priority:    apns2.PriorityHigh,
+	}
+
+	// background pushes must be sent with low priority, otherwise they are rejected.
+	if notifications.IsSilent(notification.Category) {
+		payload.PushType = apns2.PushTypeBackground
+		payload.Priority = apns2.PriorityLow
 	}
 
 	a.maxConcurrentPushes <- struct{}{}
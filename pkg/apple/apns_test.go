@@ -0,0 +1,110 @@
+package apple_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/apple"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestAPNS_Payload(t *testing.T) {
+	a := apple.NewAPNS("com.example", "https://cdn.example.com/images/", nil)
+
+	badge := 2
+	notification := notifications.NewRoomNotification("123", "foo", 12)
+	notification.UUID = "uuid"
+	notification.Image = "foo.png"
+	notification.Badge = &badge
+
+	data, err := a.Payload(*notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"category":           "NEW_ROOM",
+			"alert":              map[string]interface{}{"loc-key": "new_room_notification", "loc-args": []interface{}{"foo"}},
+			"arguments":          map[string]interface{}{"id": "123", "creator": float64(12)},
+			"uuid":               "uuid",
+			"badge":              float64(2),
+			"thread-id":          "room_123",
+			"interruption-level": "time-sensitive",
+			"relevance-score":    0.8,
+			"mutable-content":    float64(1),
+		},
+		"image": "https://cdn.example.com/images/foo.png",
+	}
+
+	actual := make(map[string]interface{})
+	err = json.Unmarshal(data, &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v actual %v", expected, actual)
+	}
+}
+
+func TestAPNS_PayloadSilent(t *testing.T) {
+	a := apple.NewAPNS("com.example", "https://cdn.example.com/images", nil)
+
+	badge := 2
+	notification := notifications.NewFeedRefreshNotification()
+	notification.UUID = "uuid"
+	notification.Badge = &badge
+
+	data, err := a.Payload(*notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"category":          "FEED_REFRESH",
+			"arguments":         map[string]interface{}{},
+			"uuid":              "uuid",
+			"content-available": float64(1),
+		},
+	}
+
+	actual := make(map[string]interface{})
+	err = json.Unmarshal(data, &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v actual %v", expected, actual)
+	}
+}
+
+func TestAPNS_PayloadRendered(t *testing.T) {
+	a := apple.NewAPNS("com.example", "", nil)
+
+	notification := notifications.NewRoomJoinedNotification("123", 12, "", []string{"foo"}, 1)
+	notification.UUID = "uuid"
+	notification.Alert.Key = "join_room_with_1_short_notification"
+	notification.Alert.Body = "foo is live, tap to listen in"
+	notification.Rendered = true
+
+	data, err := a.Payload(*notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := make(map[string]map[string]interface{})
+	err = json.Unmarshal(data, &actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// apps that do not ship the key would show it instead of the alert.
+	expected := map[string]interface{}{"body": "foo is live, tap to listen in"}
+	if !reflect.DeepEqual(actual["aps"]["alert"], expected) {
+		t.Fatalf("expected %v actual %v", expected, actual["aps"]["alert"])
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/Timothylock/go-signin-with-apple/apple"
 )
 
+// Provider is the name of apple as a login provider.
+const Provider = "apple"
+
 // UserInfo contains the apple ID user info
 type UserInfo struct {
 	ID    string
@@ -15,6 +15,11 @@ type AppleConf struct {
 	Bundle string `mapstructure:"bundle"`
 }
 
+// GoogleConf describes a configuration for google sign in, the client IDs are those of our apps.
+type GoogleConf struct {
+	ClientIDs []string `mapstructure:"client-ids"`
+}
+
 // PostgresConf describes a default configuration for the postgres database.
 type PostgresConf struct {
 	Host     string `mapstructure:"host"`
@@ -34,6 +39,35 @@ type RedisConf struct {
 	DisableTLS bool   `mapstructure:"tls-disabled"`
 }
 
+// SMSConf describes a configuration for sending text messages, Twilio is used when an account is set,
+// otherwise messages are written to the log file.
+type SMSConf struct {
+	Account string `mapstructure:"account"`
+	Token   string `mapstructure:"token"`
+	From    string `mapstructure:"from"`
+	Log     string `mapstructure:"log"`
+}
+
+// VAPIDConf describes a configuration for web push VAPID keys.
+type VAPIDConf struct {
+	Subject    string `mapstructure:"subject"`
+	PublicKey  string `mapstructure:"public"`
+	PrivateKey string `mapstructure:"private"`
+}
+
+// EmailConf describes a configuration for sending emails, an SMTP server is used when set, otherwise SendGrid.
+type EmailConf struct {
+	From     string `mapstructure:"from"`
+	Name     string `mapstructure:"name"`
+	SMTP     string `mapstructure:"smtp"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	SendGrid string `mapstructure:"sendgrid"`
+
+	// Unsubscribe is the public URL of the unsubscribe endpoint.
+	Unsubscribe string `mapstructure:"unsubscribe"`
+}
+
 // AddrConf describes a default configuration for host addresses.
 type AddrConf struct {
 	Host string `mapstructure:"host"`
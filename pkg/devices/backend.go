@@ -56,9 +56,9 @@ func (db *Backend) GetDevicesForUser(id int) ([]string, error) {
 	return result, nil
 }
 
-func (db *Backend) GetDevicesForUsers(ids []int) ([]string, error) {
+func (db *Backend) GetDevicesForUsers(ids []int) ([]Device, error) {
 	query := fmt.Sprintf(
-		"SELECT token FROM devices WHERE user_id IN (%s);",
+		"SELECT token, user_id FROM devices WHERE user_id IN (%s) AND user_id NOT IN (SELECT user_id FROM account_deletions);",
 		join(ids, ","),
 	)
 
@@ -72,11 +72,11 @@ func (db *Backend) GetDevicesForUsers(ids []int) ([]string, error) {
 		return nil, err
 	}
 
-	result := make([]string, 0)
+	result := make([]Device, 0)
 
 	for rows.Next() {
-		var device string
-		err := rows.Scan(&device)
+		device := Device{}
+		err := rows.Scan(&device.Token, &device.UserID)
 		if err != nil {
 			return nil, err
 		}
@@ -101,6 +101,65 @@ func (db *Backend) RemoveDevice(token string) error {
 	return nil
 }
 
+func (db *Backend) AddWebPushSubscriptionForUser(id int, subscription WebPushSubscription) error {
+	stmt, err := db.db.Prepare("INSERT INTO webpush_subscriptions (endpoint, user_id, p256dh, auth) VALUES ($1, $2, $3, $4) ON CONFLICT (endpoint) DO UPDATE SET user_id = $2, p256dh = $3, auth = $4;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(subscription.Endpoint, id, subscription.P256dh, subscription.Auth)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (db *Backend) GetWebPushSubscriptionsForUsers(ids []int) ([]WebPushSubscription, error) {
+	query := fmt.Sprintf(
+		"SELECT endpoint, p256dh, auth, user_id FROM webpush_subscriptions WHERE user_id IN (%s) AND user_id NOT IN (SELECT user_id FROM account_deletions);",
+		join(ids, ","),
+	)
+
+	stmt, err := db.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]WebPushSubscription, 0)
+
+	for rows.Next() {
+		subscription := WebPushSubscription{}
+		err := rows.Scan(&subscription.Endpoint, &subscription.P256dh, &subscription.Auth, &subscription.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, subscription)
+	}
+
+	return result, nil
+}
+
+func (db *Backend) RemoveWebPushSubscription(endpoint string) error {
+	stmt, err := db.db.Prepare("DELETE FROM webpush_subscriptions WHERE endpoint = $1;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(endpoint)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func join(elems []int, sep string) string {
 	switch len(elems) {
 	case 0:
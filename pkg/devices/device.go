@@ -0,0 +1,13 @@
+package devices
+
+// Device is an iOS device notifications are pushed to with APNS.
+type Device struct {
+	Token  string
+	UserID int
+
+	// Badge is the unread count shown on the app icon, it is left unchanged when nil.
+	Badge *int
+
+	// Locale is the locale rendered alerts are pushed in.
+	Locale string
+}
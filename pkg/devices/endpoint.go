@@ -1,6 +1,7 @@
 package devices
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -10,11 +11,15 @@ import (
 
 type Endpoint struct {
 	db *Backend
+
+	// vapidKey is the public application server key used by browsers to subscribe.
+	vapidKey string
 }
 
-func NewEndpoint(db *Backend) *Endpoint {
+func NewEndpoint(db *Backend, vapidKey string) *Endpoint {
 	return &Endpoint{
-		db: db,
+		db:       db,
+		vapidKey: vapidKey,
 	}
 }
 
@@ -22,6 +27,8 @@ func (d *Endpoint) Router() *mux.Router {
 	r := mux.NewRouter()
 
 	r.HandleFunc("/add", d.add).Methods("POST")
+	r.HandleFunc("/webpush", d.addWebPush).Methods("POST")
+	r.HandleFunc("/webpush/key", d.webPushKey).Methods("GET")
 
 	return r
 }
@@ -53,3 +60,49 @@ func (d *Endpoint) add(w http.ResponseWriter, r *http.Request) {
 
 	httputil.JsonSuccess(w)
 }
+
+func (d *Endpoint) addWebPush(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	subscription := WebPushSubscription{
+		Endpoint: r.Form.Get("endpoint"),
+		P256dh:   r.Form.Get("p256dh"),
+		Auth:     r.Form.Get("auth"),
+	}
+
+	err = subscription.Validate()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, err.Error())
+		return
+	}
+
+	userID, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = d.db.AddWebPushSubscriptionForUser(userID, subscription)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToStoreDevice, "failed")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (d *Endpoint) webPushKey(w http.ResponseWriter, _ *http.Request) {
+	if d.vapidKey == "" {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "web push disabled")
+		return
+	}
+
+	err := httputil.JsonEncode(w, map[string]string{"key": d.vapidKey})
+	if err != nil {
+		log.Printf("failed to encode: %v", err)
+	}
+}
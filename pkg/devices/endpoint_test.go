@@ -6,7 +6,9 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -41,7 +43,7 @@ func TestDevicesEndpoint_AddDevice(t *testing.T) {
 	}
 	defer db.Close()
 
-	endpoint := devices.NewEndpoint(devices.NewBackend(db))
+	endpoint := devices.NewEndpoint(devices.NewBackend(db), "")
 
 	mock.ExpectPrepare("^INSERT (.+)").ExpectExec().
 		WithArgs(token, session).
@@ -71,7 +73,7 @@ func TestDevicesEndpoint_AddDeviceFailsWithoutToken(t *testing.T) {
 	}
 	defer db.Close()
 
-	endpoint := devices.NewEndpoint(devices.NewBackend(db))
+	endpoint := devices.NewEndpoint(devices.NewBackend(db), "")
 
 	rr := httptest.NewRecorder()
 	handler := endpoint.Router()
@@ -103,7 +105,7 @@ func TestDevicesEndpoint_AddDeviceWithBackendError(t *testing.T) {
 	}
 	defer db.Close()
 
-	endpoint := devices.NewEndpoint(devices.NewBackend(db))
+	endpoint := devices.NewEndpoint(devices.NewBackend(db), "")
 
 	mock.ExpectPrepare("^INSERT (.+)").ExpectExec().
 		WithArgs(token, session).
@@ -131,7 +133,7 @@ func TestDevicesEndpoint_AddDeviceWithoutForm(t *testing.T) {
 	}
 	defer db.Close()
 
-	endpoint := devices.NewEndpoint(devices.NewBackend(db))
+	endpoint := devices.NewEndpoint(devices.NewBackend(db), "")
 	rr := httptest.NewRecorder()
 	handler := endpoint.Router()
 
@@ -141,3 +143,81 @@ func TestDevicesEndpoint_AddDeviceWithoutForm(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
 	}
 }
+
+func TestDevicesEndpoint_AddWebPush(t *testing.T) {
+	session := 123
+	endpoint := "https://push.example.com/send/abc"
+	p256dh := "BNcRdreALRFXTkOOUHK1EtK2wtaz5Ry4YfYCA_0QTpQtUbVlUls0VJXg7A8u-Ts1XbjhazAkj7I99e8QcYP7DkM"
+	auth := "tBHItJI5svbpez7KI4CCXg"
+
+	form := url.Values{}
+	form.Set("endpoint", endpoint)
+	form.Set("p256dh", p256dh)
+	form.Set("auth", auth)
+
+	r, err := http.NewRequest("POST", "/webpush", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), session))
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	e := devices.NewEndpoint(devices.NewBackend(db), "")
+
+	mock.ExpectPrepare("^INSERT (.+)").ExpectExec().
+		WithArgs(endpoint, session, p256dh, auth).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	rr := httptest.NewRecorder()
+	handler := e.Router()
+
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
+
+func TestDevicesEndpoint_AddWebPushFailsWithInvalidSubscription(t *testing.T) {
+	var tests = []url.Values{
+		{"endpoint": {"http://push.example.com/send/abc"}, "p256dh": {"BNcRdreALRFXTkOOUHK1EtK2wtaz5Ry4YfYCA_0QTpQtUbVlUls0VJXg7A8u-Ts1XbjhazAkj7I99e8QcYP7DkM"}, "auth": {"tBHItJI5svbpez7KI4CCXg"}},
+		{"endpoint": {"https://push.example.com/send/abc"}, "p256dh": {"foo"}, "auth": {"tBHItJI5svbpez7KI4CCXg"}},
+		{"endpoint": {"https://push.example.com/send/abc"}, "p256dh": {"BNcRdreALRFXTkOOUHK1EtK2wtaz5Ry4YfYCA_0QTpQtUbVlUls0VJXg7A8u-Ts1XbjhazAkj7I99e8QcYP7DkM"}, "auth": {""}},
+	}
+
+	for i, form := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/webpush", strings.NewReader(form.Encode()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			db, _, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			e := devices.NewEndpoint(devices.NewBackend(db), "")
+
+			rr := httptest.NewRecorder()
+			handler := e.Router()
+
+			handler.ServeHTTP(rr, req)
+
+			if status := rr.Code; status != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+			}
+		})
+	}
+}
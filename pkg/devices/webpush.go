@@ -0,0 +1,57 @@
+package devices
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var (
+	errInvalidEndpoint = errors.New("invalid endpoint")
+	errInvalidP256dh   = errors.New("invalid p256dh key")
+	errInvalidAuth     = errors.New("invalid auth secret")
+)
+
+// WebPushSubscription represents a browser push subscription as returned by `PushManager.subscribe`.
+type WebPushSubscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+
+	UserID int
+
+	// Locale is the locale notification text is rendered in, browsers cannot localize it themselves.
+	Locale string
+}
+
+// Keys returns the decoded user agent public key and authentication secret.
+func (s WebPushSubscription) Keys() ([]byte, []byte, error) {
+	p256dh, err := decodeKey(s.P256dh)
+	if err != nil || len(p256dh) != 65 || p256dh[0] != 0x04 {
+		return nil, nil, errInvalidP256dh
+	}
+
+	auth, err := decodeKey(s.Auth)
+	if err != nil || len(auth) != 16 {
+		return nil, nil, errInvalidAuth
+	}
+
+	return p256dh, auth, nil
+}
+
+// Validate ensures the subscription can be used to push notifications.
+func (s WebPushSubscription) Validate() error {
+	u, err := url.Parse(s.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errInvalidEndpoint
+	}
+
+	_, _, err = s.Keys()
+	return err
+}
+
+// decodeKey decodes base64url keys, browsers may or may not include padding.
+func decodeKey(key string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(key, "="))
+}
@@ -0,0 +1,155 @@
+package exports
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+)
+
+// section is a JSON file of the export and the query selecting it, queries return a single JSON value.
+type section struct {
+	name  string
+	query string
+}
+
+var sections = []section{
+	{
+		name:  "profile.json",
+		query: "SELECT row_to_json(t) FROM (SELECT id, display_name, username, email, phone, bio, image, joined FROM users WHERE id = $1) t;",
+	},
+	{
+		name:  "followers.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT users.id, users.username, users.display_name FROM followers INNER JOIN users ON followers.follower = users.id WHERE followers.user_id = $1 ORDER BY users.id) t;",
+	},
+	{
+		name:  "following.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT users.id, users.username, users.display_name FROM followers INNER JOIN users ON followers.user_id = users.id WHERE followers.follower = $1 ORDER BY users.id) t;",
+	},
+	{
+		name:  "blocks.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT users.id, users.username, users.display_name FROM blocks INNER JOIN users ON blocks.blocked = users.id WHERE blocks.user_id = $1 ORDER BY users.id) t;",
+	},
+	{
+		// tokens of linked accounts are credentials, they are not exported.
+		name:  "linked_accounts.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT provider, profile_id, username FROM linked_accounts WHERE user_id = $1) t;",
+	},
+	{
+		name:  "stories.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT id, expires_at, device_timestamp, (SELECT COALESCE(json_agg(r), '[]') FROM (SELECT user_id, reaction FROM story_reactions WHERE story_id = stories.id) r) AS reactions FROM stories WHERE user_id = $1 ORDER BY device_timestamp) t;",
+	},
+	{
+		name:  "story_reactions.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT story_id, reaction FROM story_reactions WHERE user_id = $1) t;",
+	},
+	{
+		name:  "rooms.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT room, join_time, left_time, visibility FROM user_room_logs WHERE user_id = $1 ORDER BY join_time) t;",
+	},
+	{
+		name:  "notifications.json",
+		query: "SELECT COALESCE(json_agg(t), '[]') FROM (SELECT id, from_id, category, alert, arguments, actors, created, read FROM notifications WHERE user_id = $1 ORDER BY id) t;",
+	},
+	{
+		name:  "notification_settings.json",
+		query: "SELECT row_to_json(t) FROM (SELECT room_frequency, follows, welcome_rooms, timezone, quiet_hours, quiet_hours_start, quiet_hours_end, locale, (SELECT COALESCE(json_agg(p), '[]') FROM (SELECT category, push, inbox, email FROM notification_preferences WHERE user_id = $1) p) AS preferences FROM notification_settings WHERE user_id = $1) t;",
+	},
+}
+
+// File is a file of an export.
+type File struct {
+	Name string
+	Data []byte
+}
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+// Sections returns the data of the user as JSON files.
+func (b *Backend) Sections(user int) ([]File, error) {
+	files := make([]File, 0, len(sections))
+	for _, s := range sections {
+		data, err := b.query(s.query, user)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, File{Name: s.name, Data: data})
+	}
+
+	return files, nil
+}
+
+// Media returns the profile image and the story IDs of the user, whose files are exported.
+func (b *Backend) Media(user int) (string, []string, error) {
+	stmt, err := b.db.Prepare("SELECT image FROM users WHERE id = $1;")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var image string
+	err = stmt.QueryRow(user).Scan(&image)
+	if err != nil {
+		return "", nil, err
+	}
+
+	stmt, err = b.db.Prepare("SELECT id FROM stories WHERE user_id = $1;")
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := stmt.Query(user)
+	if err != nil {
+		return "", nil, err
+	}
+
+	defer rows.Close()
+
+	stories := make([]string, 0)
+	for rows.Next() {
+		var id string
+		err := rows.Scan(&id)
+		if err != nil {
+			return "", nil, err
+		}
+
+		stories = append(stories, id)
+	}
+
+	return image, stories, rows.Err()
+}
+
+func (b *Backend) query(query string, user int) ([]byte, error) {
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var data sql.NullString
+	err = stmt.QueryRow(user).Scan(&data)
+	if err == sql.ErrNoRows {
+		return []byte("null"), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !data.Valid {
+		return []byte("null"), nil
+	}
+
+	// the data is indented, so it is readable when opened.
+	var indented bytes.Buffer
+	err = json.Indent(&indented, []byte(data.String), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
+}
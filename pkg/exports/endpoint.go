@@ -0,0 +1,49 @@
+package exports
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+)
+
+// Endpoint serves exports, they are downloaded without being logged in using the token of the emailed link.
+type Endpoint struct {
+	links *Links
+	path  string
+}
+
+func NewEndpoint(links *Links, path string) *Endpoint {
+	return &Endpoint{links: links, path: path}
+}
+
+func (e *Endpoint) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/{token:[a-f0-9]+}", e.download).Methods("GET")
+
+	return r
+}
+
+func (e *Endpoint) download(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	err := e.links.Validate(token)
+	if err == ErrLinkExpired {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "link expired")
+		return
+	}
+
+	if err != nil {
+		log.Printf("links.Validate err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="data.zip"`)
+	http.ServeFile(w, r, filepath.Join(e.path, FileName(token)))
+}
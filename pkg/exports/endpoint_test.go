@@ -0,0 +1,68 @@
+package exports_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/exports"
+)
+
+func TestEndpoint_Download(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	path := t.TempDir()
+	links := exports.NewLinks(rdb)
+
+	token, err := links.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(path, exports.FileName(token)), []byte("zip"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := exports.NewEndpoint(links, path).Router()
+
+	download := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", "/"+token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := download()
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	if rr.Body.String() != "zip" {
+		t.Fatalf("unexpected body %s", rr.Body.String())
+	}
+
+	mr.FastForward(exports.LinkExpiration)
+
+	rr = download()
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
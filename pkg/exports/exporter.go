@@ -0,0 +1,89 @@
+package exports
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Exporter writes the data of a user to a zip archive.
+type Exporter struct {
+	backend *Backend
+
+	// images and stories are the directories profile images and story audio files are stored in.
+	images  string
+	stories string
+}
+
+func NewExporter(backend *Backend, images, stories string) *Exporter {
+	return &Exporter{
+		backend: backend,
+		images:  images,
+		stories: stories,
+	}
+}
+
+// Export writes the archive, files that no longer exist on disk are skipped.
+func (e *Exporter) Export(user int, w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	sections, err := e.backend.Sections(user)
+	if err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		f, err := archive.Create(section.Name)
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Write(section.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	image, stories, err := e.backend.Media(user)
+	if err != nil {
+		return err
+	}
+
+	if image != "" {
+		err = add(archive, "images/"+image, filepath.Join(e.images, image))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, id := range stories {
+		err = add(archive, "stories/"+id+".aac", filepath.Join(e.stories, id+".aac"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func add(archive *zip.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, file)
+	return err
+}
@@ -0,0 +1,128 @@
+package exports_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/exports"
+)
+
+func TestExporter_Export(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	images := t.TempDir()
+	stories := t.TempDir()
+
+	err = ioutil.WriteFile(filepath.Join(images, "foo.png"), []byte("image"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(stories, "1.aac"), []byte("story"), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := 1
+
+	mock.ExpectPrepare("^SELECT row_to_json(.+) FROM users").ExpectQuery().
+		WithArgs(user).
+		WillReturnRows(mock.NewRows([]string{"row_to_json"}).AddRow(`{"id":1,"username":"foo"}`))
+
+	// sections without data are exported as empty lists.
+	for i := 0; i < 8; i++ {
+		mock.ExpectPrepare("^SELECT COALESCE(.+)").ExpectQuery().
+			WithArgs(user).
+			WillReturnRows(mock.NewRows([]string{"coalesce"}).AddRow("[]"))
+	}
+
+	mock.ExpectPrepare("^SELECT row_to_json(.+) FROM notification_settings").ExpectQuery().
+		WithArgs(user).
+		WillReturnRows(mock.NewRows([]string{"row_to_json"}))
+
+	mock.ExpectPrepare("^SELECT image FROM users").ExpectQuery().
+		WithArgs(user).
+		WillReturnRows(mock.NewRows([]string{"image"}).AddRow("foo.png"))
+
+	// the file of the second story expired.
+	mock.ExpectPrepare("^SELECT id FROM stories").ExpectQuery().
+		WithArgs(user).
+		WillReturnRows(mock.NewRows([]string{"id"}).AddRow("1").AddRow("2"))
+
+	buf := &bytes.Buffer{}
+	err = exports.NewExporter(exports.NewBackend(db), images, stories).Export(user, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[f.Name] = string(data)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	expected := []string{
+		"blocks.json",
+		"followers.json",
+		"following.json",
+		"images/foo.png",
+		"linked_accounts.json",
+		"notification_settings.json",
+		"notifications.json",
+		"profile.json",
+		"rooms.json",
+		"stories.json",
+		"stories/1.aac",
+		"story_reactions.json",
+	}
+
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v actual %v", expected, names)
+	}
+
+	if files["profile.json"] != "{\n  \"id\": 1,\n  \"username\": \"foo\"\n}" {
+		t.Fatalf("unexpected profile %s", files["profile.json"])
+	}
+
+	if files["notification_settings.json"] != "null" || files["stories/1.aac"] != "story" {
+		t.Fatalf("unexpected files %v", files)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
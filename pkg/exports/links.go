@@ -0,0 +1,65 @@
+package exports
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// LinkExpiration is how long the download link of an export is valid, exports are removed afterwards.
+const LinkExpiration = 48 * time.Hour
+
+var ErrLinkExpired = errors.New("link expired")
+
+// Links are the time-limited tokens exports are downloaded with.
+type Links struct {
+	rdb *redis.Client
+}
+
+func NewLinks(rdb *redis.Client) *Links {
+	return &Links{rdb: rdb}
+}
+
+// Create returns a new token, which is also the name of the export file.
+func (l *Links) Create() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	token := hex.EncodeToString(b)
+
+	err = l.rdb.Set(l.rdb.Context(), linkKey(token), true, LinkExpiration).Err()
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Validate returns whether the link of the token has not yet expired.
+func (l *Links) Validate(token string) error {
+	exists, err := l.rdb.Exists(l.rdb.Context(), linkKey(token)).Result()
+	if err != nil {
+		return err
+	}
+
+	if exists == 0 {
+		return ErrLinkExpired
+	}
+
+	return nil
+}
+
+// FileName returns the name of the export a token downloads.
+func FileName(token string) string {
+	return token + ".zip"
+}
+
+func linkKey(token string) string {
+	return "exports_link_" + token
+}
@@ -0,0 +1,108 @@
+package exports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	jobsKey       = "exports_jobs"
+	processingKey = "exports_processing"
+)
+
+// RequestCooldown limits how often a user can export their data.
+const RequestCooldown = 24 * time.Hour
+
+var ErrRequestCooldown = errors.New("export requested recently")
+
+// Queue holds the users whose data should be exported.
+// Jobs are moved to a processing list while exported, so jobs of a crashed worker are recovered.
+type Queue struct {
+	rdb *redis.Client
+}
+
+func NewQueue(rdb *redis.Client) *Queue {
+	return &Queue{rdb: rdb}
+}
+
+// Request queues an export for the user, it returns when the user can request again if they did so recently.
+func (q *Queue) Request(user int) (time.Duration, error) {
+	ctx := q.rdb.Context()
+
+	ok, err := q.rdb.SetNX(ctx, requestedKey(user), true, RequestCooldown).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if !ok {
+		ttl, err := q.rdb.TTL(ctx, requestedKey(user)).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		return ttl, ErrRequestCooldown
+	}
+
+	return 0, q.rdb.LPush(ctx, jobsKey, user).Err()
+}
+
+// Pop blocks until an export is queued or the timeout expires, it returns false when no export was queued.
+func (q *Queue) Pop(ctx context.Context, timeout time.Duration) (int, bool, error) {
+	res, err := q.rdb.BRPopLPush(ctx, jobsKey, processingKey, timeout).Result()
+	if err == redis.Nil {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	user, err := strconv.Atoi(res)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return user, true, nil
+}
+
+// Done removes a finished export from the processing list.
+func (q *Queue) Done(user int) error {
+	return q.rdb.LRem(q.rdb.Context(), processingKey, 1, user).Err()
+}
+
+// Failed removes an export that could not be completed, the user can request it again right away.
+func (q *Queue) Failed(user int) error {
+	ctx := q.rdb.Context()
+
+	pipe := q.rdb.TxPipeline()
+	pipe.LRem(ctx, processingKey, 1, user)
+	pipe.Del(ctx, requestedKey(user))
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+// Recover queues the exports that were being processed again, it should be called before a worker starts.
+func (q *Queue) Recover() error {
+	ctx := q.rdb.Context()
+
+	for {
+		err := q.rdb.RPopLPush(ctx, processingKey, jobsKey).Err()
+		if err == redis.Nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func requestedKey(user int) string {
+	return fmt.Sprintf("exports_requested_%d", user)
+}
@@ -0,0 +1,74 @@
+package exports_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/exports"
+)
+
+func TestQueue(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	queue := exports.NewQueue(rdb)
+
+	_, err = queue.Request(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retry, err := queue.Request(1)
+	if err != exports.ErrRequestCooldown {
+		t.Fatalf("expected %v actual %v", exports.ErrRequestCooldown, err)
+	}
+
+	if retry != exports.RequestCooldown {
+		t.Fatalf("expected retry %v actual %v", exports.RequestCooldown, retry)
+	}
+
+	user, ok, err := queue.Pop(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || user != 1 {
+		t.Fatalf("expected user 1 actual %d", user)
+	}
+
+	// exports that were being processed when a worker stopped are queued again.
+	err = queue.Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, ok, err = queue.Pop(context.Background(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ok || user != 1 {
+		t.Fatalf("expected user 1 actual %d", user)
+	}
+
+	err = queue.Failed(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// failed exports can be requested again.
+	_, err = queue.Request(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,165 @@
+package exports
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+const (
+	popTimeout    = 5 * time.Second
+	sweepInterval = time.Hour
+)
+
+// Worker exports the data of users and emails them the download link.
+type Worker struct {
+	queue    *Queue
+	exporter *Exporter
+	links    *Links
+	users    *users.Backend
+	mail     *mail.Service
+
+	// path is the directory exports are written to, url is where they are downloaded from.
+	path string
+	url  string
+}
+
+func NewWorker(queue *Queue, exporter *Exporter, links *Links, ub *users.Backend, mail *mail.Service, path, url string) *Worker {
+	return &Worker{
+		queue:    queue,
+		exporter: exporter,
+		links:    links,
+		users:    ub,
+		mail:     mail,
+		path:     path,
+		url:      strings.TrimSuffix(url, "/"),
+	}
+}
+
+// Run processes exports until the context is cancelled.
+func (w *Worker) Run(ctx context.Context) error {
+	err := w.queue.Recover()
+	if err != nil {
+		return err
+	}
+
+	sweep := time.NewTicker(sweepInterval)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sweep.C:
+			err := w.Sweep(time.Now())
+			if err != nil {
+				log.Printf("worker.Sweep err: %v\n", err)
+			}
+		default:
+		}
+
+		user, ok, err := w.queue.Pop(ctx, popTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			log.Printf("queue.Pop err: %v\n", err)
+			time.Sleep(popTimeout)
+			continue
+		}
+
+		if !ok {
+			continue
+		}
+
+		err = w.Process(user)
+		if err != nil {
+			log.Printf("failed to export %d err: %v\n", user, err)
+
+			err = w.queue.Failed(user)
+			if err != nil {
+				log.Printf("queue.Failed err: %v\n", err)
+			}
+
+			continue
+		}
+
+		err = w.queue.Done(user)
+		if err != nil {
+			log.Printf("queue.Done err: %v\n", err)
+		}
+	}
+}
+
+// Process writes the export of a user and emails them the link.
+func (w *Worker) Process(user int) error {
+	u, err := w.users.FindByID(user)
+	if err != nil {
+		return err
+	}
+
+	token, err := w.links.Create()
+	if err != nil {
+		return err
+	}
+
+	// the export is written to a temporary file, so incomplete exports are never downloaded.
+	file, err := ioutil.TempFile(w.path, "*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name())
+
+	err = w.exporter.Export(user, file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(file.Name(), filepath.Join(w.path, FileName(token)))
+	if err != nil {
+		return err
+	}
+
+	if u.Email == nil || *u.Email == "" {
+		log.Printf("user %d has no email to send the export to\n", user)
+		return nil
+	}
+
+	return w.mail.SendExportEmail(*u.Email, w.url+"/"+token, LinkExpiration)
+}
+
+// Sweep removes the exports whose links have expired.
+func (w *Worker) Sweep(now time.Time) error {
+	files, err := ioutil.ReadDir(w.path)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || now.Sub(f.ModTime()) < LinkExpiration {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(w.path, f.Name()))
+		if err != nil {
+			log.Printf("failed to remove export %s err: %v\n", f.Name(), err)
+		}
+	}
+
+	return nil
+}
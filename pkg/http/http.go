@@ -4,9 +4,12 @@ package http
 import (
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type ErrorCode int
@@ -28,6 +31,16 @@ const (
 	ErrorCodeNotFound
 	ErrorCodeNotAllowed
 	ErrorCodeEmailRegistrationDisabled
+	ErrorCodeTooManyRequests
+	ErrorCodeResendCooldown
+	ErrorCodeTooManyPinAttempts
+	ErrorCodeEmailAlreadyExists
+	ErrorCodeInvalidPhoneNumber
+	ErrorCodePhoneRegistrationDisabled
+	ErrorCodePhoneAlreadyExists
+	ErrorCodeInvalidScope
+	ErrorCodeInsufficientScope
+	ErrorCodeMissingEmail
 )
 
 // NotFoundHandler handles 404 responses
@@ -55,6 +68,25 @@ func JsonError(w http.ResponseWriter, responseCode int, code ErrorCode, msg stri
 	}
 }
 
+// JsonRetryError writes an Error for a request that can be retried after a duration, clients use it to show a timer.
+func JsonRetryError(w http.ResponseWriter, responseCode int, code ErrorCode, msg string, retryAfter time.Duration) {
+	type ErrorResponse struct {
+		Code       ErrorCode `json:"code"`
+		Message    string    `json:"message"`
+		RetryAfter int       `json:"retry_after"`
+	}
+
+	seconds := int(retryAfter.Round(time.Second).Seconds())
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(responseCode)
+
+	err := JsonEncode(w, ErrorResponse{Code: code, Message: msg, RetryAfter: seconds})
+	if err != nil {
+		log.Printf("failed to encode response: %s", err.Error())
+	}
+}
+
 // JsonSuccess writes a success message to the writer.
 func JsonSuccess(w http.ResponseWriter) {
 	type SuccessResponse struct {
@@ -88,3 +120,57 @@ func GetInt(v url.Values, key string, defaultValue int) int {
 
 	return val
 }
+
+// trustedProxies are the networks of the proxies in front of the API, addresses they forward are trusted.
+var trustedProxies = parseNetworks("127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7")
+
+// ClientIP returns the address of the client that sent a request.
+// Forwarded addresses are only trusted when added by a trusted proxy, so the right-most address not belonging to one is used.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+
+	hops := make([]string, 0)
+	for _, header := range forwarded {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0 && isTrustedProxy(ip); i-- {
+		ip = strings.TrimSpace(hops[i])
+	}
+
+	return ip
+}
+
+func isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, network := range trustedProxies {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
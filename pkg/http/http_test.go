@@ -1,6 +1,7 @@
 package http_test
 
 import (
+	nethttp "net/http"
 	"net/url"
 	"testing"
 
@@ -43,3 +44,37 @@ func TestGetInt(t *testing.T) {
 		})
 	}
 }
+
+func TestClientIP(t *testing.T) {
+	var tests = []struct {
+		remote    string
+		forwarded string
+		expected  string
+	}{
+		{"127.0.0.1:1234", "", "127.0.0.1"},
+		{"127.0.0.1:1234", "10.0.0.1, 127.0.0.1", "10.0.0.1"},
+		{"127.0.0.1", "", "127.0.0.1"},
+		{"10.0.0.2:1234", "1.1.1.1, 203.0.113.7", "203.0.113.7"},
+		{"10.0.0.2:1234", "203.0.113.7, 10.0.0.3", "203.0.113.7"},
+		{"198.51.100.1:1234", "1.1.1.1", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			r, err := nethttp.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+
+			result := http.ClientIP(r)
+			if result != tt.expected {
+				t.Fatalf("expected %s does not match actual %s", tt.expected, result)
+			}
+		})
+	}
+}
@@ -1,19 +1,24 @@
 package middlewares
 
 import (
+	"log"
 	"net/http"
 
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
 )
 
 type AuthenticationMiddleware struct {
-	sm *sessions.SessionManager
+	sm     *sessions.SessionManager
+	tokens *accesstokens.Backend
 }
 
-func NewAuthenticationMiddleware(sm *sessions.SessionManager) *AuthenticationMiddleware {
+// NewAuthenticationMiddleware creates a middleware accepting sessions, and access tokens when a token backend is set.
+func NewAuthenticationMiddleware(sm *sessions.SessionManager, tokens *accesstokens.Backend) *AuthenticationMiddleware {
 	return &AuthenticationMiddleware{
-		sm: sm,
+		sm:     sm,
+		tokens: tokens,
 	}
 }
 
@@ -25,14 +30,57 @@ func (h AuthenticationMiddleware) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		id, err := h.sm.GetUserIDForSession(token)
+		if accesstokens.IsAccessToken(token) {
+			h.authenticateAccessToken(w, req, token, next)
+			return
+		}
+
+		id, session, err := h.sm.GetSessionForToken(token)
 		if err != nil || id == 0 {
 			httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
 			return
 		}
 
+		err = h.sm.Touch(token, id, session, sessions.MetadataFromRequest(req))
+		if err != nil {
+			log.Printf("sessions.Touch err: %v\n", err)
+		}
+
 		r := req.WithContext(httputil.WithUserID(req.Context(), id))
 
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticateAccessToken only allows the request when the route declared a scope the token was granted.
+func (h AuthenticationMiddleware) authenticateAccessToken(w http.ResponseWriter, req *http.Request, token string, next http.Handler) {
+	if h.tokens == nil {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
+		return
+	}
+
+	at, err := h.tokens.Authenticate(token)
+	if err != nil {
+		if err != accesstokens.ErrInvalidToken {
+			log.Printf("accesstokens.Authenticate err: %v\n", err)
+		}
+
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
+		return
+	}
+
+	scope, ok := requiredScope(req.Context())
+	if !ok || !at.HasScope(scope) {
+		httputil.JsonError(w, http.StatusForbidden, httputil.ErrorCodeInsufficientScope, "insufficient scope")
+		return
+	}
+
+	err = h.tokens.Touch(at.ID)
+	if err != nil {
+		log.Printf("accesstokens.Touch err: %v\n", err)
+	}
+
+	r := req.WithContext(httputil.WithUserID(req.Context(), at.UserID))
+
+	next.ServeHTTP(w, r)
+}
@@ -5,7 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 
 	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
@@ -23,7 +23,7 @@ func TestAuthenticationHandler_WithoutAuth(t *testing.T) {
 	})
 
 	sm := sessions.NewSessionManager(rdb)
-	mw := middlewares.NewAuthenticationMiddleware(sm)
+	mw := middlewares.NewAuthenticationMiddleware(sm, nil)
 
 	r, err := http.NewRequest("POST", "/add", nil)
 	if err != nil {
@@ -51,7 +51,7 @@ func TestAuthenticationHandler_WithoutActiveSession(t *testing.T) {
 	})
 
 	sm := sessions.NewSessionManager(rdb)
-	mw := middlewares.NewAuthenticationMiddleware(sm)
+	mw := middlewares.NewAuthenticationMiddleware(sm, nil)
 
 	r, err := http.NewRequest("POST", "/add", nil)
 	if err != nil {
@@ -81,7 +81,7 @@ func TestAuthenticationHandler(t *testing.T) {
 	})
 
 	sm := sessions.NewSessionManager(rdb)
-	mw := middlewares.NewAuthenticationMiddleware(sm)
+	mw := middlewares.NewAuthenticationMiddleware(sm, nil)
 
 	r, err := http.NewRequest("POST", "/add", nil)
 	if err != nil {
@@ -89,7 +89,7 @@ func TestAuthenticationHandler(t *testing.T) {
 	}
 
 	sess := "123"
-	_ = sm.NewSession(sess, 1, 0)
+	_, _ = sm.NewSession(sess, 1, sessions.Metadata{})
 
 	r.Header.Set("Authorization", sess)
 
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
+)
+
+type PermissionMiddleware struct {
+	roles *roles.Backend
+}
+
+func NewPermissionMiddleware(backend *roles.Backend) *PermissionMiddleware {
+	return &PermissionMiddleware{
+		roles: backend,
+	}
+}
+
+// Require only allows users with the permission, it must be used after the AuthenticationMiddleware.
+func (p PermissionMiddleware) Require(permission roles.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			id, ok := httputil.GetUserIDFromContext(req.Context())
+			if !ok {
+				httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
+				return
+			}
+
+			allowed, err := p.roles.HasPermission(id, permission)
+			if err != nil {
+				log.Printf("roles.HasPermission err: %v\n", err)
+			}
+
+			if !allowed {
+				httputil.JsonError(w, http.StatusForbidden, httputil.ErrorCodeNotAllowed, "not allowed")
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package middlewares_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
+)
+
+func TestPermissionMiddleware(t *testing.T) {
+	tests := []struct {
+		count    int
+		expected int
+	}{
+		{0, http.StatusForbidden},
+		{1, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.expected), func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mw := middlewares.NewPermissionMiddleware(roles.NewBackend(db))
+
+			mock.ExpectPrepare("^SELECT COUNT").
+				ExpectQuery().
+				WithArgs(1, `{"staff"}`).
+				WillReturnRows(mock.NewRows([]string{"count"}).AddRow(tt.count))
+
+			r, err := http.NewRequest("GET", "/stats", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			handler := mw.Require(roles.PermissionAdmin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				httputil.JsonSuccess(w)
+			}))
+
+			handler.ServeHTTP(rr, r.WithContext(httputil.WithUserID(r.Context(), 1)))
+
+			if status := rr.Code; status != tt.expected {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+		})
+	}
+}
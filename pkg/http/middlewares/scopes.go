@@ -0,0 +1,43 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
+)
+
+type scopeKey struct{}
+
+// Scopes declares the scopes access tokens need to use the routes of a router,
+// routes without a scope can only be used with a session.
+// Its middleware must run before the AuthenticationMiddleware, which enforces the scopes.
+type Scopes map[*mux.Route]accesstokens.Scope
+
+func NewScopes() Scopes {
+	return make(Scopes)
+}
+
+// Require makes the route usable by access tokens with the scope.
+func (s Scopes) Require(route *mux.Route, scope accesstokens.Scope) {
+	s[route] = scope
+}
+
+func (s Scopes) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		scope, ok := s[mux.CurrentRoute(req)]
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), scopeKey{}, scope)))
+	})
+}
+
+func requiredScope(ctx context.Context) (accesstokens.Scope, bool) {
+	scope, ok := ctx.Value(scopeKey{}).(accesstokens.Scope)
+	return scope, ok
+}
@@ -0,0 +1,132 @@
+package middlewares_test
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
+	"github.com/soapboxsocial/soapbox/pkg/sessions"
+)
+
+func TestAuthenticationMiddleware_AccessTokenScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		found    bool
+		expected int
+	}{
+		{name: "granted", path: "/profile", found: true, expected: http.StatusOK},
+		{name: "not granted", path: "/follow", found: true, expected: http.StatusForbidden},
+		{name: "no scope", path: "/settings", found: true, expected: http.StatusForbidden},
+		{name: "invalid", path: "/profile", found: false, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			mr, err := miniredis.Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mr.Close()
+
+			sm := sessions.NewSessionManager(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+			mw := middlewares.NewAuthenticationMiddleware(sm, accesstokens.NewBackend(db))
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				id, ok := httputil.GetUserIDFromContext(r.Context())
+				if !ok || id != 7 {
+					t.Errorf("unexpected user %d", id)
+				}
+			}
+
+			router := mux.NewRouter()
+			scopes := middlewares.NewScopes()
+			scopes.Require(router.HandleFunc("/profile", handler).Methods("GET"), accesstokens.ScopeProfileRead)
+			scopes.Require(router.HandleFunc("/follow", handler).Methods("GET"), accesstokens.ScopeFollow)
+			router.HandleFunc("/settings", handler).Methods("GET")
+			router.Use(scopes.Middleware, mw.Middleware)
+
+			query := mock.ExpectPrepare("^SELECT (.+) FROM access_tokens").ExpectQuery()
+			if tt.found {
+				query.WillReturnRows(
+					mock.NewRows([]string{"id", "user_id", "name", "scopes", "expires", "created", "last_used"}).
+						AddRow(1, 7, "bot", "{profile:read,rooms:join}", nil, time.Now(), nil),
+				)
+			} else {
+				query.WillReturnError(sql.ErrNoRows)
+			}
+
+			if tt.expected == http.StatusOK {
+				mock.ExpectPrepare("^UPDATE access_tokens SET last_used").ExpectExec().
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			r, err := http.NewRequest("GET", tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r.Header.Set("Authorization", accesstokens.Prefix+"abc")
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, r)
+
+			if status := rr.Code; status != tt.expected {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.expected)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAuthenticationMiddleware_SessionIgnoresScopes(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	sm := sessions.NewSessionManager(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	mw := middlewares.NewAuthenticationMiddleware(sm, nil)
+
+	_, _ = sm.NewSession("123", 1, sessions.Metadata{})
+
+	router := mux.NewRouter()
+	router.HandleFunc("/settings", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	router.Use(middlewares.NewScopes().Middleware, mw.Middleware)
+
+	r, err := http.NewRequest("GET", "/settings", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Header.Set("Authorization", "123")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, r)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+}
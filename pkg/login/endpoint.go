@@ -5,12 +5,12 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/gorilla/mux"
 
@@ -20,17 +20,18 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/images"
 	"github.com/soapboxsocial/soapbox/pkg/login/internal"
 	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/oidc"
+	"github.com/soapboxsocial/soapbox/pkg/pins"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
+	"github.com/soapboxsocial/soapbox/pkg/sms"
 	"github.com/soapboxsocial/soapbox/pkg/users"
 	"github.com/soapboxsocial/soapbox/pkg/users/types"
 )
 
 // Contains the login handlers
 
-const expiration = 8760 * time.Hour
-
 const LoginStateRegister = "register"
 const LoginStateSuccess = "success"
 
@@ -38,6 +39,7 @@ const TestEmail = "test@apple.com"
 
 type Config struct {
 	RegisterWithEmailEnabled bool `mapstructure:"email"`
+	RegisterWithPhoneEnabled bool `mapstructure:"phone"`
 }
 
 // @todo better names
@@ -46,12 +48,19 @@ type loginState struct {
 	User      *types.User `json:"user,omitempty"`
 	ExpiresIn *int        `json:"expires_in,omitempty"`
 	Token     *string     `json:"token,omitempty"`
+
+	// RefreshToken is used to get a new token once it expired.
+	RefreshToken *string `json:"refresh_token,omitempty"`
+
+	// Restored is set when logging in cancelled the scheduled deletion of the account.
+	Restored bool `json:"restored,omitempty"`
 }
 
 type Endpoint struct {
 	sync.Mutex
 
 	state    *StateManager
+	limiter  *Limiter
 	users    *users.Backend
 	sessions *sessions.SessionManager
 
@@ -59,10 +68,16 @@ type Endpoint struct {
 
 	mail *mail.Service
 
+	sms sms.Sender
+
 	queue *pubsub.Queue
 
 	signInWithApple apple.SignInWithApple
-	roomService     pb.RoomServiceClient
+
+	// providers validate the tokens of login providers by their name.
+	providers map[string]oidc.Validator
+
+	roomService pb.RoomServiceClient
 
 	config Config
 }
@@ -70,22 +85,28 @@ type Endpoint struct {
 func NewEndpoint(
 	ub *users.Backend,
 	state *StateManager,
+	limiter *Limiter,
 	manager *sessions.SessionManager,
 	mail *mail.Service,
+	sms sms.Sender,
 	ib *images.Backend,
 	queue *pubsub.Queue,
 	signInWithApple apple.SignInWithApple,
+	providers map[string]oidc.Validator,
 	roomService pb.RoomServiceClient,
 	config Config,
 ) Endpoint {
 	return Endpoint{
 		users:           ub,
 		state:           state,
+		limiter:         limiter,
 		sessions:        manager,
 		mail:            mail,
+		sms:             sms,
 		ib:              ib,
 		queue:           queue,
 		signInWithApple: signInWithApple,
+		providers:       providers,
 		roomService:     roomService,
 		config:          config,
 	}
@@ -96,13 +117,16 @@ func (e *Endpoint) Router() *mux.Router {
 
 	r.Path("/start").Methods("POST").HandlerFunc(e.start)
 	r.Path("/start/apple").Methods("POST").HandlerFunc(e.loginWithApple)
+	r.Path("/start/phone").Methods("POST").HandlerFunc(e.startWithPhone)
+	r.Path("/start/{provider:[a-z]+}").Methods("POST").HandlerFunc(e.loginWithProvider)
 	r.Path("/pin").Methods("POST").HandlerFunc(e.submitPin)
 	r.Path("/register").Methods("POST").HandlerFunc(e.register)
+	r.Path("/refresh").Methods("POST").HandlerFunc(e.refresh)
 
 	// This is kinda hacky but we need it. Reason being, we want to only be able to register completed when logged in.
 	// But we also still want to use these routes.
 	// @TODO INJECT
-	mw := middlewares.NewAuthenticationMiddleware(e.sessions)
+	mw := middlewares.NewAuthenticationMiddleware(e.sessions, nil)
 	r.Path("/register/completed").Methods("POST").Handler(mw.Middleware(http.HandlerFunc(e.completed)))
 
 	return r
@@ -121,13 +145,17 @@ func (e *Endpoint) start(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !e.allow(w, startIPLimit, httputil.ClientIP(r)) {
+		return
+	}
+
 	token, err := internal.GenerateToken()
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
 		return
 	}
 
-	pin, err := internal.GeneratePin()
+	pin, err := pins.Generate()
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
 		return
@@ -137,13 +165,13 @@ func (e *Endpoint) start(w http.ResponseWriter, r *http.Request) {
 		pin = "098316"
 	}
 
-	isApple, err := e.users.IsAppleIDAccount(email)
+	requiresIdentity, err := e.users.RequiresIdentity(email)
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
 		return
 	}
 
-	if isApple {
+	if requiresIdentity {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "invalid authentication method")
 		return
 	}
@@ -161,6 +189,21 @@ func (e *Endpoint) start(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	ok, retry, err := e.limiter.Cooldown(email)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	if !ok {
+		httputil.JsonRetryError(w, http.StatusTooManyRequests, httputil.ErrorCodeResendCooldown, "wait before requesting another code", retry)
+		return
+	}
+
+	if !e.allow(w, startEmailLimit, email) {
+		return
+	}
+
 	err = e.state.SetPinState(token, email, pin)
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
@@ -181,6 +224,82 @@ func (e *Endpoint) start(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (e *Endpoint) startWithPhone(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	phone := internal.NormalizePhone(r.Form.Get("phone"))
+	if !internal.ValidatePhone(phone) {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidPhoneNumber, "invalid phone number")
+		return
+	}
+
+	if !e.allow(w, startIPLimit, httputil.ClientIP(r)) {
+		return
+	}
+
+	token, err := internal.GenerateToken()
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	pin, err := pins.Generate()
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	if !e.config.RegisterWithPhoneEnabled {
+		isRegistered, err := e.users.IsPhoneRegistered(phone)
+		if err != nil {
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+			return
+		}
+
+		if !isRegistered {
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodePhoneRegistrationDisabled, "register with phone disabled")
+			return
+		}
+	}
+
+	ok, retry, err := e.limiter.Cooldown(phone)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	if !ok {
+		httputil.JsonRetryError(w, http.StatusTooManyRequests, httputil.ErrorCodeResendCooldown, "wait before requesting another code", retry)
+		return
+	}
+
+	if !e.allow(w, startPhoneLimit, phone) {
+		return
+	}
+
+	err = e.state.SetPhonePinState(token, phone, pin)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	err = e.sms.Send(phone, fmt.Sprintf("Your Soapbox code is %s", pin))
+	if err != nil {
+		log.Println("failed to send code: ", err.Error())
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToLogin, "failed to send code")
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(map[string]string{"token": token})
+	if err != nil {
+		log.Println("error writing response: " + err.Error())
+	}
+}
+
 func (e *Endpoint) loginWithApple(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -201,16 +320,50 @@ func (e *Endpoint) loginWithApple(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	e.loginWithIdentity(w, r, &oidc.Identity{Provider: apple.Provider, Subject: userInfo.ID, Email: userInfo.Email})
+}
+
+func (e *Endpoint) loginWithProvider(w http.ResponseWriter, r *http.Request) {
+	provider, ok := e.providers[mux.Vars(r)["provider"]]
+	if !ok {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "unknown provider")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	jwt := r.Form.Get("token")
+	if jwt == "" {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid token id")
+		return
+	}
+
+	identity, err := provider.Validate(jwt)
+	if err != nil {
+		log.Printf("%s validation err: %v", mux.Vars(r)["provider"], err)
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "failed to validate")
+		return
+	}
+
+	e.loginWithIdentity(w, r, identity)
+}
+
+// loginWithIdentity logs in the user of a validated identity, unknown identities enter the registration state.
+func (e *Endpoint) loginWithIdentity(w http.ResponseWriter, r *http.Request, identity *oidc.Identity) {
 	token, err := internal.GenerateToken()
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
 		return
 	}
 
-	user, err := e.users.FindByAppleID(userInfo.ID)
+	user, err := e.users.FindByIdentity(identity.Provider, identity.Subject)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			e.enterAppleRegistrationState(w, token, userInfo.Email, userInfo.ID)
+			e.enterIdentityRegistrationState(w, token, identity)
 			return
 		}
 
@@ -218,14 +371,13 @@ func (e *Endpoint) loginWithApple(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = e.sessions.NewSession(token, user.ID, expiration)
+	success, err := e.login(r, token, user)
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToLogin, "")
 		return
 	}
 
-	expires := int(expiration.Seconds())
-	err = httputil.JsonEncode(w, loginState{State: LoginStateSuccess, User: user, ExpiresIn: &expires, Token: &token})
+	err = httputil.JsonEncode(w, success)
 	if err != nil {
 		log.Println("error writing response: " + err.Error())
 
@@ -242,23 +394,48 @@ func (e *Endpoint) submitPin(w http.ResponseWriter, r *http.Request) {
 	token := r.Form.Get("token")
 	pin := r.Form.Get("pin")
 
+	if !e.allow(w, pinIPLimit, httputil.ClientIP(r)) {
+		return
+	}
+
 	state, err := e.state.GetState(token)
 	if err != nil {
 		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
 		return
 	}
 
+	if !e.allow(w, pinEmailLimit, state.Identifier()) {
+		return
+	}
+
 	if state.Pin != pin {
+		remaining, err := e.state.FailedPinAttempt(token)
+		if err != nil {
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+			return
+		}
+
+		if remaining == 0 {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeTooManyPinAttempts, "too many attempts, request a new code")
+			return
+		}
+
 		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeIncorrectPin, "")
 		return
 	}
 
 	e.state.RemoveState(token)
 
-	user, err := e.users.FindByEmail(state.Email)
+	var user *types.User
+	if state.Phone != "" {
+		user, err = e.users.FindByPhone(state.Phone)
+	} else {
+		user, err = e.users.FindByEmail(state.Email)
+	}
+
 	if err != nil {
 		if err == sql.ErrNoRows {
-			e.enterRegistrationState(w, token, state.Email)
+			e.enterRegistrationState(w, token, state)
 			return
 		}
 
@@ -266,22 +443,113 @@ func (e *Endpoint) submitPin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = e.sessions.NewSession(token, user.ID, expiration)
+	success, err := e.login(r, token, user)
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToLogin, "")
 		return
 	}
 
-	expires := int(expiration.Seconds())
-	err = httputil.JsonEncode(w, loginState{State: LoginStateSuccess, User: user, ExpiresIn: &expires})
+	err = httputil.JsonEncode(w, success)
 	if err != nil {
 		log.Println("error writing response: " + err.Error())
 
 	}
 }
 
-func (e *Endpoint) enterRegistrationState(w http.ResponseWriter, token, email string) {
-	err := e.state.SetRegistrationState(token, email)
+func (e *Endpoint) refresh(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	token := r.Form.Get("refresh_token")
+	if token == "" {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeMissingParameter, "missing parameter: refresh_token")
+		return
+	}
+
+	tokens, err := e.sessions.Refresh(token, sessions.MetadataFromRequest(r))
+	if err != nil {
+		if err == sessions.ErrInvalidRefreshToken || err == sessions.ErrRefreshTokenReused {
+			httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
+			return
+		}
+
+		log.Printf("sessions.Refresh err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToLogin, "")
+		return
+	}
+
+	expires := int(tokens.ExpiresIn.Seconds())
+	err = httputil.JsonEncode(w, loginState{State: LoginStateSuccess, ExpiresIn: &expires, Token: &tokens.AccessToken, RefreshToken: &tokens.RefreshToken})
+	if err != nil {
+		log.Println("error writing response: " + err.Error())
+	}
+}
+
+// newSession logs in the user using the login token as access token.
+func (e *Endpoint) newSession(r *http.Request, token string, user *types.User) (*loginState, error) {
+	tokens, err := e.sessions.NewSession(token, user.ID, sessions.MetadataFromRequest(r))
+	if err != nil {
+		return nil, err
+	}
+
+	expires := int(tokens.ExpiresIn.Seconds())
+	return &loginState{State: LoginStateSuccess, User: user, ExpiresIn: &expires, Token: &tokens.AccessToken, RefreshToken: &tokens.RefreshToken}, nil
+}
+
+// login starts a session for an existing user, logging in restores accounts that are scheduled for deletion.
+func (e *Endpoint) login(r *http.Request, token string, user *types.User) (*loginState, error) {
+	restored, err := e.users.RestoreAccount(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if restored {
+		log.Printf("restored user %d", user.ID)
+
+		// the user is indexed again, it was removed from search when the deletion was scheduled.
+		err = e.queue.Publish(pubsub.UserTopic, pubsub.NewUserUpdateEvent(user.ID))
+		if err != nil {
+			log.Printf("queue.Publish err: %v\n", err)
+		}
+	}
+
+	success, err := e.newSession(r, token, user)
+	if err != nil {
+		return nil, err
+	}
+
+	success.Restored = restored
+	return success, nil
+}
+
+// allow counts a request against a limit, it writes an error when the limit is exceeded.
+func (e *Endpoint) allow(w http.ResponseWriter, limit limit, identifier string) bool {
+	ok, retry, err := e.limiter.Allow(limit, identifier)
+	if err != nil {
+		log.Printf("limiter.Allow err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return false
+	}
+
+	if !ok {
+		httputil.JsonRetryError(w, http.StatusTooManyRequests, httputil.ErrorCodeTooManyRequests, "too many requests", retry)
+		return false
+	}
+
+	return true
+}
+
+func (e *Endpoint) enterRegistrationState(w http.ResponseWriter, token string, state *State) {
+	var err error
+	if state.Phone != "" {
+		err = e.state.SetPhoneRegistrationState(token, state.Phone)
+	} else {
+		err = e.state.SetRegistrationState(token, state.Email)
+	}
+
 	if err != nil {
 		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
 		return
@@ -292,8 +560,9 @@ func (e *Endpoint) enterRegistrationState(w http.ResponseWriter, token, email st
 		log.Println("error writing response: " + err.Error())
 	}
 }
-func (e *Endpoint) enterAppleRegistrationState(w http.ResponseWriter, token, email, userID string) {
-	_, err := e.users.FindByEmail(email)
+
+func (e *Endpoint) enterIdentityRegistrationState(w http.ResponseWriter, token string, identity *oidc.Identity) {
+	_, err := e.users.FindByEmail(identity.Email)
 	if err == nil { // @TODO THIS MEANS THE USER IS ALREADY EXISTING
 		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid login method for user")
 		return
@@ -304,7 +573,7 @@ func (e *Endpoint) enterAppleRegistrationState(w http.ResponseWriter, token, ema
 		return
 	}
 
-	err = e.state.SetAppleRegistrationState(token, email, userID)
+	err = e.state.SetIdentityRegistrationState(token, identity.Email, identity.Provider, identity.Subject)
 	if err != nil {
 		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
 		return
@@ -360,8 +629,10 @@ func (e *Endpoint) register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var lastID int
-	if state.AppleUserID != "" {
-		lastID, err = e.users.CreateUserWithAppleLogin(state.Email, name, "", image, username, state.AppleUserID)
+	if state.Provider != "" {
+		lastID, err = e.users.CreateUserWithIdentity(state.Email, name, "", image, username, state.Provider, state.Subject)
+	} else if state.Phone != "" {
+		lastID, err = e.users.CreateUserWithPhone(state.Phone, name, "", image, username)
 	} else {
 		lastID, err = e.users.CreateUser(state.Email, name, "", image, username)
 	}
@@ -375,6 +646,11 @@ func (e *Endpoint) register(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if err.Error() == "pq: duplicate key value violates unique constraint \"idx_phone\"" {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodePhoneAlreadyExists, "phone number already exists")
+			return
+		}
+
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToRegister, "failed to register")
 		return
 	}
@@ -385,11 +661,16 @@ func (e *Endpoint) register(w http.ResponseWriter, r *http.Request) {
 		ID:          lastID,
 		DisplayName: name,
 		Username:    username,
-		Email:       &state.Email,
 		Image:       image,
 	}
 
-	err = e.sessions.NewSession(token, user.ID, expiration)
+	if state.Phone != "" {
+		user.Phone = &state.Phone
+	} else {
+		user.Email = &state.Email
+	}
+
+	success, err := e.newSession(r, token, &user)
 	if err != nil {
 		_ = e.ib.Remove(image)
 
@@ -398,8 +679,7 @@ func (e *Endpoint) register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expires := int(expiration.Seconds())
-	err = httputil.JsonEncode(w, loginState{State: LoginStateSuccess, User: &user, ExpiresIn: &expires})
+	err = httputil.JsonEncode(w, success)
 	if err != nil {
 		log.Println("error writing response: " + err.Error())
 	}
@@ -1,30 +1,39 @@
 package login_test
 
 import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-redis/redis/v8"
 	"github.com/golang/mock/gomock"
 	"github.com/sendgrid/sendgrid-go"
 
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 
 	"github.com/soapboxsocial/soapbox/mocks"
 
+	"github.com/soapboxsocial/soapbox/pkg/apple"
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
 	"github.com/soapboxsocial/soapbox/pkg/images"
 	"github.com/soapboxsocial/soapbox/pkg/login"
 	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/oidc"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
+	"github.com/soapboxsocial/soapbox/pkg/sms"
 	"github.com/soapboxsocial/soapbox/pkg/users"
 )
 
@@ -55,11 +64,14 @@ func TestLoginEndpoint_LoginWithTestAccount(t *testing.T) {
 	endpoint := login.NewEndpoint(
 		users.NewBackend(db),
 		login.NewStateManager(rdb),
+		login.NewLimiter(rdb),
 		sessions.NewSessionManager(rdb),
 		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
 		images.NewImagesBackend("/foo"),
 		pubsub.NewQueue(rdb),
 		mocks.NewMockSignInWithApple(ctrl),
+		nil,
 		mocks.NewMockRoomServiceClient(ctrl),
 		login.Config{
 			RegisterWithEmailEnabled: true,
@@ -112,11 +124,14 @@ func TestLoginEndpoint_PinSubmission(t *testing.T) {
 	endpoint := login.NewEndpoint(
 		users.NewBackend(db),
 		state,
+		login.NewLimiter(rdb),
 		sessions.NewSessionManager(rdb),
 		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
 		images.NewImagesBackend("/foo"),
 		pubsub.NewQueue(rdb),
 		mocks.NewMockSignInWithApple(ctrl),
+		nil,
 		mocks.NewMockRoomServiceClient(ctrl),
 		login.Config{
 			RegisterWithEmailEnabled: true,
@@ -139,6 +154,11 @@ func TestLoginEndpoint_PinSubmission(t *testing.T) {
 		WithArgs(email).
 		WillReturnRows(mock.NewRows([]string{"id", "display_name", "username", "image", "bio", "email"}).FromCSVString("1,dean,dean,123.png,my bio,test@apple.com"))
 
+	// the account was scheduled for deletion, logging in restores it.
+	mock.ExpectPrepare("^DELETE FROM account_deletions (.+)").ExpectExec().
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
 	form := url.Values{}
 	form.Add("pin", pin)
 	form.Add("token", token)
@@ -154,6 +174,16 @@ func TestLoginEndpoint_PinSubmission(t *testing.T) {
 	if status := rr.Code; status != http.StatusOK {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
+
+	resp := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp["restored"] != true {
+		t.Fatalf("expected account to be restored, got %v", resp)
+	}
 }
 
 func TestLoginEndpoint_RegistrationCompleted(t *testing.T) {
@@ -181,11 +211,14 @@ func TestLoginEndpoint_RegistrationCompleted(t *testing.T) {
 	endpoint := login.NewEndpoint(
 		users.NewBackend(db),
 		login.NewStateManager(rdb),
+		login.NewLimiter(rdb),
 		sm,
 		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
 		images.NewImagesBackend("/foo"),
 		pubsub.NewQueue(rdb),
 		mocks.NewMockSignInWithApple(ctrl),
+		nil,
 		m,
 		login.Config{
 			RegisterWithEmailEnabled: true,
@@ -198,7 +231,7 @@ func TestLoginEndpoint_RegistrationCompleted(t *testing.T) {
 	session := "1234"
 	userID := 1
 
-	err = sm.NewSession(session, userID, 0)
+	_, err = sm.NewSession(session, userID, sessions.Metadata{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -222,3 +255,486 @@ func TestLoginEndpoint_RegistrationCompleted(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 }
+
+func TestLoginEndpoint_Refresh(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sm := sessions.NewSessionManager(rdb)
+
+	endpoint := login.NewEndpoint(
+		users.NewBackend(nil),
+		login.NewStateManager(rdb),
+		login.NewLimiter(rdb),
+		sm,
+		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
+		images.NewImagesBackend("/foo"),
+		pubsub.NewQueue(rdb),
+		mocks.NewMockSignInWithApple(ctrl),
+		nil,
+		mocks.NewMockRoomServiceClient(ctrl),
+		login.Config{},
+	)
+
+	handler := endpoint.Router()
+
+	tokens, err := sm.NewSession("1234", 1, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second use of the refresh token is rejected.
+	for _, status := range []int{http.StatusOK, http.StatusUnauthorized} {
+		req, err := http.NewRequest("POST", "/refresh", strings.NewReader("refresh_token="+tokens.RefreshToken))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != status {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, status)
+		}
+	}
+}
+
+func TestLoginEndpoint_PinAttempts(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	state := login.NewStateManager(rdb)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	endpoint := login.NewEndpoint(
+		users.NewBackend(nil),
+		state,
+		login.NewLimiter(rdb),
+		sessions.NewSessionManager(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
+		images.NewImagesBackend("/foo"),
+		pubsub.NewQueue(rdb),
+		mocks.NewMockSignInWithApple(ctrl),
+		nil,
+		mocks.NewMockRoomServiceClient(ctrl),
+		login.Config{},
+	)
+
+	handler := endpoint.Router()
+
+	token := "1234"
+
+	err = state.SetPinState(token, "test@apple.com", "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code   int
+		status int
+	}{
+		{int(httputil.ErrorCodeIncorrectPin), http.StatusBadRequest},
+		{int(httputil.ErrorCodeIncorrectPin), http.StatusBadRequest},
+		{int(httputil.ErrorCodeIncorrectPin), http.StatusBadRequest},
+		{int(httputil.ErrorCodeIncorrectPin), http.StatusBadRequest},
+		{int(httputil.ErrorCodeTooManyPinAttempts), http.StatusBadRequest},
+
+		// the state was invalidated, the correct pin is no longer accepted.
+		{int(httputil.ErrorCodeInvalidRequestBody), http.StatusBadRequest},
+	}
+
+	for i, tt := range tests {
+		pin := "000000"
+		if i == len(tests)-1 {
+			pin = "123456"
+		}
+
+		form := url.Values{}
+		form.Add("pin", pin)
+		form.Add("token", token)
+
+		req, err := http.NewRequest("POST", "/pin", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tt.status)
+		}
+
+		var body struct {
+			Code int `json:"code"`
+		}
+
+		err = json.Unmarshal(rr.Body.Bytes(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body.Code != tt.code {
+			t.Fatalf("attempt %d: expected code %d actual %d", i, tt.code, body.Code)
+		}
+	}
+}
+
+func TestLoginEndpoint_StartCooldown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	endpoint := login.NewEndpoint(
+		users.NewBackend(db),
+		login.NewStateManager(rdb),
+		login.NewLimiter(rdb),
+		sessions.NewSessionManager(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
+		images.NewImagesBackend("/foo"),
+		pubsub.NewQueue(rdb),
+		mocks.NewMockSignInWithApple(ctrl),
+		nil,
+		mocks.NewMockRoomServiceClient(ctrl),
+		login.Config{
+			RegisterWithEmailEnabled: true,
+		},
+	)
+
+	handler := endpoint.Router()
+
+	for _, status := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+			WithArgs(login.TestEmail).
+			WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("0"))
+
+		req, err := http.NewRequest("POST", "/start", strings.NewReader("email="+login.TestEmail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != status {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, status)
+		}
+	}
+
+	mr.FastForward(2 * time.Minute)
+
+	// the email limit is reached after 5 codes within an hour.
+	for i := 0; i < 5; i++ {
+		mr.Del("login_limit_resend_" + login.TestEmail)
+
+		mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+			WithArgs(login.TestEmail).
+			WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("0"))
+
+		req, err := http.NewRequest("POST", "/start", strings.NewReader("email="+login.TestEmail))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		status := http.StatusOK
+		if i == 4 {
+			status = http.StatusTooManyRequests
+		}
+
+		if rr.Code != status {
+			t.Fatalf("request %d: handler returned wrong status code: got %v want %v", i, rr.Code, status)
+		}
+
+		if status == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+			t.Fatal("expected retry after header")
+		}
+	}
+
+	// counters always expire with their window.
+	for _, key := range mr.Keys() {
+		if strings.HasPrefix(key, "login_limit_start_") && mr.TTL(key) <= 0 {
+			t.Fatalf("expected %s to expire", key)
+		}
+	}
+}
+
+type validator struct {
+	identity *oidc.Identity
+}
+
+func (v *validator) Validate(token string) (*oidc.Identity, error) {
+	return v.identity, nil
+}
+
+func TestLoginEndpoint_LoginWithProvider(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	state := login.NewStateManager(rdb)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	identity := &oidc.Identity{Provider: oidc.Google, Subject: "1234", Email: "foo@example.com"}
+
+	endpoint := login.NewEndpoint(
+		users.NewBackend(db),
+		state,
+		login.NewLimiter(rdb),
+		sessions.NewSessionManager(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(ioutil.Discard),
+		images.NewImagesBackend("/foo"),
+		pubsub.NewQueue(rdb),
+		mocks.NewMockSignInWithApple(ctrl),
+		map[string]oidc.Validator{oidc.Google: &validator{identity: identity}},
+		mocks.NewMockRoomServiceClient(ctrl),
+		login.Config{},
+	)
+
+	handler := endpoint.Router()
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs(oidc.Google, "1234").
+		WillReturnError(sql.ErrNoRows)
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs("foo@example.com").
+		WillReturnError(sql.ErrNoRows)
+
+	req, err := http.NewRequest("POST", "/start/google", strings.NewReader("token=jwt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	resp := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp["state"] != login.LoginStateRegister {
+		t.Fatalf("expected state %s actual %v", login.LoginStateRegister, resp["state"])
+	}
+
+	s, err := state.GetState(resp["token"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &login.State{Email: identity.Email, Provider: identity.Provider, Subject: identity.Subject}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("expected %v actual %v", expected, s)
+	}
+
+	req, err = http.NewRequest("POST", "/start/facebook", strings.NewReader("token=jwt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestLoginEndpoint_LoginWithPhone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	state := login.NewStateManager(rdb)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	messages := &bytes.Buffer{}
+
+	endpoint := login.NewEndpoint(
+		users.NewBackend(db),
+		state,
+		login.NewLimiter(rdb),
+		sessions.NewSessionManager(rdb),
+		mail.NewMailService(&sendgrid.Client{}),
+		sms.NewLogSender(messages),
+		images.NewImagesBackend("/foo"),
+		pubsub.NewQueue(rdb),
+		mocks.NewMockSignInWithApple(ctrl),
+		nil,
+		mocks.NewMockRoomServiceClient(ctrl),
+		login.Config{
+			RegisterWithPhoneEnabled: true,
+		},
+	)
+
+	handler := endpoint.Router()
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("/start/phone", url.Values{"phone": {"4155552671"}})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected invalid phone number to be rejected, got %d", rr.Code)
+	}
+
+	phone := "+14155552671"
+
+	rr = post("/start/phone", url.Values{"phone": {"+1 (415) 555-2671"}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	resp := make(map[string]string)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := resp["token"]
+
+	pinState, err := state.GetState(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pinState.Phone != phone || pinState.Email != "" {
+		t.Fatalf("unexpected state %+v", pinState)
+	}
+
+	if !strings.Contains(messages.String(), "to="+phone) || !strings.Contains(messages.String(), pinState.Pin) {
+		t.Fatalf("expected pin to be sent to %s, sent %s", phone, messages.String())
+	}
+
+	mock.ExpectPrepare("^SELECT (.+) FROM users WHERE phone = (.+)").ExpectQuery().
+		WithArgs(phone).
+		WillReturnError(sql.ErrNoRows)
+
+	rr = post("/pin", url.Values{"token": {token}, "pin": {pinState.Pin}})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	registration, err := state.GetState(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &login.State{Phone: phone}
+	if !reflect.DeepEqual(registration, expected) {
+		t.Fatalf("expected %+v actual %+v", expected, registration)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStateManager_GetStateLegacyApple(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	err = mr.Set("login_state_1234", `{"Email":"foo@example.com","AppleUserID":"apple","Pin":""}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := login.NewStateManager(rdb).GetState("1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Provider != apple.Provider || s.Subject != "apple" {
+		t.Fatalf("expected apple identity actual %v", s)
+	}
+}
@@ -3,8 +3,8 @@ package internal
 import (
 	"crypto/rand"
 	"fmt"
-	"io"
 	"regexp"
+	"strings"
 )
 
 var emailRegex = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
@@ -13,6 +13,18 @@ func ValidateEmail(email string) bool {
 	return len(email) < 254 && emailRegex.MatchString(email)
 }
 
+// phoneRegex matches E.164 numbers, a country code followed by the subscriber number, at most 15 digits.
+var phoneRegex = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// NormalizePhone removes the separators commonly used when writing phone numbers.
+func NormalizePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(phone)
+}
+
+func ValidatePhone(phone string) bool {
+	return phoneRegex.MatchString(phone)
+}
+
 var usernameRegex = regexp.MustCompile("^([a-z0-9_]+)*$")
 
 func ValidateUsername(username string) bool {
@@ -28,20 +40,3 @@ func GenerateToken() (string, error) {
 
 	return fmt.Sprintf("%x", b), nil
 }
-
-func GeneratePin() (string, error) {
-	max := 6
-	b := make([]byte, max)
-	n, err := io.ReadAtLeast(rand.Reader, b, max)
-	if n != max {
-		return "", err
-	}
-
-	for i := 0; i < len(b); i++ {
-		b[i] = table[int(b[i])%len(table)]
-	}
-
-	return string(b), nil
-}
-
-var table = []byte{'1', '2', '3', '4', '5', '6', '7', '8', '9', '0'}
@@ -0,0 +1,92 @@
+package login
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// limit is the amount of requests allowed within a window.
+type limit struct {
+	name     string
+	requests int64
+	window   time.Duration
+}
+
+var (
+	startEmailLimit = limit{name: "start_email", requests: 5, window: time.Hour}
+	startPhoneLimit = limit{name: "start_phone", requests: 3, window: time.Hour}
+	startIPLimit    = limit{name: "start_ip", requests: 30, window: time.Hour}
+
+	// pinEmailLimit limits the pins entered for an email or a phone number.
+	pinEmailLimit = limit{name: "pin_email", requests: 15, window: time.Hour}
+	pinIPLimit    = limit{name: "pin_ip", requests: 30, window: 15 * time.Minute}
+)
+
+// resendCooldown is how long users wait before another pin is sent to them.
+const resendCooldown = time.Minute
+
+// Limiter rate limits login requests.
+type Limiter struct {
+	rdb *redis.Client
+}
+
+// NewLimiter creates a new login limiter
+func NewLimiter(rdb *redis.Client) *Limiter {
+	return &Limiter{rdb: rdb}
+}
+
+// Allow counts a request for the identifier, it returns false with the time until the window resets once the limit is exceeded.
+func (l *Limiter) Allow(limit limit, identifier string) (bool, time.Duration, error) {
+	ctx := l.rdb.Context()
+	key := limiterKey(limit.name, identifier)
+
+	// the window starts with the first request, it is created with its expiration so a counter never outlives it.
+	pipe := l.rdb.TxPipeline()
+	pipe.SetNX(ctx, key, 0, limit.window)
+	incr := pipe.Incr(ctx, key)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+
+	count := incr.Val()
+	if count <= limit.requests {
+		return true, 0, nil
+	}
+
+	ttl, err := l.rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return false, ttl, nil
+}
+
+// Cooldown starts a cooldown for the email or phone number unless one is running, it returns false with the remaining time if one is.
+func (l *Limiter) Cooldown(identifier string) (bool, time.Duration, error) {
+	ctx := l.rdb.Context()
+	key := limiterKey("resend", identifier)
+
+	set, err := l.rdb.SetNX(ctx, key, true, resendCooldown).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	if set {
+		return true, 0, nil
+	}
+
+	ttl, err := l.rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, 0, err
+	}
+
+	return false, ttl, nil
+}
+
+func limiterKey(name, identifier string) string {
+	return fmt.Sprintf("login_limit_%s_%s", name, identifier)
+}
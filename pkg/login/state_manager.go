@@ -6,15 +6,38 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/apple"
 )
 
 const pinExpiration = 15 * time.Minute
 
+// maxPinAttempts is the amount of incorrect pins after which the login state is invalidated.
+const maxPinAttempts = 5
+
 // State represents the user login state
 type State struct {
-	Email       string
-	AppleUserID string
-	Pin         string
+	Email string
+	Pin   string
+
+	// Phone is set instead of the email when logging in with a phone number.
+	Phone string `json:",omitempty"`
+
+	// Provider and Subject identify the user with a login provider when registering with one.
+	Provider string
+	Subject  string
+
+	// AppleUserID is set by registration states created before other providers were supported.
+	AppleUserID string `json:",omitempty"`
+}
+
+// Identifier returns the phone number or email the user logs in with.
+func (s *State) Identifier() string {
+	if s.Phone != "" {
+		return s.Phone
+	}
+
+	return s.Email
 }
 
 // StateManager is responsible for handling the login state of a user
@@ -40,73 +63,80 @@ func (sm *StateManager) GetState(token string) (*State, error) {
 		return nil, err
 	}
 
+	if state.AppleUserID != "" && state.Provider == "" {
+		state.Provider = apple.Provider
+		state.Subject = state.AppleUserID
+	}
+
 	return state, nil
 }
 
 // SetPinState sets the login pin state
 func (sm *StateManager) SetPinState(token, email, pin string) error {
-	state := &State{
-		Pin:   pin,
-		Email: email,
-	}
-
-	data, err := json.Marshal(state)
-	if err != nil {
-		return err
-	}
-
-	_, err = sm.rdb.Set(sm.rdb.Context(), key(token), data, pinExpiration).Result()
-	if err != nil {
-		return err
-	}
+	return sm.setState(token, &State{Pin: pin, Email: email}, pinExpiration)
+}
 
-	return nil
+// SetPhonePinState sets the login pin state for a phone number
+func (sm *StateManager) SetPhonePinState(token, phone, pin string) error {
+	return sm.setState(token, &State{Pin: pin, Phone: phone}, pinExpiration)
 }
 
 // SetRegistrationState sets the registration state
 func (sm *StateManager) SetRegistrationState(token, email string) error {
-	state := &State{
-		Email: email,
-	}
-
-	data, err := json.Marshal(state)
-	if err != nil {
-		return err
-	}
+	return sm.setState(token, &State{Email: email}, 0)
+}
 
-	_, err = sm.rdb.Set(sm.rdb.Context(), key(token), data, 0).Result()
-	if err != nil {
-		return err
-	}
+// SetPhoneRegistrationState sets the registration state for a phone number
+func (sm *StateManager) SetPhoneRegistrationState(token, phone string) error {
+	return sm.setState(token, &State{Phone: phone}, 0)
+}
 
-	return nil
+// SetIdentityRegistrationState starts the registration state for a user of a login provider
+func (sm *StateManager) SetIdentityRegistrationState(token, email, provider, subject string) error {
+	return sm.setState(token, &State{Email: email, Provider: provider, Subject: subject}, 0)
 }
 
-// SetAppleRegistrationState starts the registration state for apple
-func (sm *StateManager) SetAppleRegistrationState(token, email, userID string) error {
-	state := &State{
-		Email:       email,
-		AppleUserID: userID,
-	}
+// FailedPinAttempt records an incorrect pin for the token and returns the remaining attempts.
+// The state is removed once no attempts remain.
+func (sm *StateManager) FailedPinAttempt(token string) (int, error) {
+	ctx := sm.rdb.Context()
 
-	data, err := json.Marshal(state)
+	pipe := sm.rdb.TxPipeline()
+	attempts := pipe.Incr(ctx, attemptsKey(token))
+	pipe.Expire(ctx, attemptsKey(token), pinExpiration)
+
+	_, err := pipe.Exec(ctx)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = sm.rdb.Set(sm.rdb.Context(), key(token), data, 0).Result()
-	if err != nil {
-		return err
+	remaining := maxPinAttempts - int(attempts.Val())
+	if remaining <= 0 {
+		sm.RemoveState(token)
+		return 0, nil
 	}
 
-	return nil
+	return remaining, nil
 }
 
 // RemoveState removes the state
 func (sm *StateManager) RemoveState(token string) {
-	sm.rdb.Del(sm.rdb.Context(), key(token))
+	sm.rdb.Del(sm.rdb.Context(), key(token), attemptsKey(token))
+}
+
+func (sm *StateManager) setState(token string, state *State, expiration time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return sm.rdb.Set(sm.rdb.Context(), key(token), data, expiration).Err()
 }
 
 func key(token string) string {
 	return fmt.Sprintf("login_state_%s", token)
 }
+
+func attemptsKey(token string) string {
+	return fmt.Sprintf("login_state_attempts_%s", token)
+}
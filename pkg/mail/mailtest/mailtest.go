@@ -0,0 +1,117 @@
+// Package mailtest provides an SMTP sink for testing code that sends emails.
+package mailtest
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Server is an SMTP server that accepts every email and keeps it in memory.
+type Server struct {
+	// Addr is the address the server listens on, in the form of `host:port`.
+	Addr string
+
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []*netmail.Message
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a random local port, it should be closed once done.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Addr: listener.Addr().String(), listener: listener}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Messages returns the received emails in the order they arrived.
+func (s *Server) Messages() []*netmail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*netmail.Message{}, s.messages...)
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost mailtest")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 localhost")
+		case "MAIL", "RCPT", "RSET", "NOOP":
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			message, err := netmail.ReadMessage(bufio.NewReader(bytes.NewReader(data)))
+			if err != nil {
+				_ = tp.PrintfLine("554 invalid message")
+				continue
+			}
+
+			s.mu.Lock()
+			s.messages = append(s.messages, message)
+			s.mu.Unlock()
+
+			_ = tp.PrintfLine("250 OK")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 command not implemented")
+		}
+	}
+}
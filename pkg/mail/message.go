@@ -0,0 +1,26 @@
+package mail
+
+import (
+	"errors"
+	netmail "net/mail"
+)
+
+// ErrTemporaryFailure is returned when an email was not accepted but sending it again later may succeed.
+var ErrTemporaryFailure = errors.New("temporary failure sending email")
+
+// Message is an email with a plain text and an optional HTML body.
+type Message struct {
+	From    netmail.Address
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+
+	// Headers are additional headers, for example `List-Unsubscribe`.
+	Headers map[string]string
+}
+
+// Sender delivers emails.
+type Sender interface {
+	Send(message Message) error
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// SendGridSender sends emails using the SendGrid API.
+type SendGridSender struct {
+	client *sendgrid.Client
+}
+
+func NewSendGridSender(client *sendgrid.Client) *SendGridSender {
+	return &SendGridSender{client: client}
+}
+
+func (s *SendGridSender) Send(message Message) error {
+	m := mail.NewV3Mail()
+	m.SetFrom(mail.NewEmail(message.From.Name, message.From.Address))
+	m.Subject = message.Subject
+
+	p := mail.NewPersonalization()
+	p.AddTos(mail.NewEmail("", message.To))
+	m.AddPersonalizations(p)
+
+	m.AddContent(mail.NewContent("text/plain", message.Text))
+	if message.HTML != "" {
+		m.AddContent(mail.NewContent("text/html", message.HTML))
+	}
+
+	for key, value := range message.Headers {
+		m.SetHeader(key, value)
+	}
+
+	resp, err := s.client.Send(m)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return ErrTemporaryFailure
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("failed to send email %v", resp.Body)
+	}
+
+	return nil
+}
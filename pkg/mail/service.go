@@ -2,6 +2,7 @@ package mail
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
@@ -37,3 +38,32 @@ func (s *Service) SendPinEmail(recipient, pin string) error {
 
 	return nil
 }
+
+func (s *Service) sendAlert(recipient, subject, text string) error {
+	m := mail.NewSingleEmailPlainText(
+		mail.NewEmail("GeniusCafe.iD", "Services@GeniusCafe.iD"),
+		subject,
+		mail.NewEmail("", recipient),
+		text,
+	)
+
+	resp, err := s.client.Send(m)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("failed to send email %v", resp.Body)
+	}
+
+	return nil
+}
+
+// SendExportEmail sends the link an export of the users data is downloaded with.
+func (s *Service) SendExportEmail(recipient, link string, expires time.Duration) error {
+	return s.sendAlert(
+		recipient,
+		"Your data is ready",
+		fmt.Sprintf("The export of your data is ready. Download it within %d hours: %s", int(expires.Hours()), link),
+	)
+}
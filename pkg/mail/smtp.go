@@ -0,0 +1,147 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends emails to an SMTP server, for example a local sink during development.
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates a sender for the server at addr, authentication is only used when a username is set.
+func NewSMTPSender(addr, username, password string) *SMTPSender {
+	s := &SMTPSender{addr: addr}
+
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return s
+}
+
+func (s *SMTPSender) Send(message Message) error {
+	data, err := encode(message)
+	if err != nil {
+		return err
+	}
+
+	err = smtp.SendMail(s.addr, s.auth, message.From.Address, []string{message.To}, data)
+	if err == nil {
+		return nil
+	}
+
+	// 4xx replies are transient according to RFC 5321.
+	if tpErr, ok := err.(*textproto.Error); ok && tpErr.Code >= 400 && tpErr.Code < 500 {
+		return ErrTemporaryFailure
+	}
+
+	return err
+}
+
+// encode creates a multipart/alternative MIME message.
+func encode(message Message) ([]byte, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	err := writePart(writer, "text/plain", message.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.HTML != "" {
+		err = writePart(writer, "text/html", message.HTML)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := messageID(message.From.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string]string{
+		"From":         message.From.String(),
+		"To":           message.To,
+		"Subject":      mime.QEncoding.Encode("utf-8", message.Subject),
+		"Date":         time.Now().Format(time.RFC1123Z),
+		"Message-ID":   id,
+		"MIME-Version": "1.0",
+		"Content-Type": fmt.Sprintf("multipart/alternative; boundary=%q", writer.Boundary()),
+	}
+
+	for key, value := range message.Headers {
+		headers[textproto.CanonicalMIMEHeaderKey(key)] = value
+	}
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	data := &bytes.Buffer{}
+	for _, key := range keys {
+		fmt.Fprintf(data, "%s: %s\r\n", key, headers[key])
+	}
+
+	data.WriteString("\r\n")
+	data.Write(body.Bytes())
+
+	return data.Bytes(), nil
+}
+
+func writePart(writer *multipart.Writer, contentType, content string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	_, err = qp.Write([]byte(content))
+	if err != nil {
+		return err
+	}
+
+	return qp.Close()
+}
+
+func messageID(from string) (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain), nil
+}
@@ -0,0 +1,80 @@
+package mail_test
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"strings"
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/mail/mailtest"
+)
+
+func TestSMTPSender_Send(t *testing.T) {
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	sender := mail.NewSMTPSender(server.Addr, "", "")
+
+	err = sender.Send(mail.Message{
+		From:    netmail.Address{Name: "Soapbox", Address: "no-reply@soapbox.social"},
+		To:      "foo@example.com",
+		Subject: "Grüße",
+		Text:    "hello",
+		HTML:    "<p>hello</p>",
+		Headers: map[string]string{"List-Unsubscribe-Post": "List-Unsubscribe=One-Click"},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message actual %d", len(messages))
+	}
+
+	message := messages[0]
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject != "Grüße" {
+		t.Fatalf("unexpected subject %s", subject)
+	}
+
+	if message.Header.Get("List-Unsubscribe-Post") != "List-Unsubscribe=One-Click" {
+		t.Fatalf("unexpected header %s", message.Header.Get("List-Unsubscribe-Post"))
+	}
+
+	_, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := multipart.NewReader(message.Body, params["boundary"])
+
+	expected := []string{"hello", "<p>hello</p>"}
+	for _, text := range expected {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.TrimSpace(string(body)) != text {
+			t.Fatalf("expected %s actual %s", text, body)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package me
 
 import (
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -8,10 +10,13 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	"github.com/soapboxsocial/soapbox/pkg/activeusers"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
 	"github.com/soapboxsocial/soapbox/pkg/linkedaccounts"
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 
 	// "github.com/soapboxsocial/soapbox/pkg/recommendations/follows"
@@ -20,15 +25,21 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/users/types"
 )
 
+const (
+	defaultNotificationsLimit = 20
+	maxNotificationsLimit     = 100
+)
+
 type Endpoint struct {
 	users *users.Backend
 	ns    *notifications.Storage
 	// oauthConfig     *oauth1.Config
-	la      *linkedaccounts.Backend
-	stories *stories.Backend
-	queue   *pubsub.Queue
-	actives *activeusers.Backend
-	targets *notifications.Settings
+	la        *linkedaccounts.Backend
+	stories   *stories.Backend
+	queue     *pubsub.Queue
+	actives   *activeusers.Backend
+	targets   *notifications.Settings
+	templates *templates.Registry
 	// recommendations *follows.Backend
 }
 
@@ -50,10 +61,15 @@ type Me struct {
 // For example:
 //   - terms of service updates?
 type Notification struct {
+	ID        int                                `json:"id"`
 	Timestamp int64                              `json:"timestamp"`
 	From      *users.NotificationUser            `json:"from"`
 	Room      *string                            `json:"room,omitempty"`
 	Category  notifications.NotificationCategory `json:"category"`
+	Alert     notifications.Alert                `json:"alert"`
+	Text      string                             `json:"text,omitempty"`
+	Actors    []*users.NotificationUser          `json:"actors,omitempty"`
+	Read      bool                               `json:"read"`
 }
 
 func NewEndpoint(
@@ -65,26 +81,34 @@ func NewEndpoint(
 	queue *pubsub.Queue,
 	actives *activeusers.Backend,
 	targets *notifications.Settings,
+	templates *templates.Registry,
 	// recommendations *follows.Backend,
 ) *Endpoint {
 	return &Endpoint{
 		users: users,
 		ns:    ns,
 		// oauthConfig:     config,
-		la:      la,
-		stories: backend,
-		queue:   queue,
-		actives: actives,
-		targets: targets,
+		la:        la,
+		stories:   backend,
+		queue:     queue,
+		actives:   actives,
+		targets:   targets,
+		templates: templates,
 		// recommendations: recommendations,
 	}
 }
 
 func (m *Endpoint) Router() *mux.Router {
 	r := mux.NewRouter()
+	scopes := middlewares.NewScopes()
 
-	r.HandleFunc("/", m.me).Methods("GET")
+	scopes.Require(r.HandleFunc("/", m.me).Methods("GET"), accesstokens.ScopeProfileRead)
 	r.HandleFunc("/notifications", m.notifications).Methods("GET")
+	r.HandleFunc("/notifications/unread", m.unreadNotifications).Methods("GET")
+	r.HandleFunc("/notifications/read", m.readAllNotifications).Methods("POST")
+	r.HandleFunc("/notifications/{id:[0-9]+}/read", m.readNotification).Methods("POST")
+	r.HandleFunc("/notifications/{id:[0-9]+}/read", m.unreadNotification).Methods("DELETE")
+	r.HandleFunc("/notifications/{id:[0-9]+}", m.deleteNotification).Methods("DELETE")
 	// r.HandleFunc("/profiles/twitter", m.addTwitter).Methods("POST")
 	// r.HandleFunc("/profiles/twitter", m.removeTwitter).Methods("DELETE")
 	r.HandleFunc("/feed", m.feed).Methods("GET")
@@ -92,6 +116,9 @@ func (m *Endpoint) Router() *mux.Router {
 	r.HandleFunc("/settings", m.settings).Methods("GET")
 	// r.HandleFunc("/following/recommendations", m.followingRecommendations).Methods("GET")
 	r.HandleFunc("/settings/notifications", m.updateNotificationSettings).Methods("POST")
+	r.HandleFunc("/settings/notifications/preferences", m.updateNotificationPreferences).Methods("POST")
+
+	r.Use(scopes.Middleware)
 
 	return r
 }
@@ -132,15 +159,34 @@ func (m *Endpoint) notifications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	list, err := m.ns.GetNotifications(id)
+	cursor := httputil.GetInt(r.URL.Query(), "cursor", 0)
+	limit := httputil.GetInt(r.URL.Query(), "limit", defaultNotificationsLimit)
+	if limit <= 0 || limit > maxNotificationsLimit {
+		limit = defaultNotificationsLimit
+	}
+
+	list, err := m.ns.GetNotifications(id, cursor, limit)
 	if err != nil {
 		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeFailedToGetUser, "failed to get self")
 		return
 	}
 
+	locale := m.localeFor(id, r.URL.Query().Get("locale"))
+
 	populated := make([]Notification, 0)
 	for _, notification := range list {
-		populatedNotification := Notification{Timestamp: notification.Timestamp, Category: notification.Category}
+		populatedNotification := Notification{
+			ID:        notification.ID,
+			Timestamp: notification.Timestamp,
+			Category:  notification.Category,
+			Alert:     notification.Alert,
+			Read:      notification.Read,
+		}
+
+		text, err := m.templates.RenderAlert(locale, notification.Alert)
+		if err == nil {
+			populatedNotification.Text = text
+		}
 
 		if notification.From != 0 {
 			from, err := m.users.NotificationUserFor(notification.From)
@@ -152,22 +198,143 @@ func (m *Endpoint) notifications(w http.ResponseWriter, r *http.Request) {
 			populatedNotification.From = from
 		}
 
-		if notification.Category == notifications.WELCOME_ROOM {
-			room := notification.Arguments["room"].(string)
-			populatedNotification.Room = &room
+		for _, actor := range notification.Actors {
+			user, err := m.users.NotificationUserFor(actor)
+			if err != nil {
+				log.Printf("users.NotificationUserFor err: %v\n", err)
+				continue
+			}
+
+			populatedNotification.Actors = append(populatedNotification.Actors, user)
+		}
+
+		switch notification.Category {
+		case notifications.NEW_ROOM, notifications.ROOM_INVITE, notifications.ROOM_JOINED, notifications.WELCOME_ROOM, notifications.TRENDING_ROOM:
+			if room, ok := notification.Arguments["id"].(string); ok {
+				populatedNotification.Room = &room
+			}
 		}
 
 		populated = append(populated, populatedNotification)
 	}
 
-	m.ns.MarkNotificationsViewed(id)
-
 	err = httputil.JsonEncode(w, populated)
 	if err != nil {
 		log.Printf("failed to write me response: %s\n", err.Error())
 	}
 }
 
+// localeFor returns the requested locale if it is valid, otherwise the locale from the notification settings.
+func (m *Endpoint) localeFor(id int, requested string) string {
+	if notifications.IsValidLocale(requested) {
+		return requested
+	}
+
+	target, err := m.targets.GetSettingsFor(id)
+	if err != nil {
+		return templates.DefaultLocale
+	}
+
+	return target.Locale
+}
+
+func (m *Endpoint) unreadNotifications(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	count, err := m.ns.UnreadCount(id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	err = httputil.JsonEncode(w, map[string]int{"count": count})
+	if err != nil {
+		log.Printf("httputil.JsonEncode err: %s", err)
+	}
+}
+
+func (m *Endpoint) readAllNotifications(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err := m.ns.MarkAllRead(id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (m *Endpoint) readNotification(w http.ResponseWriter, r *http.Request) {
+	m.markNotification(w, r, true)
+}
+
+func (m *Endpoint) unreadNotification(w http.ResponseWriter, r *http.Request) {
+	m.markNotification(w, r, false)
+}
+
+func (m *Endpoint) markNotification(w http.ResponseWriter, r *http.Request, read bool) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	notification, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = m.ns.MarkRead(id, notification, read)
+	if err == sql.ErrNoRows {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "notification not found")
+		return
+	}
+
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (m *Endpoint) deleteNotification(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	notification, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = m.ns.Delete(id, notification)
+	if err == sql.ErrNoRows {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "notification not found")
+		return
+	}
+
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
 // func (m *Endpoint) addTwitter(w http.ResponseWriter, r *http.Request) {
 // 	err := r.ParseForm()
 // 	if err != nil {
@@ -295,6 +462,8 @@ func (m *Endpoint) settings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	target.Preferences = target.AllPreferences()
+
 	err = httputil.JsonEncode(w, &Settings{Notifications: *target})
 	if err != nil {
 		log.Printf("httputil.JsonEncode err: %s", err)
@@ -338,9 +507,108 @@ func (m *Endpoint) updateNotificationSettings(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	locale := r.Form.Get("locale")
+	if locale != "" {
+		if !notifications.IsValidLocale(locale) {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid locale")
+			return
+		}
+
+		err = m.targets.UpdateLocaleFor(id, locale)
+		if err != nil {
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+			return
+		}
+	}
+
+	// older clients do not send quiet hours, in which case we keep the stored ones.
+	timezone := r.Form.Get("timezone")
+	if timezone == "" {
+		httputil.JsonSuccess(w)
+		return
+	}
+
+	if !notifications.IsValidTimezone(timezone) {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid timezone")
+		return
+	}
+
+	quietHours, err := parseQuietHours(r)
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid quiet hours")
+		return
+	}
+
+	err = m.targets.UpdateQuietHoursFor(id, timezone, *quietHours)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
 	httputil.JsonSuccess(w)
 }
 
+func (m *Endpoint) updateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "unauthorized")
+		return
+	}
+
+	category := notifications.NotificationCategory(r.Form.Get("category"))
+	if !notifications.IsConfigurableCategory(category) {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid category")
+		return
+	}
+
+	preferences := notifications.ChannelPreferences{}
+	for channel, value := range map[string]*bool{"push": &preferences.Push, "inbox": &preferences.Inbox, "email": &preferences.Email} {
+		*value, err = strconv.ParseBool(r.Form.Get(channel))
+		if err != nil {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid "+channel)
+			return
+		}
+	}
+
+	err = m.targets.UpdatePreferencesFor(id, category, preferences)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func parseQuietHours(r *http.Request) (*notifications.QuietHours, error) {
+	enabled, err := strconv.ParseBool(r.Form.Get("quiet_hours"))
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := strconv.Atoi(r.Form.Get("quiet_hours_start"))
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := strconv.Atoi(r.Form.Get("quiet_hours_end"))
+	if err != nil {
+		return nil, err
+	}
+
+	quietHours := &notifications.QuietHours{Enabled: enabled, Start: start, End: end}
+	if !quietHours.IsValid() {
+		return nil, errors.New("quiet hours out of range")
+	}
+
+	return quietHours, nil
+}
+
 // func (m *Endpoint) followingRecommendations(w http.ResponseWriter, r *http.Request) {
 // 	id, ok := httputil.GetUserIDFromContext(r.Context())
 // 	if !ok {
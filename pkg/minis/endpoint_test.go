@@ -13,7 +13,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 
 	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
@@ -43,10 +43,10 @@ func TestEndpoint_ListMinis(t *testing.T) {
 	})
 
 	sm := sessions.NewSessionManager(rdb)
-	mw := middlewares.NewAuthenticationMiddleware(sm)
+	mw := middlewares.NewAuthenticationMiddleware(sm, nil)
 
 	auth := "12345"
-	_ = sm.NewSession(auth, 1, 0)
+	_, _ = sm.NewSession(auth, 1, sessions.Metadata{})
 
 	endpoint := minis.NewEndpoint(minis.NewBackend(db), mw, nil)
 
@@ -90,10 +90,10 @@ func TestEndpoint_ListMinis_Error(t *testing.T) {
 	})
 
 	sm := sessions.NewSessionManager(rdb)
-	mw := middlewares.NewAuthenticationMiddleware(sm)
+	mw := middlewares.NewAuthenticationMiddleware(sm, nil)
 
 	auth := "12345"
-	_ = sm.NewSession(auth, 1, 0)
+	_, _ = sm.NewSession(auth, 1, sessions.Metadata{})
 
 	endpoint := minis.NewEndpoint(minis.NewBackend(db), mw, nil)
 
@@ -136,7 +136,7 @@ func TestEndpoint_SaveScores(t *testing.T) {
 	keys := make(minis.AuthKeys)
 	keys[key] = mini
 
-	endpoint := minis.NewEndpoint(minis.NewBackend(db), middlewares.NewAuthenticationMiddleware(nil), keys)
+	endpoint := minis.NewEndpoint(minis.NewBackend(db), middlewares.NewAuthenticationMiddleware(nil, nil), keys)
 
 	rr := httptest.NewRecorder()
 	handler := endpoint.Router()
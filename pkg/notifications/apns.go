@@ -1,5 +1,82 @@
 package notifications
 
+import (
+	"fmt"
+	"strings"
+)
+
 type APNS interface {
 	Send(target string, notification PushNotification) error
 }
+
+// InterruptionLevel is how prominently iOS presents a notification, it requires iOS 15.
+type InterruptionLevel string
+
+const (
+	InterruptionPassive       InterruptionLevel = "passive"
+	InterruptionActive        InterruptionLevel = "active"
+	InterruptionTimeSensitive InterruptionLevel = "time-sensitive"
+)
+
+// presentation is how notifications of a category are presented on iOS.
+type presentation struct {
+	level InterruptionLevel
+
+	// relevance ranks notifications in the summary, from 0 to 1.
+	relevance float64
+}
+
+var defaultPresentation = presentation{level: InterruptionActive, relevance: 0.5}
+
+var presentations = map[NotificationCategory]presentation{
+	ROOM_INVITE:            {level: InterruptionTimeSensitive, relevance: 1},
+	ACCOUNT_SECURITY:       {level: InterruptionTimeSensitive, relevance: 1},
+	NEW_ROOM:               {level: InterruptionTimeSensitive, relevance: 0.8},
+	WELCOME_ROOM:           {level: InterruptionTimeSensitive, relevance: 0.7},
+	ROOM_JOINED:            {level: InterruptionTimeSensitive, relevance: 0.6},
+	TRENDING_ROOM:          {level: InterruptionActive, relevance: 0.5},
+	STORY_REACTION:         {level: InterruptionActive, relevance: 0.5},
+	NEW_FOLLOWER:           {level: InterruptionActive, relevance: 0.4},
+	NEW_FOLLOWER_DIGEST:    {level: InterruptionActive, relevance: 0.4},
+	NEW_STORY:              {level: InterruptionActive, relevance: 0.3},
+	NEW_STORY_DIGEST:       {level: InterruptionActive, relevance: 0.3},
+	INFO:                   {level: InterruptionPassive, relevance: 0.2},
+	FOLLOW_RECOMMENDATIONS: {level: InterruptionPassive, relevance: 0.1},
+	REENGAGEMENT:           {level: InterruptionPassive, relevance: 0.1},
+}
+
+// IsSilent returns whether notifications of a category are background pushes that are not shown to the user.
+func IsSilent(category NotificationCategory) bool {
+	return category == FEED_REFRESH
+}
+
+// InterruptionLevel returns how prominently the notification is presented.
+func (n PushNotification) InterruptionLevel() InterruptionLevel {
+	return presentationFor(n.Category).level
+}
+
+// RelevanceScore returns how the notification ranks in the notification summary.
+func (n PushNotification) RelevanceScore() float64 {
+	return presentationFor(n.Category).relevance
+}
+
+// ThreadID returns the identifier notifications are grouped by, room notifications are grouped per room and stories per user.
+// All other notifications are grouped with those of the same category.
+func (n PushNotification) ThreadID() string {
+	switch n.Category {
+	case NEW_ROOM, ROOM_JOINED, ROOM_INVITE, WELCOME_ROOM, TRENDING_ROOM:
+		return fmt.Sprintf("room_%v", n.Arguments["id"])
+	case NEW_STORY:
+		return fmt.Sprintf("user_%v", n.Arguments["id"])
+	default:
+		return strings.ToLower(string(PreferenceCategory(n.Category)))
+	}
+}
+
+func presentationFor(category NotificationCategory) presentation {
+	if p, ok := presentations[category]; ok {
+		return p
+	}
+
+	return defaultPresentation
+}
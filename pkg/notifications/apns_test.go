@@ -0,0 +1,41 @@
+package notifications_test
+
+import (
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestPushNotification_ThreadID(t *testing.T) {
+	var tests = []struct {
+		notification *notifications.PushNotification
+		thread       string
+	}{
+		{notifications.NewRoomInviteNotification("xyz", "foo"), "room_xyz"},
+		{notifications.NewRoomJoinedNotification("xyz", 1, "", []string{"foo"}, 1), "room_xyz"},
+		{notifications.NewStoryNotification(12, "foo"), "user_12"},
+		{notifications.NewStoryDigestNotification([]notifications.DigestActor{{ID: 12, Name: "foo"}}), "new_story"},
+		{notifications.NewFollowerDigestNotification([]notifications.DigestActor{{ID: 12, Name: "foo"}}), "new_follower"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.thread, func(t *testing.T) {
+			thread := tt.notification.ThreadID()
+			if thread != tt.thread {
+				t.Fatalf("expected %s actual %s", tt.thread, thread)
+			}
+		})
+	}
+}
+
+func TestPushNotification_InterruptionLevel(t *testing.T) {
+	notification := notifications.NewRoomInviteNotification("xyz", "foo")
+	if notification.InterruptionLevel() != notifications.InterruptionTimeSensitive {
+		t.Fatalf("unexpected interruption level %s", notification.InterruptionLevel())
+	}
+
+	notification = &notifications.PushNotification{Category: notifications.TEST}
+	if notification.InterruptionLevel() != notifications.InterruptionActive || notification.RelevanceScore() != 0.5 {
+		t.Fatal("expected default presentation")
+	}
+}
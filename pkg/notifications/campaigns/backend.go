@@ -0,0 +1,444 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// Status is the state of a campaign.
+type Status string
+
+const (
+	StatusScheduled Status = "scheduled"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusCancelled Status = "cancelled"
+)
+
+// Campaign is a notification sent to a segment.
+type Campaign struct {
+	ID        int
+	Name      string
+	Segment   Segment
+	Category  notifications.NotificationCategory
+	Body      string
+	Scheduled time.Time
+	Status    Status
+
+	// Rate is the maximum amount of notifications sent per minute.
+	Rate int
+}
+
+// Notification returns the notification sent to every user in the audience.
+func (c Campaign) Notification() *notifications.PushNotification {
+	return &notifications.PushNotification{
+		Category: c.Category,
+		Alert:    notifications.Alert{Body: c.Body},
+		Campaign: c.ID,
+	}
+}
+
+// Report is the delivery and open statistics of a campaign.
+type Report struct {
+	Audience int
+	Sent     int
+	Opened   int
+}
+
+// OpenRate returns the share of sent notifications that were opened.
+func (r Report) OpenRate() float64 {
+	if r.Sent == 0 {
+		return 0
+	}
+
+	return float64(r.Opened) / float64(r.Sent)
+}
+
+const campaignColumns = "notification_campaigns.id, notification_campaigns.name, notification_campaigns.category, notification_campaigns.body, notification_campaigns.scheduled, notification_campaigns.rate, notification_campaigns.status, " +
+	"notification_segments.id, notification_segments.name, notification_segments.filters"
+
+const campaignJoin = " FROM notification_campaigns INNER JOIN notification_segments ON notification_campaigns.segment_id = notification_segments.id"
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+func (b *Backend) CreateSegment(segment Segment) (int, error) {
+	err := segment.Validate()
+	if err != nil {
+		return 0, err
+	}
+
+	filters, err := json.Marshal(segment.Filters)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := b.db.Prepare("INSERT INTO notification_segments (name, filters) VALUES ($1, $2) RETURNING id;")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = stmt.QueryRow(segment.Name, filters).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (b *Backend) GetSegmentByName(name string) (*Segment, error) {
+	stmt, err := b.db.Prepare("SELECT id, name, filters FROM notification_segments WHERE name = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSegment(stmt.QueryRow(name))
+}
+
+func (b *Backend) ListSegments() ([]Segment, error) {
+	stmt, err := b.db.Prepare("SELECT id, name, filters FROM notification_segments ORDER BY name;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]Segment, 0)
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, *segment)
+	}
+
+	return segments, nil
+}
+
+func (b *Backend) DeleteSegment(name string) error {
+	stmt, err := b.db.Prepare("DELETE FROM notification_segments WHERE name = $1;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(name)
+	return err
+}
+
+// AudienceSize returns the amount of users in a segment.
+func (b *Backend) AudienceSize(segment Segment) (int, error) {
+	where, args, err := segment.where(0)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := b.db.Prepare("SELECT COUNT(*) FROM users WHERE " + where + ";")
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = stmt.QueryRow(args...).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Audience returns the IDs of all users in a segment.
+func (b *Backend) Audience(segment Segment) ([]int64, error) {
+	where, args, err := segment.where(0)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := b.db.Prepare("SELECT users.id FROM users WHERE " + where + ";")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (b *Backend) CreateCampaign(campaign Campaign) (int, error) {
+	stmt, err := b.db.Prepare("INSERT INTO notification_campaigns (name, segment_id, category, body, scheduled, rate) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id;")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = stmt.QueryRow(campaign.Name, campaign.Segment.ID, campaign.Category, campaign.Body, campaign.Scheduled, campaign.Rate).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (b *Backend) GetCampaign(id int) (*Campaign, error) {
+	stmt, err := b.db.Prepare("SELECT " + campaignColumns + campaignJoin + " WHERE notification_campaigns.id = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	return scanCampaign(stmt.QueryRow(id))
+}
+
+func (b *Backend) ListCampaigns() ([]Campaign, error) {
+	return b.getCampaigns("SELECT " + campaignColumns + campaignJoin + " ORDER BY notification_campaigns.scheduled DESC;")
+}
+
+// DueCampaigns returns the scheduled campaigns that should be started.
+func (b *Backend) DueCampaigns(now time.Time) ([]Campaign, error) {
+	return b.getCampaigns("SELECT "+campaignColumns+campaignJoin+" WHERE notification_campaigns.status = $1 AND notification_campaigns.scheduled <= $2;", StatusScheduled, now)
+}
+
+func (b *Backend) RunningCampaigns() ([]Campaign, error) {
+	return b.getCampaigns("SELECT "+campaignColumns+campaignJoin+" WHERE notification_campaigns.status = $1;", StatusRunning)
+}
+
+// CancelCampaign stops a campaign that has not completed, sql.ErrNoRows is returned if there is none.
+func (b *Backend) CancelCampaign(id int) error {
+	stmt, err := b.db.Prepare("UPDATE notification_campaigns SET status = $1 WHERE id = $2 AND status IN ($3, $4);")
+	if err != nil {
+		return err
+	}
+
+	res, err := stmt.Exec(StatusCancelled, id, StatusScheduled, StatusRunning)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// StartCampaign stores the audience of a scheduled campaign and marks it as running.
+// The audience is resolved once, so users entering the segment later do not receive it.
+func (b *Backend) StartCampaign(campaign Campaign) error {
+	where, args, err := campaign.Segment.where(1)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE notification_campaigns SET status = $1 WHERE id = $2 AND status = $3;", StatusRunning, campaign.ID, StatusScheduled)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil || count == 0 {
+		// another runner started the campaign, or it was cancelled.
+		_ = tx.Rollback()
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO notification_campaign_targets (campaign_id, user_id) SELECT $1, users.id FROM users WHERE "+where+" ON CONFLICT DO NOTHING;",
+		append([]interface{}{campaign.ID}, args...)...,
+	)
+
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// NextTargets claims up to limit users of a running campaign that have not been sent to yet.
+func (b *Backend) NextTargets(campaign, limit int) ([]int64, error) {
+	stmt, err := b.db.Prepare(`UPDATE notification_campaign_targets SET sent = NOW()
+		WHERE campaign_id = $1 AND user_id IN (
+			SELECT user_id FROM notification_campaign_targets WHERE campaign_id = $1 AND sent IS NULL LIMIT $2 FOR UPDATE SKIP LOCKED
+		) RETURNING user_id;`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(campaign, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// RecentlySent returns how many users of a campaign were sent to in the last minute.
+func (b *Backend) RecentlySent(campaign int) (int, error) {
+	stmt, err := b.db.Prepare("SELECT COUNT(*) FROM notification_campaign_targets WHERE campaign_id = $1 AND sent > NOW() - INTERVAL '1 minute';")
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = stmt.QueryRow(campaign).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CompleteCampaign marks a running campaign as completed.
+func (b *Backend) CompleteCampaign(id int) error {
+	stmt, err := b.db.Prepare("UPDATE notification_campaigns SET status = $1 WHERE id = $2 AND status = $3;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(StatusCompleted, id, StatusRunning)
+	return err
+}
+
+// Report returns the audience size and how many of the sent notifications were opened.
+func (b *Backend) Report(id int) (*Report, error) {
+	stmt, err := b.db.Prepare(`SELECT
+		(SELECT COUNT(*) FROM notification_campaign_targets WHERE campaign_id = $1),
+		COUNT(*),
+		COUNT(opened)
+		FROM notification_analytics WHERE campaign = $1;`)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	err = stmt.QueryRow(id).Scan(&report.Audience, &report.Sent, &report.Opened)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func (b *Backend) getCampaigns(query string, args ...interface{}) ([]Campaign, error) {
+	stmt, err := b.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	campaigns := make([]Campaign, 0)
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		campaigns = append(campaigns, *campaign)
+	}
+
+	return campaigns, nil
+}
+
+type scanner interface {
+	Scan(...interface{}) error
+}
+
+func scanSegment(row scanner) (*Segment, error) {
+	segment := &Segment{}
+
+	var filters []byte
+	err := row.Scan(&segment.ID, &segment.Name, &filters)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(filters, &segment.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return segment, nil
+}
+
+func scanCampaign(row scanner) (*Campaign, error) {
+	campaign := &Campaign{}
+
+	var filters []byte
+	err := row.Scan(
+		&campaign.ID,
+		&campaign.Name,
+		&campaign.Category,
+		&campaign.Body,
+		&campaign.Scheduled,
+		&campaign.Rate,
+		&campaign.Status,
+		&campaign.Segment.ID,
+		&campaign.Segment.Name,
+		&filters,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(filters, &campaign.Segment.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
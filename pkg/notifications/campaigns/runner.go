@@ -0,0 +1,101 @@
+package campaigns
+
+import (
+	"log"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// Dispatcher queues notifications for delivery.
+type Dispatcher interface {
+	Dispatch(origin int, targets []notifications.Target, notification *notifications.PushNotification)
+}
+
+// Runner starts scheduled campaigns and sends running ones at their rate.
+// Multiple runners can be used, targets are claimed so every user receives a campaign only once.
+type Runner struct {
+	backend    *Backend
+	settings   *notifications.Settings
+	dispatcher Dispatcher
+}
+
+func NewRunner(backend *Backend, settings *notifications.Settings, dispatcher Dispatcher) *Runner {
+	return &Runner{
+		backend:    backend,
+		settings:   settings,
+		dispatcher: dispatcher,
+	}
+}
+
+// Run processes campaigns every interval until the process exits.
+func (r *Runner) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := r.Process(time.Now())
+		if err != nil {
+			log.Printf("campaigns.Process err: %v\n", err)
+		}
+	}
+}
+
+// Process starts due campaigns and sends the next batch of every running campaign.
+func (r *Runner) Process(now time.Time) error {
+	due, err := r.backend.DueCampaigns(now)
+	if err != nil {
+		return err
+	}
+
+	for _, campaign := range due {
+		err := r.backend.StartCampaign(campaign)
+		if err != nil {
+			log.Printf("backend.StartCampaign err: %v\n", err)
+		}
+	}
+
+	running, err := r.backend.RunningCampaigns()
+	if err != nil {
+		return err
+	}
+
+	for _, campaign := range running {
+		err := r.send(campaign)
+		if err != nil {
+			log.Printf("failed to send campaign %d err: %v\n", campaign.ID, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) send(campaign Campaign) error {
+	sent, err := r.backend.RecentlySent(campaign.ID)
+	if err != nil {
+		return err
+	}
+
+	limit := campaign.Rate - sent
+	if limit <= 0 {
+		return nil
+	}
+
+	ids, err := r.backend.NextTargets(campaign.ID, limit)
+	if err != nil {
+		return err
+	}
+
+	if len(ids) == 0 {
+		return r.backend.CompleteCampaign(campaign.ID)
+	}
+
+	targets, err := r.settings.GetSettingsForUsers(ids)
+	if err != nil {
+		return err
+	}
+
+	r.dispatcher.Dispatch(0, targets, campaign.Notification())
+
+	return nil
+}
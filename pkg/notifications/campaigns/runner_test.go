@@ -0,0 +1,150 @@
+package campaigns_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/campaigns"
+)
+
+type dispatch struct {
+	targets      []notifications.Target
+	notification *notifications.PushNotification
+}
+
+type dispatcher struct {
+	dispatched []dispatch
+}
+
+func (d *dispatcher) Dispatch(_ int, targets []notifications.Target, notification *notifications.PushNotification) {
+	d.dispatched = append(d.dispatched, dispatch{targets: targets, notification: notification})
+}
+
+var campaignColumns = []string{"id", "name", "category", "body", "scheduled", "rate", "status", "segment_id", "segment_name", "filters"}
+
+func TestRunner_Process(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	d := &dispatcher{}
+	runner := campaigns.NewRunner(campaigns.NewBackend(db), notifications.NewSettings(db), d)
+
+	now := time.Now()
+	filters := `[{"type": "active_within_days", "value": 7}]`
+
+	mock.ExpectPrepare("^SELECT (.+) WHERE notification_campaigns.status = (.+) AND (.+)").
+		ExpectQuery().
+		WithArgs(campaigns.StatusScheduled, now).
+		WillReturnRows(mock.NewRows(campaignColumns).AddRow(1, "launch", "INFO", "hello", now, 2, "scheduled", 1, "active", filters))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("^UPDATE notification_campaigns (.+)").
+		WithArgs(campaigns.StatusRunning, 1, campaigns.StatusScheduled).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("^INSERT INTO notification_campaign_targets (.+) NOT IN \\(SELECT user_id FROM account_deletions\\)").
+		WithArgs(1, 7).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	mock.ExpectPrepare("^SELECT (.+) WHERE notification_campaigns.status = (.+)").
+		ExpectQuery().
+		WithArgs(campaigns.StatusRunning).
+		WillReturnRows(mock.NewRows(campaignColumns).AddRow(1, "launch", "INFO", "hello", now, 2, "running", 1, "active", filters))
+
+	// one notification was sent within the last minute, so only one more can be sent.
+	mock.ExpectPrepare("^SELECT COUNT(.+)").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(1))
+
+	mock.ExpectPrepare("^UPDATE notification_campaign_targets (.+)").
+		ExpectQuery().
+		WithArgs(1, 1).
+		WillReturnRows(mock.NewRows([]string{"user_id"}).AddRow(5))
+
+	mock.ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				AddRow(5, 2, true, true, "UTC", false, 1320, 420, "en", nil, nil),
+		)
+
+	err = runner.Process(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.dispatched) != 1 {
+		t.Fatalf("expected 1 dispatch actual %d", len(d.dispatched))
+	}
+
+	if len(d.dispatched[0].targets) != 1 || d.dispatched[0].targets[0].ID != 5 {
+		t.Fatalf("unexpected targets %v", d.dispatched[0].targets)
+	}
+
+	n := d.dispatched[0].notification
+	if n.Campaign != 1 || n.Alert.Body != "hello" || n.Category != notifications.INFO {
+		t.Fatalf("unexpected notification %v", n)
+	}
+}
+
+func TestRunner_ProcessCompletesCampaign(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	d := &dispatcher{}
+	runner := campaigns.NewRunner(campaigns.NewBackend(db), notifications.NewSettings(db), d)
+
+	now := time.Now()
+	filters := `[{"type": "follows", "value": 12}]`
+
+	mock.ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows(campaignColumns))
+
+	mock.ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows(campaignColumns).AddRow(1, "launch", "INFO", "hello", now, 100, "running", 1, "followers", filters))
+
+	mock.ExpectPrepare("^SELECT COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(0))
+
+	mock.ExpectPrepare("^UPDATE notification_campaign_targets (.+)").
+		ExpectQuery().
+		WithArgs(1, 100).
+		WillReturnRows(mock.NewRows([]string{"user_id"}))
+
+	mock.ExpectPrepare("^UPDATE notification_campaigns (.+)").
+		ExpectExec().
+		WithArgs(campaigns.StatusCompleted, 1, campaigns.StatusRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = runner.Process(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.dispatched) != 0 {
+		t.Fatalf("expected no dispatch actual %d", len(d.dispatched))
+	}
+}
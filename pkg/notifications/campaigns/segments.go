@@ -0,0 +1,127 @@
+// Package campaigns sends notifications to saved audience segments with a throttled rollout.
+package campaigns
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FilterType is a kind of audience filter, only these types can be used to build segments.
+type FilterType string
+
+const (
+	// FilterActiveWithinDays matches users that were active within the last N days.
+	FilterActiveWithinDays FilterType = "active_within_days"
+
+	// FilterFollows matches users that follow the user with the ID N.
+	FilterFollows FilterType = "follows"
+
+	// FilterMinRoomMinutes matches users that spent at least N minutes in rooms within the room time window.
+	FilterMinRoomMinutes FilterType = "min_room_minutes"
+
+	// FilterMaxRoomMinutes matches users that spent less than N minutes in rooms within the room time window.
+	FilterMaxRoomMinutes FilterType = "max_room_minutes"
+)
+
+// roomTimeWindow is the amount of days room time filters consider.
+const roomTimeWindow = 30
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrNoFilters     = errors.New("segment has no filters")
+)
+
+// Filter restricts the audience of a segment, values are always passed to the database as parameters.
+type Filter struct {
+	Type  FilterType `json:"type"`
+	Value int        `json:"value"`
+}
+
+// Validate returns an error if the filter is unknown or its value is out of range.
+func (f Filter) Validate() error {
+	switch f.Type {
+	case FilterActiveWithinDays:
+		if f.Value < 1 || f.Value > 365 {
+			return ErrInvalidFilter
+		}
+	case FilterFollows, FilterMinRoomMinutes, FilterMaxRoomMinutes:
+		if f.Value < 1 {
+			return ErrInvalidFilter
+		}
+	default:
+		return ErrInvalidFilter
+	}
+
+	return nil
+}
+
+func (f Filter) String() string {
+	return fmt.Sprintf("%s=%d", f.Type, f.Value)
+}
+
+// clause returns the SQL condition for the filter with its value as the parameter at index.
+func (f Filter) clause(index int) string {
+	roomTime := fmt.Sprintf(
+		"SELECT user_id FROM user_room_logs WHERE join_time >= NOW() - INTERVAL '%d days' AND left_time IS NOT NULL GROUP BY user_id HAVING SUM(EXTRACT(EPOCH FROM left_time - join_time)) >= $%d * 60",
+		roomTimeWindow,
+		index,
+	)
+
+	switch f.Type {
+	case FilterActiveWithinDays:
+		return fmt.Sprintf("users.id IN (SELECT user_id FROM user_active_times WHERE last_active >= NOW() - make_interval(days => $%d))", index)
+	case FilterFollows:
+		return fmt.Sprintf("users.id IN (SELECT follower FROM followers WHERE user_id = $%d)", index)
+	case FilterMinRoomMinutes:
+		return "users.id IN (" + roomTime + ")"
+	case FilterMaxRoomMinutes:
+		return "users.id NOT IN (" + roomTime + ")"
+	}
+
+	return ""
+}
+
+// Segment is a named, reusable audience.
+type Segment struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Filters []Filter `json:"filters"`
+}
+
+// Validate returns an error if the segment has no filters or any of them are invalid.
+func (s Segment) Validate() error {
+	if len(s.Filters) == 0 {
+		return ErrNoFilters
+	}
+
+	for _, f := range s.Filters {
+		err := f.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// where returns the condition matching the audience, parameters are numbered starting after offset.
+func (s Segment) where(offset int) (string, []interface{}, error) {
+	err := s.Validate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	clauses := make([]string, 0, len(s.Filters)+1)
+	args := make([]interface{}, 0, len(s.Filters))
+
+	for i, f := range s.Filters {
+		clauses = append(clauses, f.clause(offset+i+1))
+		args = append(args, f.Value)
+	}
+
+	// accounts scheduled for deletion are never part of an audience.
+	clauses = append(clauses, "users.id NOT IN (SELECT user_id FROM account_deletions)")
+
+	return strings.Join(clauses, " AND "), args, nil
+}
@@ -0,0 +1,121 @@
+package campaigns_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications/campaigns"
+)
+
+func TestFilter_Validate(t *testing.T) {
+	var tests = []struct {
+		filter campaigns.Filter
+		valid  bool
+	}{
+		{campaigns.Filter{Type: campaigns.FilterActiveWithinDays, Value: 7}, true},
+		{campaigns.Filter{Type: campaigns.FilterActiveWithinDays, Value: 0}, false},
+		{campaigns.Filter{Type: campaigns.FilterActiveWithinDays, Value: 1000}, false},
+		{campaigns.Filter{Type: campaigns.FilterFollows, Value: 12}, true},
+		{campaigns.Filter{Type: campaigns.FilterMinRoomMinutes, Value: -1}, false},
+		{campaigns.Filter{Type: campaigns.FilterMaxRoomMinutes, Value: 30}, true},
+		{campaigns.Filter{Type: "users.id = 1 OR true", Value: 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter.String(), func(t *testing.T) {
+			err := tt.filter.Validate()
+			if (err == nil) != tt.valid {
+				t.Fatalf("expected valid %v actual err %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestBackend_AudienceSize(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := campaigns.NewBackend(db)
+
+	segment := campaigns.Segment{
+		Name: "active followers",
+		Filters: []campaigns.Filter{
+			{Type: campaigns.FilterActiveWithinDays, Value: 7},
+			{Type: campaigns.FilterFollows, Value: 12},
+		},
+	}
+
+	// values are always passed as parameters.
+	mock.ExpectPrepare(`^SELECT COUNT\(\*\) FROM users WHERE (.+)\$1(.+) AND (.+)\$2(.+)`).
+		ExpectQuery().
+		WithArgs(7, 12).
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(42))
+
+	size, err := backend.AudienceSize(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 42 {
+		t.Fatalf("expected 42 actual %d", size)
+	}
+}
+
+func TestBackend_AudienceSizeWithoutFilters(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	_, err = campaigns.NewBackend(db).AudienceSize(campaigns.Segment{Name: "everyone"})
+	if err != campaigns.ErrNoFilters {
+		t.Fatalf("expected %v actual %v", campaigns.ErrNoFilters, err)
+	}
+}
+
+func TestBackend_CancelCampaign(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := campaigns.NewBackend(db)
+
+	mock.ExpectPrepare("^UPDATE notification_campaigns (.+)").
+		ExpectExec().
+		WithArgs(campaigns.StatusCancelled, 1, campaigns.StatusScheduled, campaigns.StatusRunning).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = backend.CancelCampaign(1)
+	if err == nil {
+		t.Fatal("expected error for campaign that already completed")
+	}
+}
+
+func TestBackend_Report(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare("^SELECT (.+) FROM notification_analytics WHERE campaign = (.+)").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(mock.NewRows([]string{"audience", "sent", "opened"}).AddRow(10, 8, 2))
+
+	report, err := campaigns.NewBackend(db).Report(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.OpenRate() != 0.25 {
+		t.Fatalf("expected 0.25 actual %f", report.OpenRate())
+	}
+}
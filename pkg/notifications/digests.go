@@ -0,0 +1,292 @@
+package notifications
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// digestsKey is a sorted set of pending digests scored by when they are due.
+	digestsKey = "notifications_digests"
+
+	// digestTargetsKey stores the target of every pending digest.
+	digestTargetsKey = "notifications_digests_targets"
+)
+
+var errNotDigestible = errors.New("notification cannot be digested")
+
+// DigestActor is a user whose notification was accumulated into a digest.
+type DigestActor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Digest is a set of accumulated notifications that is sent as a single notification.
+type Digest struct {
+	Target   Target
+	Category NotificationCategory
+
+	// Actors are ordered by most recent first.
+	Actors []DigestActor
+}
+
+// Notification returns the aggregated push notification for the digest.
+func (d Digest) Notification() *PushNotification {
+	switch d.Category {
+	case NEW_FOLLOWER:
+		return NewFollowerDigestNotification(d.Actors)
+	case NEW_STORY:
+		return NewStoryDigestNotification(d.Actors)
+	default:
+		return nil
+	}
+}
+
+// Digests accumulates notifications per user and category until they are due.
+type Digests struct {
+	rdb *redis.Client
+}
+
+func NewDigests(rdb *redis.Client) *Digests {
+	return &Digests{rdb: rdb}
+}
+
+// Add accumulates a notification for the target, the digest is due at the given time unless one is already pending.
+func (d *Digests) Add(target Target, notification *PushNotification, due time.Time) error {
+	actor, err := digestActorFor(notification)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(actor)
+	if err != nil {
+		return err
+	}
+
+	t, err := json.Marshal(digestTarget{ID: target.ID, Target: target})
+	if err != nil {
+		return err
+	}
+
+	member := digestMember(target.ID, notification.Category)
+	ctx := d.rdb.Context()
+
+	pipe := d.rdb.TxPipeline()
+	pipe.ZAdd(ctx, digestActorsKey(member), &redis.Z{Score: float64(time.Now().UnixNano()), Member: string(data)})
+	pipe.HSet(ctx, digestTargetsKey, member, string(t))
+	pipe.ZAddNX(ctx, digestsKey, &redis.Z{Score: float64(due.Unix()), Member: member})
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+// Due removes and returns all digests that are due at the given time.
+func (d *Digests) Due(now time.Time) ([]Digest, error) {
+	ctx := d.rdb.Context()
+
+	members, err := d.rdb.ZRangeByScore(ctx, digestsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: 100,
+	}).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	digests := make([]Digest, 0)
+	for _, member := range members {
+		digest, err := d.take(member)
+		if err == redis.TxFailedErr {
+			// the digest changed while it was read, it is taken on the next run.
+			continue
+		}
+
+		if err != nil {
+			return digests, err
+		}
+
+		if digest != nil {
+			digests = append(digests, *digest)
+		}
+	}
+
+	return digests, nil
+}
+
+// Remove drops the pending digests of a user, these are the categories digestActorFor accepts.
+func (d *Digests) Remove(user int) error {
+	ctx := d.rdb.Context()
+
+	pipe := d.rdb.TxPipeline()
+	for _, category := range []NotificationCategory{NEW_FOLLOWER, NEW_STORY} {
+		member := digestMember(user, category)
+
+		pipe.ZRem(ctx, digestsKey, member)
+		pipe.Del(ctx, digestActorsKey(member))
+		pipe.HDel(ctx, digestTargetsKey, member)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// take reads a digest and removes it once it was read, the digest stays pending when reading fails.
+// Only the process that removes the digest returns it.
+func (d *Digests) take(member string) (*Digest, error) {
+	ctx := d.rdb.Context()
+
+	var digest *Digest
+	var parseErr error
+
+	err := d.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		actors, err := tx.ZRevRange(ctx, digestActorsKey(member), 0, -1).Result()
+		if err != nil {
+			return err
+		}
+
+		target, err := tx.HGet(ctx, digestTargetsKey, member).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		// a digest that cannot be parsed will never be sent, so it is removed anyway.
+		digest, parseErr = parseDigest(member, actors, target)
+
+		cmds, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZRem(ctx, digestsKey, member)
+			pipe.Del(ctx, digestActorsKey(member))
+			pipe.HDel(ctx, digestTargetsKey, member)
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if cmds[0].(*redis.IntCmd).Val() == 0 {
+			digest = nil
+		}
+
+		return nil
+	}, digestActorsKey(member))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return digest, parseErr
+}
+
+func parseDigest(member string, actors []string, target string) (*Digest, error) {
+	parts := strings.SplitN(member, ":", 2)
+	if len(parts) != 2 || target == "" {
+		return nil, nil
+	}
+
+	t := &digestTarget{}
+	err := json.Unmarshal([]byte(target), t)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &Digest{Category: NotificationCategory(parts[1]), Actors: make([]DigestActor, 0)}
+	digest.Target = t.Target
+	digest.Target.ID = t.ID
+
+	for _, data := range actors {
+		actor := DigestActor{}
+		err := json.Unmarshal([]byte(data), &actor)
+		if err != nil {
+			continue
+		}
+
+		digest.Actors = append(digest.Actors, actor)
+	}
+
+	if len(digest.Actors) == 0 {
+		return nil, nil
+	}
+
+	return digest, nil
+}
+
+// NewFollowerDigestNotification aggregates multiple followers into a single notification.
+func NewFollowerDigestNotification(actors []DigestActor) *PushNotification {
+	ids := make([]int, 0, len(actors))
+	for _, actor := range actors {
+		ids = append(ids, actor.ID)
+	}
+
+	alert := Alert{Key: "new_followers_digest_notification", Arguments: []string{actors[0].Name, strconv.Itoa(len(actors) - 1)}}
+	switch len(actors) {
+	case 1:
+		alert = Alert{Key: "new_follower_notification", Arguments: []string{actors[0].Name}}
+	case 2:
+		alert = Alert{Key: "new_followers_digest_two_notification", Arguments: []string{actors[0].Name, actors[1].Name}}
+	}
+
+	return &PushNotification{
+		Category:   NEW_FOLLOWER_DIGEST,
+		Alert:      alert,
+		Arguments:  map[string]interface{}{"id": ids[0], "actors": ids},
+		CollapseID: "new_follower_digest",
+	}
+}
+
+// NewStoryDigestNotification aggregates stories posted by multiple users into a single notification.
+func NewStoryDigestNotification(actors []DigestActor) *PushNotification {
+	ids := make([]int, 0, len(actors))
+	for _, actor := range actors {
+		ids = append(ids, actor.ID)
+	}
+
+	alert := Alert{Key: "new_stories_digest_notification", Arguments: []string{actors[0].Name, strconv.Itoa(len(actors) - 1)}}
+	switch len(actors) {
+	case 1:
+		alert = Alert{Key: "new_story_notification", Arguments: []string{actors[0].Name}}
+	case 2:
+		alert = Alert{Key: "new_stories_digest_two_notification", Arguments: []string{actors[0].Name, actors[1].Name}}
+	}
+
+	return &PushNotification{
+		Category:   NEW_STORY_DIGEST,
+		Alert:      alert,
+		Arguments:  map[string]interface{}{"id": ids[0], "actors": ids},
+		CollapseID: "new_story_digest",
+	}
+}
+
+// digestTarget is used to serialize a target, whose ID is hidden from JSON.
+type digestTarget struct {
+	ID int `json:"id"`
+	Target
+}
+
+func digestActorFor(notification *PushNotification) (*DigestActor, error) {
+	switch notification.Category {
+	case NEW_FOLLOWER, NEW_STORY:
+		id, ok := notification.Arguments["id"].(int)
+		if !ok || len(notification.Alert.Arguments) == 0 {
+			return nil, errNotDigestible
+		}
+
+		return &DigestActor{ID: id, Name: notification.Alert.Arguments[0]}, nil
+	default:
+		return nil, errNotDigestible
+	}
+}
+
+func digestMember(user int, category NotificationCategory) string {
+	return fmt.Sprintf("%d:%s", user, category)
+}
+
+func digestActorsKey(member string) string {
+	return fmt.Sprintf("notifications_digest_%s", member)
+}
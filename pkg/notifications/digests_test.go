@@ -0,0 +1,202 @@
+package notifications_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestDigests(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	digests := notifications.NewDigests(rdb)
+
+	target := notifications.Target{ID: 1, Follows: true, Timezone: "UTC"}
+	due := time.Now().Add(10 * time.Minute)
+
+	followers := []notifications.DigestActor{{ID: 2, Name: "anna"}, {ID: 3, Name: "bob"}, {ID: 4, Name: "carl"}}
+	for _, follower := range followers {
+		err := digests.Add(target, &notifications.PushNotification{
+			Category:  notifications.NEW_FOLLOWER,
+			Alert:     notifications.Alert{Key: "new_follower_notification", Arguments: []string{follower.Name}},
+			Arguments: map[string]interface{}{"id": follower.ID},
+		}, due)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// ensures actors are ordered by time.
+		time.Sleep(time.Millisecond)
+	}
+
+	res, err := digests.Due(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 0 {
+		t.Fatalf("expected no due digests actual %d", len(res))
+	}
+
+	res, err = digests.Due(due)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []notifications.Digest{
+		{
+			Target:   target,
+			Category: notifications.NEW_FOLLOWER,
+			Actors:   []notifications.DigestActor{{ID: 4, Name: "carl"}, {ID: 3, Name: "bob"}, {ID: 2, Name: "anna"}},
+		},
+	}
+
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %v actual %v", expected, res)
+	}
+
+	res, err = digests.Due(due)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 0 {
+		t.Fatalf("expected digest to be flushed once, actual %d", len(res))
+	}
+}
+
+func TestDigests_DueKeepsDigestWhenReadFails(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	digests := notifications.NewDigests(rdb)
+
+	target := notifications.Target{ID: 1, Follows: true, Timezone: "UTC"}
+	due := time.Now().Add(10 * time.Minute)
+
+	err = digests.Add(target, &notifications.PushNotification{
+		Category:  notifications.NEW_FOLLOWER,
+		Alert:     notifications.Alert{Key: "new_follower_notification", Arguments: []string{"anna"}},
+		Arguments: map[string]interface{}{"id": 2},
+	}, due)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// replaces the actors with a value of the wrong type, so reading the digest fails.
+	actors := "notifications_digest_1:NEW_FOLLOWER"
+	members, err := mr.ZMembers(actors)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr.Del(actors)
+	_ = mr.Set(actors, "invalid")
+
+	_, err = digests.Due(due)
+	if err == nil {
+		t.Fatal("expected reading the digest to fail")
+	}
+
+	mr.Del(actors)
+	_, _ = mr.ZAdd(actors, 1, members[0])
+
+	res, err := digests.Due(due)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].Actors[0].Name != "anna" {
+		t.Fatalf("expected digest to be kept after the failure, actual %v", res)
+	}
+}
+
+func TestNewFollowerDigestNotification(t *testing.T) {
+	var tests = []struct {
+		actors []notifications.DigestActor
+		alert  notifications.Alert
+	}{
+		{
+			actors: []notifications.DigestActor{{ID: 1, Name: "anna"}},
+			alert:  notifications.Alert{Key: "new_follower_notification", Arguments: []string{"anna"}},
+		},
+		{
+			actors: []notifications.DigestActor{{ID: 1, Name: "anna"}, {ID: 2, Name: "bob"}},
+			alert:  notifications.Alert{Key: "new_followers_digest_two_notification", Arguments: []string{"anna", "bob"}},
+		},
+		{
+			actors: []notifications.DigestActor{{ID: 1, Name: "anna"}, {ID: 2, Name: "bob"}, {ID: 3, Name: "carl"}},
+			alert:  notifications.Alert{Key: "new_followers_digest_notification", Arguments: []string{"anna", "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alert.Key, func(t *testing.T) {
+			n := notifications.NewFollowerDigestNotification(tt.actors)
+
+			if !reflect.DeepEqual(n.Alert, tt.alert) {
+				t.Fatalf("expected %v actual %v", tt.alert, n.Alert)
+			}
+
+			if n.Arguments["id"] != tt.actors[0].ID {
+				t.Fatalf("expected id %d actual %v", tt.actors[0].ID, n.Arguments["id"])
+			}
+		})
+	}
+}
+
+func TestNewStoryDigestNotification(t *testing.T) {
+	var tests = []struct {
+		actors []notifications.DigestActor
+		alert  notifications.Alert
+	}{
+		{
+			actors: []notifications.DigestActor{{ID: 1, Name: "anna"}},
+			alert:  notifications.Alert{Key: "new_story_notification", Arguments: []string{"anna"}},
+		},
+		{
+			actors: []notifications.DigestActor{{ID: 1, Name: "anna"}, {ID: 2, Name: "bob"}},
+			alert:  notifications.Alert{Key: "new_stories_digest_two_notification", Arguments: []string{"anna", "bob"}},
+		},
+		{
+			actors: []notifications.DigestActor{{ID: 1, Name: "anna"}, {ID: 2, Name: "bob"}, {ID: 3, Name: "carl"}},
+			alert:  notifications.Alert{Key: "new_stories_digest_notification", Arguments: []string{"anna", "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alert.Key, func(t *testing.T) {
+			n := notifications.NewStoryDigestNotification(tt.actors)
+
+			if n.Category != notifications.NEW_STORY_DIGEST {
+				t.Fatalf("unexpected category %s", n.Category)
+			}
+
+			if !reflect.DeepEqual(n.Alert, tt.alert) {
+				t.Fatalf("expected %v actual %v", tt.alert, n.Alert)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+package notifications
+
+// Email sends notifications to the email address of a target.
+type Email interface {
+	Send(target Target, notification PushNotification) error
+}
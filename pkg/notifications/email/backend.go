@@ -0,0 +1,87 @@
+// Package email delivers notifications by email and handles unsubscribing from them.
+package email
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrNoEmail is returned for users that log in with a phone number and have not added an email.
+var ErrNoEmail = errors.New("user has no email")
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+// TokenFor returns the unsubscribe token of a user, a token is created the first time it is requested.
+func (b *Backend) TokenFor(user int) (string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt, err := b.db.Prepare("INSERT INTO notification_email_tokens (user_id, token) VALUES ($1, $2) ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id RETURNING token;")
+	if err != nil {
+		return "", err
+	}
+
+	var result string
+	err = stmt.QueryRow(user, token).Scan(&result)
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// UserFor returns the user an unsubscribe token belongs to.
+func (b *Backend) UserFor(token string) (int, error) {
+	stmt, err := b.db.Prepare("SELECT user_id FROM notification_email_tokens WHERE token = $1;")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = stmt.QueryRow(token).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// EmailFor returns the email address of a user.
+func (b *Backend) EmailFor(user int) (string, error) {
+	stmt, err := b.db.Prepare("SELECT email FROM users WHERE id = $1;")
+	if err != nil {
+		return "", err
+	}
+
+	var email sql.NullString
+	err = stmt.QueryRow(user).Scan(&email)
+	if err != nil {
+		return "", err
+	}
+
+	if !email.Valid {
+		return "", ErrNoEmail
+	}
+
+	return email.String, nil
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
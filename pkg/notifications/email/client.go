@@ -0,0 +1,130 @@
+package email
+
+import (
+	"bytes"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
+)
+
+// Client sends notifications as emails rendered in the locale of the target.
+type Client struct {
+	sender    mail.Sender
+	backend   *Backend
+	templates *templates.Registry
+	from      netmail.Address
+
+	// unsubscribe is the URL of the unsubscribe endpoint.
+	unsubscribe string
+}
+
+func NewClient(sender mail.Sender, backend *Backend, templates *templates.Registry, from netmail.Address, unsubscribe string) *Client {
+	return &Client{
+		sender:      sender,
+		backend:     backend,
+		templates:   templates,
+		from:        from,
+		unsubscribe: unsubscribe,
+	}
+}
+
+func (c *Client) Send(target notifications.Target, notification notifications.PushNotification) error {
+	address := notification.Recipient
+	if address == "" {
+		var err error
+		address, err = c.backend.EmailFor(target.ID)
+		if err == ErrNoEmail {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	text, err := c.templates.RenderAlert(target.Locale, notification.Alert)
+	if err != nil {
+		return err
+	}
+
+	message := mail.Message{
+		From:    c.from,
+		To:      address,
+		Subject: c.subject(target.Locale, notification.Category),
+		Headers: make(map[string]string),
+	}
+
+	data := content{Text: text}
+
+	// security notifications are transactional, users cannot unsubscribe from them.
+	category := notifications.PreferenceCategory(notification.Category)
+	if notifications.IsConfigurableCategory(category) {
+		link, err := c.unsubscribeLink(target.ID, category)
+		if err != nil {
+			return err
+		}
+
+		data.Unsubscribe = link
+		data.UnsubscribeText, _ = c.templates.Render(target.Locale, "email_unsubscribe", []string{})
+
+		// see RFC 8058 for one-click unsubscribing.
+		message.Headers["List-Unsubscribe"] = "<" + link + ">"
+		message.Headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+
+	message.Text, message.HTML, err = render(data)
+	if err != nil {
+		return err
+	}
+
+	err = c.sender.Send(message)
+	if err == mail.ErrTemporaryFailure {
+		return notifications.ErrRetryRequired
+	}
+
+	return err
+}
+
+// subject returns the subject for a category, falling back to a generic one.
+func (c *Client) subject(locale string, category notifications.NotificationCategory) string {
+	subject, err := c.templates.Render(locale, "email_subject_"+strings.ToLower(string(category)), []string{})
+	if err == nil {
+		return subject
+	}
+
+	subject, _ = c.templates.Render(locale, "email_subject", []string{})
+	return subject
+}
+
+func (c *Client) unsubscribeLink(user int, category notifications.NotificationCategory) (string, error) {
+	token, err := c.backend.TokenFor(user)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("token", token)
+	query.Set("category", string(category))
+
+	return c.unsubscribe + "?" + query.Encode(), nil
+}
+
+func render(data content) (string, string, error) {
+	text := &bytes.Buffer{}
+	err := textLayout.Execute(text, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	html := &bytes.Buffer{}
+	err = htmlLayout.Execute(html, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	return text.String(), html.String(), nil
+}
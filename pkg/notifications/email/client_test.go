@@ -0,0 +1,138 @@
+package email_test
+
+import (
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/mail"
+	"github.com/soapboxsocial/soapbox/pkg/mail/mailtest"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/email"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
+)
+
+const unsubscribeURL = "https://soapbox.social/v1/notifications/email/unsubscribe"
+
+func TestClient_Send(t *testing.T) {
+	server, client, mock := setup(t)
+	defer server.Close()
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(mock.NewRows([]string{"email"}).AddRow("foo@example.com"))
+
+	mock.ExpectPrepare("^INSERT (.+)").ExpectQuery().
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"token"}).AddRow("abc"))
+
+	notification := notifications.NewFollowerDigestNotification([]notifications.DigestActor{
+		{ID: 2, Name: "foo"},
+		{ID: 3, Name: "bar"},
+	})
+
+	err := client.Send(notifications.Target{ID: 1, Locale: "en"}, *notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message actual %d", len(messages))
+	}
+
+	message := messages[0]
+
+	if message.Header.Get("To") != "foo@example.com" {
+		t.Fatalf("unexpected recipient %s", message.Header.Get("To"))
+	}
+
+	if message.Header.Get("Subject") != "You have new followers on Soapbox" {
+		t.Fatalf("unexpected subject %s", message.Header.Get("Subject"))
+	}
+
+	query := url.Values{"token": {"abc"}, "category": {string(notifications.NEW_FOLLOWER)}}
+	expected := "<" + unsubscribeURL + "?" + query.Encode() + ">"
+	if message.Header.Get("List-Unsubscribe") != expected {
+		t.Fatalf("expected %s actual %s", expected, message.Header.Get("List-Unsubscribe"))
+	}
+
+	if message.Header.Get("List-Unsubscribe-Post") != "List-Unsubscribe=One-Click" {
+		t.Fatalf("unexpected header %s", message.Header.Get("List-Unsubscribe-Post"))
+	}
+}
+
+func TestClient_SendWithoutUnsubscribe(t *testing.T) {
+	server, client, mock := setup(t)
+	defer server.Close()
+
+	// security notifications are sent to the recipient instead of the address of the target.
+	notification := notifications.PushNotification{
+		Category:  notifications.ACCOUNT_SECURITY,
+		Alert:     notifications.Alert{Body: "someone logged in"},
+		Recipient: "old@example.com",
+	}
+
+	err := client.Send(notifications.Target{ID: 1, Locale: "de"}, notification)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message actual %d", len(messages))
+	}
+
+	if !strings.Contains(messages[0].Header.Get("To"), "old@example.com") {
+		t.Fatalf("unexpected recipient %s", messages[0].Header.Get("To"))
+	}
+
+	if messages[0].Header.Get("List-Unsubscribe") != "" {
+		t.Fatal("expected no unsubscribe header for security notifications")
+	}
+
+	if !strings.Contains(messages[0].Header.Get("Subject"), "=?utf-8?q?") {
+		t.Fatalf("expected encoded subject actual %s", messages[0].Header.Get("Subject"))
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setup(t *testing.T) (*mailtest.Server, *email.Client, sqlmock.Sqlmock) {
+	t.Helper()
+
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := email.NewClient(
+		mail.NewSMTPSender(server.Addr, "", ""),
+		email.NewBackend(db),
+		registry,
+		netmail.Address{Name: "Soapbox", Address: "notifications@soapbox.social"},
+		unsubscribeURL,
+	)
+
+	return server, client, mock
+}
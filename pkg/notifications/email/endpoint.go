@@ -0,0 +1,110 @@
+package email
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// Endpoint lets users unsubscribe from notification emails without logging in.
+type Endpoint struct {
+	backend  *Backend
+	settings *notifications.Settings
+}
+
+func NewEndpoint(backend *Backend, settings *notifications.Settings) *Endpoint {
+	return &Endpoint{
+		backend:  backend,
+		settings: settings,
+	}
+}
+
+func (e *Endpoint) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/unsubscribe", e.confirm).Methods("GET")
+	r.HandleFunc("/unsubscribe", e.unsubscribe).Methods("POST")
+
+	return r
+}
+
+// confirm asks users to confirm, so link scanners opening the URL do not unsubscribe them.
+func (e *Endpoint) confirm(w http.ResponseWriter, r *http.Request) {
+	// the router is mounted under a prefix which is stripped from the URL, the form posts back to the requested URI instead.
+	action := r.RequestURI
+	if action == "" {
+		action = r.URL.RequestURI()
+	}
+
+	e.renderPage(w, map[string]interface{}{"Action": action, "Done": false})
+}
+
+// unsubscribe disables emails for a category, or all categories if none is set.
+// The parameters are part of the URL, one-click unsubscribe requests only have `List-Unsubscribe=One-Click` as their body.
+func (e *Endpoint) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	user, err := e.backend.UserFor(query.Get("token"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "invalid token")
+			return
+		}
+
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	categories := notifications.Categories
+	if category := notifications.NotificationCategory(query.Get("category")); category != "" {
+		if !notifications.IsConfigurableCategory(category) {
+			httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid category")
+			return
+		}
+
+		categories = []notifications.NotificationCategory{category}
+	}
+
+	// accounts scheduled for deletion have no settings until they are restored.
+	target, err := e.settings.GetSettingsFor(user)
+	if err == sql.ErrNoRows {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "invalid token")
+		return
+	}
+
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	for _, category := range categories {
+		preferences := target.PreferencesFor(category)
+		if !preferences.Email {
+			continue
+		}
+
+		preferences.Email = false
+
+		err = e.settings.UpdatePreferencesFor(user, category, preferences)
+		if err != nil {
+			httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "")
+			return
+		}
+	}
+
+	e.renderPage(w, map[string]interface{}{"Done": true})
+}
+
+func (e *Endpoint) renderPage(w http.ResponseWriter, data map[string]interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	err := unsubscribePage.Execute(w, data)
+	if err != nil {
+		log.Printf("unsubscribePage.Execute err: %v\n", err)
+	}
+}
@@ -0,0 +1,135 @@
+package email_test
+
+import (
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/email"
+)
+
+func TestEndpoint_Unsubscribe(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	e := email.NewEndpoint(email.NewBackend(db), notifications.NewSettings(db))
+
+	req, err := http.NewRequest("POST", "/unsubscribe?token=abc&category=NEW_FOLLOWER", strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs("abc").
+		WillReturnRows(mock.NewRows([]string{"user_id"}).AddRow(1))
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				AddRow(1, 2, true, true, "UTC", false, 1320, 420, "en", `{"NEW_FOLLOWER": {"push": true, "inbox": true, "email": true}}`, nil),
+		)
+
+	mock.ExpectPrepare("^INSERT INTO notification_preferences (.+)").ExpectExec().
+		WithArgs(1, notifications.NEW_FOLLOWER, true, true, false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	rr := httptest.NewRecorder()
+	e.Router().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEndpoint_UnsubscribeWithInvalidToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	e := email.NewEndpoint(email.NewBackend(db), notifications.NewSettings(db))
+
+	req, err := http.NewRequest("POST", "/unsubscribe?token=abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs("abc").
+		WillReturnRows(mock.NewRows([]string{"user_id"}))
+
+	rr := httptest.NewRecorder()
+	e.Router().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestEndpoint_ConfirmPostsToMountedPath(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	e := email.NewEndpoint(email.NewBackend(db), notifications.NewSettings(db))
+	handler := http.StripPrefix("/v1/notifications/email", e.Router())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/notifications/email/unsubscribe?token=abc&category=NEW_FOLLOWER", nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	match := regexp.MustCompile(`action="([^"]+)"`).FindStringSubmatch(rr.Body.String())
+	if match == nil {
+		t.Fatal("expected a form action")
+	}
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs("abc").
+		WillReturnRows(mock.NewRows([]string{"user_id"}).AddRow(1))
+
+	mock.ExpectPrepare("^SELECT (.+)").ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				AddRow(1, 2, true, true, "UTC", false, 1320, 420, "en", `{"NEW_FOLLOWER": {"push": true, "inbox": true, "email": true}}`, nil),
+		)
+
+	mock.ExpectPrepare("^INSERT INTO notification_preferences (.+)").ExpectExec().
+		WithArgs(1, notifications.NEW_FOLLOWER, true, true, false).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", html.UnescapeString(match[1]), nil))
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
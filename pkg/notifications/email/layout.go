@@ -0,0 +1,35 @@
+package email
+
+import (
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+// content is rendered into the email layouts.
+type content struct {
+	Text            string
+	Unsubscribe     string
+	UnsubscribeText string
+}
+
+var textLayout = texttemplate.Must(texttemplate.New("text").Parse(`{{.Text}}
+{{if .Unsubscribe}}
+--
+{{.UnsubscribeText}}: {{.Unsubscribe}}
+{{end}}`))
+
+var htmlLayout = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; color: #000;">
+<p>{{.Text}}</p>
+{{if .Unsubscribe}}<p style="color: #8e8e93; font-size: 12px;"><a href="{{.Unsubscribe}}" style="color: #8e8e93;">{{.UnsubscribeText}}</a></p>{{end}}
+</body>
+</html>`))
+
+var unsubscribePage = htmltemplate.Must(htmltemplate.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta name="viewport" content="width=device-width, initial-scale=1"></head>
+<body style="font-family: -apple-system, Helvetica, Arial, sans-serif; text-align: center;">
+{{if .Done}}<p>You have been unsubscribed.</p>{{else}}<form method="POST" action="{{.Action}}"><button type="submit">Unsubscribe</button></form>{{end}}
+</body>
+</html>`))
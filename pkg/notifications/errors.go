@@ -6,6 +6,9 @@ var (
 	// ErrDeviceUnregistered is returned when an apns token is unregistered.
 	ErrDeviceUnregistered = errors.New("apns device unregistered")
 
+	// ErrSubscriptionExpired is returned when a web push subscription is no longer valid.
+	ErrSubscriptionExpired = errors.New("web push subscription expired")
+
 	// ErrRetryRequired is returned when a notification was not send due to a server and a retry is required.
 	ErrRetryRequired = errors.New("failed to send retry required")
 )
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+)
+
+type AccountSecurityNotificationHandler struct {
+	targets *notifications.Settings
+}
+
+func NewAccountSecurityNotificationHandler(targets *notifications.Settings) *AccountSecurityNotificationHandler {
+	return &AccountSecurityNotificationHandler{
+		targets: targets,
+	}
+}
+
+func (a AccountSecurityNotificationHandler) Type() pubsub.EventType {
+	return pubsub.EventTypeEmailChanged
+}
+
+func (a AccountSecurityNotificationHandler) Origin(*pubsub.Event) (int, error) {
+	return 0, ErrNoCreator
+}
+
+func (a AccountSecurityNotificationHandler) Targets(event *pubsub.Event) ([]notifications.Target, error) {
+	id, err := event.GetInt("id")
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := a.targets.GetSettingsFor(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return []notifications.Target{*target}, nil
+}
+
+func (a AccountSecurityNotificationHandler) Build(event *pubsub.Event) (*notifications.PushNotification, error) {
+	previous, ok := event.Params["previous"].(string)
+	if !ok {
+		return nil, fmt.Errorf("failed to recover previous")
+	}
+
+	email, ok := event.Params["email"].(string)
+	if !ok {
+		return nil, fmt.Errorf("failed to recover email")
+	}
+
+	body := fmt.Sprintf("The email of your account was changed to %s. If you did not do this, contact us immediately.", email)
+	if previous == email {
+		body = "You can now log in to your account with your email. If you did not do this, contact us immediately."
+	}
+
+	// the alert goes to the previous email, so the owner notices if the account was taken over.
+	return &notifications.PushNotification{
+		Category:  notifications.ACCOUNT_SECURITY,
+		Alert:     notifications.Alert{Body: body},
+		Arguments: map[string]interface{}{},
+		Recipient: previous,
+	}, nil
+}
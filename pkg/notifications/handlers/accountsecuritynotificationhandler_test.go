@@ -0,0 +1,94 @@
+package handlers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/handlers"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+)
+
+func TestAccountSecurityNotificationHandler_Targets(t *testing.T) {
+	raw := pubsub.NewEmailChangedEvent(12, "old@example.com", "new@example.com")
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := handlers.NewAccountSecurityNotificationHandler(notifications.NewSettings(db))
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WithArgs(12).
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("12,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
+
+	target, err := handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []notifications.Target{
+		{ID: 12, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
+	}
+
+	if !reflect.DeepEqual(target, expected) {
+		t.Fatalf("expected %v actual %v", expected, target)
+	}
+}
+
+func TestAccountSecurityNotificationHandler_Build(t *testing.T) {
+	var tests = []struct {
+		previous string
+		email    string
+		body     string
+	}{
+		{
+			previous: "old@example.com",
+			email:    "new@example.com",
+			body:     "The email of your account was changed to new@example.com. If you did not do this, contact us immediately.",
+		},
+		{
+			previous: "old@example.com",
+			email:    "old@example.com",
+			body:     "You can now log in to your account with your email. If you did not do this, contact us immediately.",
+		},
+	}
+
+	handler := handlers.NewAccountSecurityNotificationHandler(nil)
+
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			raw := pubsub.NewEmailChangedEvent(12, tt.previous, tt.email)
+			event, err := getRawEvent(&raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			n, err := handler.Build(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			notification := &notifications.PushNotification{
+				Category:  notifications.ACCOUNT_SECURITY,
+				Alert:     notifications.Alert{Body: tt.body},
+				Arguments: map[string]interface{}{},
+				Recipient: tt.previous,
+			}
+
+			if !reflect.DeepEqual(n, notification) {
+				t.Fatalf("expected %v actual %v", notification, n)
+			}
+		})
+	}
+}
@@ -8,5 +8,6 @@ var (
 	errFailedToSort          = errors.New("failed to sort")
 	errEmptyResponse         = errors.New("empty response")
 	errMemberNoLongerPresent = errors.New("member no longer present")
+	errNoStory               = errors.New("no story")
 	ErrNoCreator             = errors.New("no creator")
 )
@@ -51,7 +51,7 @@ func (f FollowerNotificationHandler) Build(event *pubsub.Event) (*notifications.
 		return nil, err
 	}
 
-	displayName, err := f.getDisplayName(creator)
+	user, err := f.users.FindByID(creator)
 	if err != nil {
 		return nil, err
 	}
@@ -60,17 +60,9 @@ func (f FollowerNotificationHandler) Build(event *pubsub.Event) (*notifications.
 		Category: notifications.NEW_FOLLOWER,
 		Alert: notifications.Alert{
 			Key:       "new_follower_notification",
-			Arguments: []string{displayName},
+			Arguments: []string{user.DisplayName},
 		},
 		Arguments: map[string]interface{}{"id": creator},
+		Image:     user.Image,
 	}, nil
 }
-
-func (f FollowerNotificationHandler) getDisplayName(id int) (string, error) {
-	user, err := f.users.FindByID(id)
-	if err != nil {
-		return "", err
-	}
-
-	return user.DisplayName, nil
-}
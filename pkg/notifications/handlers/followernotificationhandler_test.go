@@ -33,7 +33,7 @@ func TestFollowerNotificationHandler_Targets(t *testing.T) {
 	mock.
 		ExpectPrepare("SELECT").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms"}).FromCSVString("1,2,false,false"))
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("1,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
 
 	target, err := handler.Targets(event)
 	if err != nil {
@@ -41,7 +41,7 @@ func TestFollowerNotificationHandler_Targets(t *testing.T) {
 	}
 
 	expected := []notifications.Target{
-		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false},
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
 	}
 
 	if !reflect.DeepEqual(target, expected) {
@@ -85,6 +85,7 @@ func TestFollowerNotificationHandler_Build(t *testing.T) {
 			Arguments: []string{displayName},
 		},
 		Arguments: map[string]interface{}{"id": user},
+		Image:     "t",
 	}
 
 	if !reflect.DeepEqual(n, notification) {
@@ -2,8 +2,6 @@ package handlers
 
 import (
 	"errors"
-	"fmt"
-	"strconv"
 
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
@@ -60,48 +58,10 @@ func (f FollowRecommendationsNotificationHandler) Build(event *pubsub.Event) (*n
 		return nil, errors.New("no recommendations")
 	}
 
-	translation := ""
-	body := ""
-	args := make([]string, 0)
-
-	switch count {
-	case 1:
-		translation += "1"
-		body = fmt.Sprintf("%s who you may know is on Soapbox, why not follow them?", recommendations[0].DisplayName)
-		args = append(args, recommendations[0].DisplayName)
-	case 2:
-		translation += "2"
-		body = fmt.Sprintf(
-			"%s and %s who you may know are on Soapbox, why not follow them?",
-			recommendations[0].DisplayName, recommendations[1].DisplayName,
-		)
-		args = append(args, recommendations[0].DisplayName, recommendations[1].DisplayName)
-	case 3:
-		translation += "3"
-		body = fmt.Sprintf(
-			"%s, %s and %s who you may know are on Soapbox, why not follow them?",
-			recommendations[0].DisplayName, recommendations[1].DisplayName, recommendations[2].DisplayName,
-		)
-
-		args = append(args, recommendations[0].DisplayName, recommendations[1].DisplayName, recommendations[2].DisplayName)
-	default:
-		translation += "3_and_more"
-		body = fmt.Sprintf(
-			"%s, %s, %s and %d others who you may know are on Soapbox, why not follow them?",
-			recommendations[0].DisplayName, recommendations[1].DisplayName, recommendations[2].DisplayName, count-3,
-		)
-
-		args = append(args, recommendations[0].DisplayName, recommendations[1].DisplayName, recommendations[2].DisplayName, strconv.Itoa(count-3))
+	names := make([]string, 0, 3)
+	for i := 0; i < count && i < 3; i++ {
+		names = append(names, recommendations[i].DisplayName)
 	}
 
-	translation += "_follow_recommendations_notification"
-
-	return &notifications.PushNotification{
-		Category: notifications.FOLLOW_RECOMMENDATIONS,
-		Alert: notifications.Alert{
-			Body:      body,
-			Key:       translation,
-			Arguments: args,
-		},
-	}, nil
+	return notifications.NewFollowRecommendationsNotification(names, count), nil
 }
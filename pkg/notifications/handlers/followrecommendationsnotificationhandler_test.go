@@ -35,7 +35,7 @@ func TestFollowRecommendationsNotificationHandler_Targets(t *testing.T) {
 	mock.
 		ExpectPrepare("SELECT").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms"}).FromCSVString("1,2,false,false"))
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("1,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
 
 	target, err := handler.Targets(event)
 	if err != nil {
@@ -43,7 +43,7 @@ func TestFollowRecommendationsNotificationHandler_Targets(t *testing.T) {
 	}
 
 	expected := []notifications.Target{
-		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false},
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
 	}
 
 	if !reflect.DeepEqual(target, expected) {
@@ -65,7 +65,6 @@ func TestFollowRecommendationsNotificationHandler_Build(t *testing.T) {
 			notification: &notifications.PushNotification{
 				Category: notifications.FOLLOW_RECOMMENDATIONS,
 				Alert: notifications.Alert{
-					Body:      "bob who you may know is on Soapbox, why not follow them?",
 					Key:       "1_follow_recommendations_notification",
 					Arguments: []string{"bob"},
 				},
@@ -80,7 +79,6 @@ func TestFollowRecommendationsNotificationHandler_Build(t *testing.T) {
 			notification: &notifications.PushNotification{
 				Category: notifications.FOLLOW_RECOMMENDATIONS,
 				Alert: notifications.Alert{
-					Body:      "bob and pew who you may know are on Soapbox, why not follow them?",
 					Key:       "2_follow_recommendations_notification",
 					Arguments: []string{"bob", "pew"},
 				},
@@ -96,7 +94,6 @@ func TestFollowRecommendationsNotificationHandler_Build(t *testing.T) {
 			notification: &notifications.PushNotification{
 				Category: notifications.FOLLOW_RECOMMENDATIONS,
 				Alert: notifications.Alert{
-					Body:      "bob, pew and den who you may know are on Soapbox, why not follow them?",
 					Key:       "3_follow_recommendations_notification",
 					Arguments: []string{"bob", "pew", "den"},
 				},
@@ -113,7 +110,6 @@ func TestFollowRecommendationsNotificationHandler_Build(t *testing.T) {
 			notification: &notifications.PushNotification{
 				Category: notifications.FOLLOW_RECOMMENDATIONS,
 				Alert: notifications.Alert{
-					Body:      "bob, pew, den and 1 others who you may know are on Soapbox, why not follow them?",
 					Key:       "3_and_more_follow_recommendations_notification",
 					Arguments: []string{"bob", "pew", "den", "1"},
 				},
@@ -49,7 +49,7 @@ func (r RoomCreationNotificationHandler) Targets(event *pubsub.Event) ([]notific
 		return nil, err
 	}
 
-	targets, err := r.targets.GetSettingsFollowingUser(creator)
+	targets, err := r.targets.GetSettingsForRoomAlerts(creator)
 	if err != nil {
 		return nil, err
 	}
@@ -85,23 +85,17 @@ func (r RoomCreationNotificationHandler) Build(event *pubsub.Event) (*notificati
 		return nil, errors.New("room is private")
 	}
 
-	displayName, err := r.getDisplayName(creator)
+	user, err := r.users.FindByID(creator)
 	if err != nil {
 		return nil, err
 	}
 
+	notification := notifications.NewRoomNotification(room, user.DisplayName, creator)
 	if response.State.Name != "" {
-		return notifications.NewRoomNotificationWithName(room, displayName, response.State.Name), nil
+		notification = notifications.NewRoomNotificationWithName(room, user.DisplayName, response.State.Name)
 	}
 
-	return notifications.NewRoomNotification(room, displayName, creator), nil
-}
-
-func (r RoomCreationNotificationHandler) getDisplayName(id int) (string, error) {
-	user, err := r.users.FindByID(id)
-	if err != nil {
-		return "", err
-	}
+	notification.Image = user.Image
 
-	return user.DisplayName, nil
+	return notification, nil
 }
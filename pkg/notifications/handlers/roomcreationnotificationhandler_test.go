@@ -42,7 +42,7 @@ func TestRoomCreationNotificationHandler_Targets(t *testing.T) {
 	mock.
 		ExpectPrepare("SELECT").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms"}).FromCSVString("1,2,false,false"))
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles", "subscribed"}).FromCSVString("1,2,false,false,UTC,false,1320,420,en,NULL,NULL,false"))
 
 	target, err := handler.Targets(event)
 	if err != nil {
@@ -50,7 +50,7 @@ func TestRoomCreationNotificationHandler_Targets(t *testing.T) {
 	}
 
 	expected := []notifications.Target{
-		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false},
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
 	}
 
 	if !reflect.DeepEqual(target, expected) {
@@ -103,6 +103,7 @@ func TestRoomCreationNotificationHandler_Build(t *testing.T) {
 		},
 		Arguments:  map[string]interface{}{"id": room, "creator": user},
 		CollapseID: room,
+		Image:      "t",
 	}
 
 	if !reflect.DeepEqual(n, notification) {
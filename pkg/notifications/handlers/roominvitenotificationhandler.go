@@ -54,23 +54,17 @@ func (r RoomInviteNotificationHandler) Build(event *pubsub.Event) (*notification
 	name := event.Params["name"].(string)
 	room := event.Params["room"].(string)
 
-	displayName, err := r.getDisplayName(creator)
+	user, err := r.users.FindByID(creator)
 	if err != nil {
 		return nil, err
 	}
 
+	notification := notifications.NewRoomInviteNotificationWithName(room, user.DisplayName, name)
 	if name == "" {
-		return notifications.NewRoomInviteNotification(room, displayName), nil
+		notification = notifications.NewRoomInviteNotification(room, user.DisplayName)
 	}
 
-	return notifications.NewRoomInviteNotificationWithName(room, displayName, name), nil
-}
-
-func (r RoomInviteNotificationHandler) getDisplayName(id int) (string, error) {
-	user, err := r.users.FindByID(id)
-	if err != nil {
-		return "", err
-	}
+	notification.Image = user.Image
 
-	return user.DisplayName, nil
+	return notification, nil
 }
@@ -35,7 +35,7 @@ func TestRoomInviteNotificationHandler_Targets(t *testing.T) {
 	mock.
 		ExpectPrepare("SELECT").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms"}).FromCSVString("1,2,false,false"))
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("1,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
 
 	target, err := handler.Targets(event)
 	if err != nil {
@@ -43,7 +43,7 @@ func TestRoomInviteNotificationHandler_Targets(t *testing.T) {
 	}
 
 	expected := []notifications.Target{
-		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false},
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
 	}
 
 	if !reflect.DeepEqual(target, expected) {
@@ -66,6 +66,7 @@ func TestRoomInviteNotificationHandler_Build(t *testing.T) {
 				},
 				Arguments:  map[string]interface{}{"id": "xyz"},
 				CollapseID: "xyz",
+				Image:      "t",
 			},
 		},
 		{
@@ -78,6 +79,7 @@ func TestRoomInviteNotificationHandler_Build(t *testing.T) {
 				},
 				Arguments:  map[string]interface{}{"id": "xyz"},
 				CollapseID: "xyz",
+				Image:      "t",
 			},
 		},
 	}
@@ -2,7 +2,7 @@ package handlers
 
 import (
 	"context"
-	"strconv"
+	"strings"
 
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
@@ -40,7 +40,7 @@ func (r RoomJoinNotificationHandler) Targets(event *pubsub.Event) ([]notificatio
 		return nil, err
 	}
 
-	targets, err := r.targets.GetSettingsFollowingUser(creator)
+	targets, err := r.targets.GetSettingsForRoomAlerts(creator)
 	if err != nil {
 		return nil, err
 	}
@@ -106,55 +106,36 @@ func (r RoomJoinNotificationHandler) Build(event *pubsub.Event) (*notifications.
 		return nil, errMemberNoLongerPresent
 	}
 
-	translation := "join_room_with_"
-	args := make([]string, 0)
-
-	if state.Name != "" {
-		translation = "join_room_name_with_"
-		args = append(args, state.Name)
-	}
-
 	count := len(state.Members)
 
-	if count == 1 {
-		translation += "1"
-		args = append(args, state.Members[0].DisplayName)
-	}
-
-	if count == 2 {
-		translation += "2"
-		args = append(args, state.Members[0].DisplayName, state.Members[1].DisplayName)
-	}
-
-	if count == 3 {
-		translation += "3"
-		args = append(args, state.Members[0].DisplayName, state.Members[1].DisplayName, state.Members[2].DisplayName)
-	}
-
+	names := make([]string, 0, 3)
 	if count > 3 {
-		translation += "3_and_more"
-
-		members := members(state.Members, creator)
-		if len(members) < 3 {
+		names = members(state.Members, creator)
+		if len(names) < 3 {
 			return nil, errFailedToSort
 		}
-
-		args = append(args, members[0], members[1], members[2], strconv.Itoa(count-3))
+	} else {
+		for _, member := range state.Members {
+			names = append(names, member.DisplayName)
+		}
 	}
 
-	notification := &notifications.PushNotification{
-		Category: notifications.ROOM_JOINED,
-		Alert: notifications.Alert{
-			Key:       translation + "_notification",
-			Arguments: args,
-		},
-		CollapseID: room,
-		Arguments:  map[string]interface{}{"id": room, "creator": creator},
-	}
+	notification := notifications.NewRoomJoinedNotification(room, creator, state.Name, names, count)
+	notification.Image = image(state.Members, creator)
 
 	return notification, nil
 }
 
+// Variants tests shorter copy against the original alert, both variants receive half of the targets.
+func (r RoomJoinNotificationHandler) Variants(notification *notifications.PushNotification) []notifications.Variant {
+	key := notification.Alert.Key
+
+	return []notifications.Variant{
+		{Name: "control", Key: key, Weight: 1},
+		{Name: "short", Key: strings.TrimSuffix(key, "_notification") + "_short_notification", Weight: 1, Rendered: true},
+	}
+}
+
 func containsId(arr []int64, id int64) bool {
 	for _, allowed := range arr {
 		if id == allowed {
@@ -180,6 +161,17 @@ func members(members []*pb.RoomState_RoomMember, first int) []string {
 	return names
 }
 
+// image returns the profile picture of a member.
+func image(members []*pb.RoomState_RoomMember, id int) string {
+	for _, member := range members {
+		if member.Id == int64(id) {
+			return member.Image
+		}
+	}
+
+	return ""
+}
+
 func contains(members []*pb.RoomState_RoomMember, id int64) bool {
 	for _, member := range members {
 		if member.Id == id {
@@ -42,9 +42,9 @@ func TestRoomJoinNotificationHandler_Targets(t *testing.T) {
 		ExpectPrepare("SELECT").
 		ExpectQuery().
 		WillReturnRows(
-			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms"}).
-				AddRow(1, 2, false, false).
-				AddRow(2, 2, false, false),
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles", "subscribed"}).
+				AddRow(1, 2, false, false, "UTC", false, 1320, 420, "en", nil, nil, true).
+				AddRow(2, 2, false, false, "UTC", false, 1320, 420, "en", nil, nil, false),
 		)
 
 	m.EXPECT().FilterUsersThatCanJoin(gomock.Any(), gomock.Any()).Return(&pb.FilterUsersThatCanJoinResponse{Ids: []int64{1}}, nil)
@@ -55,7 +55,7 @@ func TestRoomJoinNotificationHandler_Targets(t *testing.T) {
 	}
 
 	expected := []notifications.Target{
-		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false},
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences, Subscribed: true},
 	}
 
 	if !reflect.DeepEqual(target, expected) {
@@ -176,3 +176,24 @@ func TestRoomJoinNotificationBuilder_Build(t *testing.T) {
 		})
 	}
 }
+
+func TestRoomJoinNotificationHandler_Variants(t *testing.T) {
+	handler := handlers.NewRoomJoinNotificationHandler(nil, nil)
+
+	if name := handlers.Name(handler); name != "RoomJoin" {
+		t.Fatalf("unexpected name %s", name)
+	}
+
+	notification := notifications.NewRoomJoinedNotification("123", 1, "", []string{"foo"}, 1)
+
+	variants := handler.Variants(notification)
+
+	expected := []notifications.Variant{
+		{Name: "control", Key: "join_room_with_1_notification", Weight: 1},
+		{Name: "short", Key: "join_room_with_1_short_notification", Weight: 1, Rendered: true},
+	}
+
+	if !reflect.DeepEqual(variants, expected) {
+		t.Fatalf("expected %v actual %v", expected, variants)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+type StoryNotificationHandler struct {
+	targets *notifications.Settings
+	users   *users.Backend
+}
+
+func NewStoryNotificationHandler(targets *notifications.Settings, u *users.Backend) *StoryNotificationHandler {
+	return &StoryNotificationHandler{
+		targets: targets,
+		users:   u,
+	}
+}
+
+func (s StoryNotificationHandler) Type() pubsub.EventType {
+	return pubsub.EventTypeNewStory
+}
+
+func (s StoryNotificationHandler) Origin(event *pubsub.Event) (int, error) {
+	creator, err := event.GetInt("creator")
+	if err != nil {
+		return 0, err
+	}
+
+	return creator, nil
+}
+
+func (s StoryNotificationHandler) Targets(event *pubsub.Event) ([]notifications.Target, error) {
+	creator, err := event.GetInt("creator")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.targets.GetSettingsFollowingUser(creator)
+}
+
+func (s StoryNotificationHandler) Build(event *pubsub.Event) (*notifications.PushNotification, error) {
+	creator, err := event.GetInt("creator")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.users.FindByID(creator)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := notifications.NewStoryNotification(creator, user.DisplayName)
+	notification.Image = user.Image
+
+	return notification, nil
+}
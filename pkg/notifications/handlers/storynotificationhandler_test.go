@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/handlers"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+func TestStoryNotificationHandler_Targets(t *testing.T) {
+	raw := pubsub.NewStoryCreationEvent(12, "1234")
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := handlers.NewStoryNotificationHandler(notifications.NewSettings(db), nil)
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WithArgs(12).
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("1,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
+
+	target, err := handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []notifications.Target{
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
+	}
+
+	if !reflect.DeepEqual(target, expected) {
+		t.Fatalf("expected %v actual %v", expected, target)
+	}
+}
+
+func TestStoryNotificationHandler_Build(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := handlers.NewStoryNotificationHandler(nil, users.NewBackend(db))
+
+	raw := pubsub.NewStoryCreationEvent(12, "1234")
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"id", "display_name", "username", "image", "bio", "email"}).FromCSVString("12,foo,t,t,t,t"))
+
+	n, err := handler.Build(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &notifications.PushNotification{
+		Category: notifications.NEW_STORY,
+		Alert: notifications.Alert{
+			Key:       "new_story_notification",
+			Arguments: []string{"foo"},
+		},
+		Arguments: map[string]interface{}{"id": 12},
+		Image:     "t",
+	}
+
+	if !reflect.DeepEqual(n, notification) {
+		t.Fatalf("expected %v actual %v", notification, n)
+	}
+}
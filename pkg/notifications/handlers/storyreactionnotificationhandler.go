@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/stories"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+type StoryReactionNotificationHandler struct {
+	targets *notifications.Settings
+	users   *users.Backend
+	stories *stories.Backend
+}
+
+func NewStoryReactionNotificationHandler(targets *notifications.Settings, u *users.Backend, s *stories.Backend) *StoryReactionNotificationHandler {
+	return &StoryReactionNotificationHandler{
+		targets: targets,
+		users:   u,
+		stories: s,
+	}
+}
+
+func (s StoryReactionNotificationHandler) Type() pubsub.EventType {
+	return pubsub.EventTypeStoryReaction
+}
+
+func (s StoryReactionNotificationHandler) Origin(event *pubsub.Event) (int, error) {
+	id, err := event.GetInt("id")
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s StoryReactionNotificationHandler) Targets(event *pubsub.Event) ([]notifications.Target, error) {
+	id, err := event.GetInt("id")
+	if err != nil {
+		return nil, err
+	}
+
+	story, ok := event.Params["story"].(string)
+	if !ok {
+		return nil, errNoStory
+	}
+
+	owner, err := s.stories.GetStoryOwner(story)
+	if err != nil {
+		return nil, err
+	}
+
+	// users are not notified of reactions to their own stories.
+	if owner == id {
+		return []notifications.Target{}, nil
+	}
+
+	target, err := s.targets.GetSettingsFor(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return []notifications.Target{*target}, nil
+}
+
+func (s StoryReactionNotificationHandler) Build(event *pubsub.Event) (*notifications.PushNotification, error) {
+	id, err := event.GetInt("id")
+	if err != nil {
+		return nil, err
+	}
+
+	story, ok := event.Params["story"].(string)
+	if !ok {
+		return nil, errNoStory
+	}
+
+	reaction, _ := event.Params["reaction"].(string)
+
+	user, err := s.users.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	notification := notifications.NewStoryReactionNotification(story, id, user.DisplayName, reaction)
+	notification.Image = user.Image
+
+	return notification, nil
+}
@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/handlers"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/stories"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+func TestStoryReactionNotificationHandler_Targets(t *testing.T) {
+	raw := pubsub.NewStoryReactionEvent(12, "1234", "🔥")
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := handlers.NewStoryReactionNotificationHandler(notifications.NewSettings(db), nil, stories.NewBackend(db))
+
+	mock.
+		ExpectPrepare("SELECT user_id FROM stories").
+		ExpectQuery().
+		WithArgs("1234").
+		WillReturnRows(mock.NewRows([]string{"user_id"}).AddRow(1))
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("1,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
+
+	target, err := handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []notifications.Target{
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
+	}
+
+	if !reflect.DeepEqual(target, expected) {
+		t.Fatalf("expected %v actual %v", expected, target)
+	}
+}
+
+func TestStoryReactionNotificationHandler_TargetsWithOwnStory(t *testing.T) {
+	raw := pubsub.NewStoryReactionEvent(12, "1234", "🔥")
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := handlers.NewStoryReactionNotificationHandler(notifications.NewSettings(db), nil, stories.NewBackend(db))
+
+	mock.
+		ExpectPrepare("SELECT user_id FROM stories").
+		ExpectQuery().
+		WithArgs("1234").
+		WillReturnRows(mock.NewRows([]string{"user_id"}).AddRow(12))
+
+	target, err := handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(target) != 0 {
+		t.Fatalf("expected no targets actual %v", target)
+	}
+}
+
+func TestStoryReactionNotificationHandler_Build(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	handler := handlers.NewStoryReactionNotificationHandler(nil, users.NewBackend(db), nil)
+
+	raw := pubsub.NewStoryReactionEvent(12, "1234", "🔥")
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"id", "display_name", "username", "image", "bio", "email"}).FromCSVString("12,foo,t,t,t,t"))
+
+	n, err := handler.Build(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := &notifications.PushNotification{
+		Category: notifications.STORY_REACTION,
+		Alert: notifications.Alert{
+			Key:       "story_reaction_notification",
+			Arguments: []string{"foo", "🔥"},
+		},
+		Arguments:  map[string]interface{}{"id": "1234", "from": 12},
+		CollapseID: "1234",
+		Image:      "t",
+	}
+
+	if !reflect.DeepEqual(n, notification) {
+		t.Fatalf("expected %v actual %v", notification, n)
+	}
+}
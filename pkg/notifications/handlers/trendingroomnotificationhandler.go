@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
+)
+
+const (
+	// trendingRoomSize is the amount of members a room needs to be trending.
+	trendingRoomSize = 25
+
+	// trendingRoomJoins is the amount of joins within the trendingRoomWindow a room needs to be trending.
+	trendingRoomJoins  = 10
+	trendingRoomWindow = 5 * time.Minute
+
+	// trendingRoomCooldown is how long a room is not trending again after followers were notified of it.
+	trendingRoomCooldown = 3 * time.Hour
+)
+
+// TrendingRoomNotificationHandler notifies the followers of the members of a public room that is growing quickly.
+type TrendingRoomNotificationHandler struct {
+	targets  *notifications.Settings
+	metadata pb.RoomServiceClient
+	rdb      *redis.Client
+}
+
+func NewTrendingRoomNotificationHandler(targets *notifications.Settings, metadata pb.RoomServiceClient, rdb *redis.Client) *TrendingRoomNotificationHandler {
+	return &TrendingRoomNotificationHandler{
+		targets:  targets,
+		metadata: metadata,
+		rdb:      rdb,
+	}
+}
+
+func (t TrendingRoomNotificationHandler) Type() pubsub.EventType {
+	return pubsub.EventTypeRoomJoin
+}
+
+func (t TrendingRoomNotificationHandler) Origin(event *pubsub.Event) (int, error) {
+	creator, err := event.GetInt("creator")
+	if err != nil {
+		return 0, err
+	}
+
+	return creator, nil
+}
+
+// Targets returns no targets until the room crosses the size or join velocity threshold, which it only does once per cooldown.
+func (t TrendingRoomNotificationHandler) Targets(event *pubsub.Event) ([]notifications.Target, error) {
+	if pubsub.RoomVisibility(event.Params["visibility"].(string)) == pubsub.Private {
+		return []notifications.Target{}, nil
+	}
+
+	joined, err := event.GetInt("creator")
+	if err != nil {
+		return nil, err
+	}
+
+	room := event.Params["id"].(string)
+
+	joins, err := t.recordJoin(room, joined)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := t.state(room)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.Visibility != pb.Visibility_VISIBILITY_PUBLIC {
+		return []notifications.Target{}, nil
+	}
+
+	if len(state.Members) < trendingRoomSize && joins < trendingRoomJoins {
+		return []notifications.Target{}, nil
+	}
+
+	trending, err := t.rdb.SetNX(t.rdb.Context(), trendingRoomKey(room), true, trendingRoomCooldown).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if !trending {
+		return []notifications.Target{}, nil
+	}
+
+	members := make([]int64, 0, len(state.Members))
+	for _, member := range state.Members {
+		members = append(members, member.Id)
+	}
+
+	targets, err := t.targets.GetSettingsForTrendingRoom(members, joined)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(targets) == 0 {
+		return targets, nil
+	}
+
+	ids := make([]int64, 0, len(targets))
+	for _, target := range targets {
+		ids = append(ids, int64(target.ID))
+	}
+
+	resp, err := t.metadata.FilterUsersThatCanJoin(
+		context.TODO(),
+		&pb.FilterUsersThatCanJoinRequest{Room: room, Ids: ids},
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]notifications.Target, 0)
+	for _, target := range targets {
+		if containsId(resp.Ids, int64(target.ID)) {
+			res = append(res, target)
+		}
+	}
+
+	return res, nil
+}
+
+func (t TrendingRoomNotificationHandler) Build(event *pubsub.Event) (*notifications.PushNotification, error) {
+	if pubsub.RoomVisibility(event.Params["visibility"].(string)) == pubsub.Private {
+		return nil, errRoomPrivate
+	}
+
+	joined, err := event.GetInt("creator")
+	if err != nil {
+		return nil, err
+	}
+
+	room := event.Params["id"].(string)
+	state, err := t.state(room)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(state.Members) == 0 {
+		return nil, errNoRoomMembers
+	}
+
+	// the user that joined is shown, unless they already left.
+	member := state.Members[0]
+	for _, m := range state.Members {
+		if m.Id == int64(joined) {
+			member = m
+			break
+		}
+	}
+
+	notification := notifications.NewTrendingRoomNotification(room, state.Name, member.DisplayName, len(state.Members))
+	notification.Image = member.Image
+
+	return notification, nil
+}
+
+func (t TrendingRoomNotificationHandler) state(room string) (*pb.RoomState, error) {
+	response, err := t.metadata.GetRoom(context.Background(), &pb.GetRoomRequest{Id: room})
+	if err != nil {
+		return nil, err
+	}
+
+	if response == nil || response.State == nil {
+		return nil, errEmptyResponse
+	}
+
+	return response.State, nil
+}
+
+// recordJoin adds a join to the room and returns the amount of users that joined within the trendingRoomWindow.
+func (t TrendingRoomNotificationHandler) recordJoin(room string, user int) (int64, error) {
+	ctx := t.rdb.Context()
+	key := trendingRoomJoinsKey(room)
+	now := time.Now()
+
+	pipe := t.rdb.TxPipeline()
+	pipe.ZAdd(ctx, key, &redis.Z{Score: float64(now.UnixNano()), Member: user})
+	pipe.ZRemRangeByScore(ctx, key, "-inf", strconv.FormatInt(now.Add(-trendingRoomWindow).UnixNano(), 10))
+	count := pipe.ZCard(ctx, key)
+	pipe.Expire(ctx, key, trendingRoomWindow)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return count.Val(), nil
+}
+
+func trendingRoomJoinsKey(room string) string {
+	return fmt.Sprintf("trending_room_joins_%s", room)
+}
+
+func trendingRoomKey(room string) string {
+	return fmt.Sprintf("trending_room_%s", room)
+}
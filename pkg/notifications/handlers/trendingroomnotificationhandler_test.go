@@ -0,0 +1,240 @@
+package handlers_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/golang/mock/gomock"
+
+	"github.com/soapboxsocial/soapbox/mocks"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/handlers"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
+)
+
+func trendingRoomState(size int) *pb.RoomState {
+	members := make([]*pb.RoomState_RoomMember, 0, size)
+	for i := 1; i <= size; i++ {
+		members = append(members, &pb.RoomState_RoomMember{Id: int64(i), DisplayName: "foo", Image: "t"})
+	}
+
+	return &pb.RoomState{Id: "xyz", Visibility: pb.Visibility_VISIBILITY_PUBLIC, Members: members}
+}
+
+func TestTrendingRoomNotificationHandler_Targets(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockRoomServiceClient(ctrl)
+
+	handler := handlers.NewTrendingRoomNotificationHandler(notifications.NewSettings(db), m, rdb)
+
+	raw := pubsub.NewRoomJoinEvent("xyz", 1, pubsub.Public)
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a small room is not trending.
+	m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.GetRoomResponse{State: trendingRoomState(3)}, nil)
+
+	targets, err := handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 0 {
+		t.Fatalf("expected no targets actual %v", targets)
+	}
+
+	m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.GetRoomResponse{State: trendingRoomState(30)}, nil)
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				FromCSVString("40,2,true,true,UTC,false,1320,420,en,NULL,NULL\n41,2,true,true,UTC,false,1320,420,en,NULL,NULL"),
+		)
+
+	m.EXPECT().FilterUsersThatCanJoin(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.FilterUsersThatCanJoinResponse{Ids: []int64{41}}, nil)
+
+	targets, err = handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []notifications.Target{
+		{ID: 41, RoomFrequency: 2, Follows: true, WelcomeRooms: true, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en"},
+	}
+
+	if !reflect.DeepEqual(targets, expected) {
+		t.Fatalf("expected %v actual %v", expected, targets)
+	}
+
+	// followers are only notified once while the room is trending.
+	m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.GetRoomResponse{State: trendingRoomState(31)}, nil)
+
+	targets, err = handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 0 {
+		t.Fatalf("expected no targets actual %v", targets)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrendingRoomNotificationHandler_Targets_Velocity(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mr.Close()
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockRoomServiceClient(ctrl)
+	m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.GetRoomResponse{State: trendingRoomState(12)}, nil).AnyTimes()
+	m.EXPECT().FilterUsersThatCanJoin(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.FilterUsersThatCanJoinResponse{Ids: []int64{40}}, nil)
+
+	handler := handlers.NewTrendingRoomNotificationHandler(notifications.NewSettings(db), m, rdb)
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				FromCSVString("40,2,true,true,UTC,false,1320,420,en,NULL,NULL"),
+		)
+
+	for i := 1; i <= 10; i++ {
+		raw := pubsub.NewRoomJoinEvent("xyz", i, pubsub.Public)
+		event, err := getRawEvent(&raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		targets, err := handler.Targets(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i < 10 && len(targets) != 0 {
+			t.Fatalf("expected no targets after %d joins actual %v", i, targets)
+		}
+
+		if i == 10 && len(targets) != 1 {
+			t.Fatalf("expected 1 target after %d joins actual %v", i, targets)
+		}
+	}
+}
+
+func TestTrendingRoomNotificationHandler_Targets_Private(t *testing.T) {
+	handler := handlers.NewTrendingRoomNotificationHandler(nil, nil, nil)
+
+	raw := pubsub.NewRoomJoinEvent("xyz", 1, pubsub.Private)
+	event, err := getRawEvent(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets, err := handler.Targets(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 0 {
+		t.Fatalf("expected no targets actual %v", targets)
+	}
+}
+
+func TestTrendingRoomNotificationHandler_Build(t *testing.T) {
+	tests := []struct {
+		state        *pb.RoomState
+		notification *notifications.PushNotification
+	}{
+		{
+			state: trendingRoomState(30),
+			notification: &notifications.PushNotification{
+				Category:   notifications.TRENDING_ROOM,
+				Alert:      notifications.Alert{Key: "trending_room_notification", Arguments: []string{"foo", "29"}},
+				Arguments:  map[string]interface{}{"id": "xyz"},
+				CollapseID: "xyz",
+				Image:      "t",
+			},
+		},
+		{
+			state: &pb.RoomState{Name: "Test", Members: trendingRoomState(30).Members},
+			notification: &notifications.PushNotification{
+				Category:   notifications.TRENDING_ROOM,
+				Alert:      notifications.Alert{Key: "trending_room_name_notification", Arguments: []string{"Test", "30"}},
+				Arguments:  map[string]interface{}{"id": "xyz"},
+				CollapseID: "xyz",
+				Image:      "t",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ctrl := gomock.NewController(t)
+
+		m := mocks.NewMockRoomServiceClient(ctrl)
+		m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(&pb.GetRoomResponse{State: tt.state}, nil)
+
+		handler := handlers.NewTrendingRoomNotificationHandler(nil, m, nil)
+
+		raw := pubsub.NewRoomJoinEvent("xyz", 1, pubsub.Public)
+		event, err := getRawEvent(&raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := handler.Build(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(n, tt.notification) {
+			t.Fatalf("expected %v actual %v", tt.notification, n)
+		}
+
+		ctrl.Finish()
+	}
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 )
@@ -20,3 +23,20 @@ type Handler interface {
 	// Build builds the notification
 	Build(event *pubsub.Event) (*notifications.PushNotification, error)
 }
+
+// VariantHandler is implemented by handlers that test multiple variants of their alert copy.
+type VariantHandler interface {
+
+	// Variants returns the alert key variants of a built notification with their traffic weights
+	Variants(notification *notifications.PushNotification) []notifications.Variant
+}
+
+// Name returns the name of a handler, it is recorded with the notifications it builds for reporting.
+func Name(h Handler) string {
+	t := reflect.TypeOf(h)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return strings.TrimSuffix(t.Name(), "NotificationHandler")
+}
@@ -3,9 +3,16 @@ package handlers_test
 import (
 	"encoding/json"
 
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 )
 
+// legacyPreferences are the preferences of targets with follows and welcome rooms turned off.
+var legacyPreferences = notifications.Preferences{
+	notifications.NEW_FOLLOWER: {Inbox: true},
+	notifications.WELCOME_ROOM: {Inbox: true},
+}
+
 func getRawEvent(event *pubsub.Event) (*pubsub.Event, error) {
 	data, err := json.Marshal(event)
 	if err != nil {
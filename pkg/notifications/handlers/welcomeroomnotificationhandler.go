@@ -5,15 +5,10 @@ import (
 
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 	"github.com/soapboxsocial/soapbox/pkg/users"
 )
 
-var staticTargets = []notifications.Target{
-	{ID: 1},
-	{ID: 75},
-	{ID: 962},
-}
-
 type WelcomeRoomNotificationHandler struct {
 	users    *users.Backend
 	settings *notifications.Settings
@@ -44,7 +39,12 @@ func (w WelcomeRoomNotificationHandler) Targets(*pubsub.Event) ([]notifications.
 		targets = targets[:6]
 	}
 
-	return append(targets, staticTargets...), nil
+	greeters, err := w.settings.GetSettingsForPermission(roles.PermissionGreet)
+	if err != nil {
+		log.Printf("settings.GetSettingsForPermission err: %s", err)
+	}
+
+	return append(targets, greeters...), nil
 }
 
 func (w WelcomeRoomNotificationHandler) Build(event *pubsub.Event) (*notifications.PushNotification, error) {
@@ -55,7 +55,7 @@ func (w WelcomeRoomNotificationHandler) Build(event *pubsub.Event) (*notificatio
 
 	room := event.Params["room"].(string)
 
-	displayName, err := w.getDisplayName(creator)
+	user, err := w.users.FindByID(creator)
 	if err != nil {
 		return nil, err
 	}
@@ -64,18 +64,10 @@ func (w WelcomeRoomNotificationHandler) Build(event *pubsub.Event) (*notificatio
 		Category: notifications.WELCOME_ROOM,
 		Alert: notifications.Alert{
 			Key:       "welcome_room_notification",
-			Arguments: []string{displayName},
+			Arguments: []string{user.DisplayName},
 		},
 		Arguments:  map[string]interface{}{"id": room, "from": creator},
 		CollapseID: room,
+		Image:      user.Image,
 	}, nil
 }
-
-func (w WelcomeRoomNotificationHandler) getDisplayName(id int) (string, error) {
-	user, err := w.users.FindByID(id)
-	if err != nil {
-		return "", err
-	}
-
-	return user.DisplayName, nil
-}
@@ -9,6 +9,7 @@ import (
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
 	"github.com/soapboxsocial/soapbox/pkg/notifications/handlers"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 	"github.com/soapboxsocial/soapbox/pkg/users"
 )
 
@@ -33,7 +34,15 @@ func TestWelcomeRoomNotificationHandler_Targets(t *testing.T) {
 	mock.
 		ExpectPrepare("SELECT").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms"}).FromCSVString("12,2,false,false"))
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).FromCSVString("12,2,false,false,UTC,false,1320,420,en,NULL,NULL"))
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WithArgs(`{"greeter"}`).
+		WillReturnRows(mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+			AddRow(1, 2, false, false, "UTC", false, 1320, 420, "en", nil, `["greeter", "staff"]`).
+			AddRow(962, 2, false, false, "UTC", false, 1320, 420, "en", nil, `["greeter"]`))
 
 	target, err := handler.Targets(event)
 	if err != nil {
@@ -41,10 +50,9 @@ func TestWelcomeRoomNotificationHandler_Targets(t *testing.T) {
 	}
 
 	expected := []notifications.Target{
-		{ID: 12, RoomFrequency: 2, Follows: false, WelcomeRooms: false},
-		{ID: 1, RoomFrequency: 0, Follows: false, WelcomeRooms: false},
-		{ID: 75, RoomFrequency: 0, Follows: false, WelcomeRooms: false},
-		{ID: 962, RoomFrequency: 0, Follows: false, WelcomeRooms: false},
+		{ID: 12, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences},
+		{ID: 1, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences, Roles: []roles.Role{roles.Greeter, roles.Staff}},
+		{ID: 962, RoomFrequency: 2, Follows: false, WelcomeRooms: false, Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 1320, End: 420}, Locale: "en", Preferences: legacyPreferences, Roles: []roles.Role{roles.Greeter}},
 	}
 
 	if !reflect.DeepEqual(target, expected) {
@@ -90,6 +98,7 @@ func TestWelcomeRoomNotificationHandler_Build(t *testing.T) {
 		},
 		Arguments:  map[string]interface{}{"id": room, "from": user},
 		CollapseID: room,
+		Image:      "t",
 	}
 
 	if !reflect.DeepEqual(n, notification) {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 	"github.com/soapboxsocial/soapbox/pkg/rooms"
 )
 
@@ -13,11 +14,23 @@ import (
 
 var (
 	followerCooldown     = 15 * time.Minute
+	followerDigestWindow = 15 * time.Minute
 	roomInviteCooldown   = 3 * time.Minute
 	roomMemberCooldown   = 5 * time.Minute
 	roomCooldown         = 10 * time.Minute
 	welcomeRoomCooldown  = 30 * time.Minute
+
+	// trendingRoomCooldown limits how often users are told about trending rooms, it is scaled by their room frequency.
+	trendingRoomCooldown = 2 * time.Hour
 	reEngagementCooldown = (24 * time.Hour) * 7
+
+	// storyCooldown limits notifications per creator, followers are notified of their first story only.
+	storyCooldown         = 6 * time.Hour
+	storyDigestWindow     = 30 * time.Minute
+	storyReactionCooldown = 10 * time.Minute
+
+	// feedRefreshCooldown keeps silent pushes within the budget iOS allows for background refreshes.
+	feedRefreshCooldown = 30 * time.Minute
 )
 
 type Limiter struct {
@@ -32,55 +45,107 @@ func NewLimiter(rdb *redis.Client, currentRoom *rooms.CurrentRoomBackend) *Limit
 	}
 }
 
+// ChannelsFor returns the channels a notification is delivered to a target on.
+func (l *Limiter) ChannelsFor(target Target, notification *PushNotification) ChannelPreferences {
+	if isWelcomeRoomForGreeter(target, notification) {
+		return ChannelPreferences{Push: true, Inbox: true}
+	}
+
+	channels := target.PreferencesFor(notification.Category)
+
+	// followers are only emailed as a digest.
+	if notification.Category == NEW_FOLLOWER {
+		channels.Email = false
+	}
+
+	return channels
+}
+
+// ShouldSendNotification returns whether a notification should be delivered to a target on any channel.
 func (l *Limiter) ShouldSendNotification(target Target, notification *PushNotification) bool {
-	switch notification.Category {
-	case NEW_ROOM, ROOM_JOINED:
-		if target.RoomFrequency == FrequencyOff {
+	if isWelcomeRoomForGreeter(target, notification) {
+		return true
+	}
+
+	if !target.AllowsAny(notification.Category) {
+		return false
+	}
+
+	if isTimeSensitive(notification.Category) {
+		if _, quiet := target.QuietHoursEnd(time.Now()); quiet {
 			return false
 		}
+	}
 
+	switch notification.Category {
+	case NEW_ROOM, ROOM_JOINED:
 		if l.isLimited(limiterKeyForRoom(target.ID, notification)) {
 			return false
 		}
 
-		if l.isLimited(limiterKeyForRoomMember(target.ID, notification)) {
+		// subscribers are alerted whenever the creator goes live, regardless of their room frequency.
+		if !target.Subscribed && l.isLimited(limiterKeyForRoomMember(target.ID, notification)) {
 			return false
 		}
 
-		if l.isUserInRoom(target.ID, notification) {
+		return !l.isUserInRoom(target.ID, notification)
+	case TRENDING_ROOM:
+		// users that were already notified of the room are not notified again.
+		if l.isLimited(limiterKeyForRoom(target.ID, notification)) || l.isLimited(limiterKeyForTrendingRoom(target.ID)) {
 			return false
 		}
 
-		return true
+		return !l.isUserInRoom(target.ID, notification)
 	case ROOM_INVITE:
 		return !l.isLimited(limiterKeyForRoomInvite(target.ID, notification))
 	case NEW_FOLLOWER:
-		if !target.Follows {
-			return false
-		}
-
 		return !l.isLimited(limiterKeyForFollower(target.ID, notification))
 	case REENGAGEMENT:
-		return !l.isLimited(limiterKeyForFollower(target.ID, notification))
+		return !l.isLimited(limiterKeyForReEngagement(target.ID))
 	case WELCOME_ROOM:
-		if target.ID == 1 || target.ID == 75 || target.ID == 962 {
-			return true
-		}
-
-		if !target.WelcomeRooms {
-			return false
-		}
-
 		return !l.isLimited(limiterKeyForWelcomeRoom(target.ID))
+	case NEW_STORY:
+		return !l.isLimited(limiterKeyForStory(target.ID, notification))
+	case STORY_REACTION:
+		return !l.isLimited(limiterKeyForStoryReaction(target.ID, notification))
+	case FEED_REFRESH:
+		return !l.isLimited(limiterKeyForFeedRefresh(target.ID))
 	case TEST:
-		return target.ID == 1 || target.ID == 75
-	case INFO:
-		return true
-	case FOLLOW_RECOMMENDATIONS:
+		return target.Can(roles.PermissionReceiveTestNotifications)
+	default:
 		return true
+	}
+}
+
+// DeferNotification returns when to send a notification that can wait until the targets quiet hours end.
+func (l *Limiter) DeferNotification(target Target, notification *PushNotification) (time.Time, bool) {
+	switch notification.Category {
+	case NEW_FOLLOWER, NEW_FOLLOWER_DIGEST, REENGAGEMENT, INFO, FOLLOW_RECOMMENDATIONS, NEW_STORY, NEW_STORY_DIGEST, STORY_REACTION:
+		return target.QuietHoursEnd(time.Now())
 	default:
-		return false
+		return time.Time{}, false
+	}
+}
+
+// DigestNotification returns until when a notification should be accumulated into a digest.
+// This is the case while the target is in the window following a notification of the same category.
+func (l *Limiter) DigestNotification(target Target, notification *PushNotification) (time.Time, bool) {
+	var key string
+	switch notification.Category {
+	case NEW_FOLLOWER:
+		key = limiterKeyForFollowerDigest(target.ID)
+	case NEW_STORY:
+		key = limiterKeyForStoryDigest(target.ID)
+	default:
+		return time.Time{}, false
 	}
+
+	ttl, err := l.rdb.PTTL(l.rdb.Context(), key).Result()
+	if err != nil || ttl <= 0 {
+		return time.Time{}, false
+	}
+
+	return time.Now().Add(ttl), true
 }
 
 func (l *Limiter) SentNotification(target Target, notification *PushNotification) {
@@ -90,14 +155,29 @@ func (l *Limiter) SentNotification(target Target, notification *PushNotification
 	case ROOM_JOINED:
 		l.limit(limiterKeyForRoomMember(target.ID, notification), getLimitForRoomFrequency(target.RoomFrequency, roomMemberCooldown))
 		l.limit(limiterKeyForRoom(target.ID, notification), getLimitForRoomFrequency(target.RoomFrequency, roomCooldown))
+	case TRENDING_ROOM:
+		l.limit(limiterKeyForRoom(target.ID, notification), getLimitForRoomFrequency(target.RoomFrequency, roomCooldown))
+		l.limit(limiterKeyForTrendingRoom(target.ID), getLimitForRoomFrequency(target.RoomFrequency, trendingRoomCooldown))
 	case ROOM_INVITE:
 		l.limit(limiterKeyForRoomInvite(target.ID, notification), roomInviteCooldown)
 	case NEW_FOLLOWER:
 		l.limit(limiterKeyForFollower(target.ID, notification), followerCooldown)
+		l.limit(limiterKeyForFollowerDigest(target.ID), followerDigestWindow)
+	case NEW_FOLLOWER_DIGEST:
+		l.limit(limiterKeyForFollowerDigest(target.ID), followerDigestWindow)
 	case REENGAGEMENT:
 		l.limit(limiterKeyForReEngagement(target.ID), reEngagementCooldown)
+	case NEW_STORY:
+		l.limit(limiterKeyForStory(target.ID, notification), storyCooldown)
+		l.limit(limiterKeyForStoryDigest(target.ID), storyDigestWindow)
+	case NEW_STORY_DIGEST:
+		l.limit(limiterKeyForStoryDigest(target.ID), storyDigestWindow)
+	case STORY_REACTION:
+		l.limit(limiterKeyForStoryReaction(target.ID, notification), storyReactionCooldown)
+	case FEED_REFRESH:
+		l.limit(limiterKeyForFeedRefresh(target.ID), feedRefreshCooldown)
 	case WELCOME_ROOM:
-		if target.ID == 1 || target.ID == 75 || target.ID == 962 {
+		if target.Can(roles.PermissionGreet) {
 			return
 		}
 
@@ -105,6 +185,21 @@ func (l *Limiter) SentNotification(target Target, notification *PushNotification
 	}
 }
 
+// Reset removes all limits of a user.
+func (l *Limiter) Reset(user int) error {
+	ctx := l.rdb.Context()
+
+	iter := l.rdb.Scan(ctx, 0, fmt.Sprintf("notifications_limit_%d_*", user), 100).Iterator()
+	for iter.Next(ctx) {
+		err := l.rdb.Del(ctx, iter.Val()).Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
 const limitPlaceholder = "placeholder"
 
 func (l *Limiter) isLimited(key string) bool {
@@ -135,6 +230,10 @@ func limiterKeyForRoomMember(target int, notification *PushNotification) string
 	return fmt.Sprintf("notifications_limit_%d_room_member_%v", target, notification.Arguments["creator"])
 }
 
+func limiterKeyForTrendingRoom(target int) string {
+	return fmt.Sprintf("notifications_limit_%d_trending_room", target)
+}
+
 func limiterKeyForRoomInvite(target int, notification *PushNotification) string {
 	return fmt.Sprintf("notifications_limit_%d_room_invite_%v", target, notification.Arguments["id"])
 }
@@ -143,6 +242,10 @@ func limiterKeyForFollower(target int, notification *PushNotification) string {
 	return fmt.Sprintf("notifications_limit_%d_follower_%v", target, notification.Arguments["id"])
 }
 
+func limiterKeyForFollowerDigest(target int) string {
+	return fmt.Sprintf("notifications_limit_%d_follower_digest", target)
+}
+
 func limiterKeyForReEngagement(target int) string {
 	return fmt.Sprintf("notifications_limit_%d_re_engagement", target)
 }
@@ -151,11 +254,43 @@ func limiterKeyForWelcomeRoom(target int) string {
 	return fmt.Sprintf("notifications_limit_%d_welcome_room", target)
 }
 
+func limiterKeyForStory(target int, notification *PushNotification) string {
+	return fmt.Sprintf("notifications_limit_%d_story_%v", target, notification.Arguments["id"])
+}
+
+func limiterKeyForStoryDigest(target int) string {
+	return fmt.Sprintf("notifications_limit_%d_story_digest", target)
+}
+
+func limiterKeyForStoryReaction(target int, notification *PushNotification) string {
+	return fmt.Sprintf("notifications_limit_%d_story_reaction_%v", target, notification.Arguments["from"])
+}
+
+func limiterKeyForFeedRefresh(target int) string {
+	return fmt.Sprintf("notifications_limit_%d_feed_refresh", target)
+}
+
+// isWelcomeRoomForGreeter returns whether a welcome room is sent to a greeter, who always receive them.
+func isWelcomeRoomForGreeter(target Target, notification *PushNotification) bool {
+	return notification.Category == WELCOME_ROOM && target.Can(roles.PermissionGreet)
+}
+
+// isTimeSensitive returns whether a notification is only relevant when it is sent, these are dropped during quiet hours.
+func isTimeSensitive(category NotificationCategory) bool {
+	switch category {
+	case NEW_ROOM, ROOM_JOINED, ROOM_INVITE, WELCOME_ROOM, TRENDING_ROOM:
+		return true
+	default:
+		return false
+	}
+}
+
 func getLimitForRoomFrequency(frequency Frequency, base time.Duration) time.Duration {
 
 	// @TODO think about this frequency
 	switch frequency {
-	case Infrequent:
+	// users can turn room notifications back on in their preferences, they are limited the most.
+	case FrequencyOff, Infrequent:
 		return base * 5
 	case Normal:
 		return base
@@ -0,0 +1,11 @@
+package notifications
+
+import "regexp"
+
+// localeRegex matches BCP 47 style language tags like `en`, `pt-BR` or `zh_Hant`.
+var localeRegex = regexp.MustCompile("^[a-zA-Z]{2,3}([-_][a-zA-Z0-9]{2,8}){0,2}$")
+
+// IsValidLocale returns whether locale looks like a language tag.
+func IsValidLocale(locale string) bool {
+	return localeRegex.MatchString(locale)
+}
@@ -0,0 +1,170 @@
+package notifications
+
+// Channel is a way a notification is delivered to a user.
+type Channel string
+
+const (
+	ChannelPush  Channel = "push"
+	ChannelInbox Channel = "inbox"
+	ChannelEmail Channel = "email"
+)
+
+// ChannelPreferences are whether a category is delivered on each channel.
+type ChannelPreferences struct {
+	Push  bool `json:"push"`
+	Inbox bool `json:"inbox"`
+	Email bool `json:"email"`
+}
+
+// Allows returns whether the channel is enabled.
+func (p ChannelPreferences) Allows(channel Channel) bool {
+	switch channel {
+	case ChannelPush:
+		return p.Push
+	case ChannelInbox:
+		return p.Inbox
+	case ChannelEmail:
+		return p.Email
+	default:
+		return false
+	}
+}
+
+// Any returns whether any channel is enabled.
+func (p ChannelPreferences) Any() bool {
+	return p.Push || p.Inbox || p.Email
+}
+
+// Preferences is the per category matrix of channel preferences a user has set.
+type Preferences map[NotificationCategory]ChannelPreferences
+
+// Categories are the notification categories users can set preferences for.
+var Categories = []NotificationCategory{
+	NEW_ROOM,
+	NEW_FOLLOWER,
+	ROOM_INVITE,
+	ROOM_JOINED,
+	TRENDING_ROOM,
+	WELCOME_ROOM,
+	REENGAGEMENT,
+	INFO,
+	FOLLOW_RECOMMENDATIONS,
+	NEW_STORY,
+	STORY_REACTION,
+}
+
+// defaultChannelPreferences apply to every category without an entry in defaultPreferences.
+var defaultChannelPreferences = ChannelPreferences{Push: true, Inbox: true}
+
+var defaultPreferences = map[NotificationCategory]ChannelPreferences{
+	REENGAGEMENT: {Push: true},
+
+	// stories expire, their inbox rows are how users find them again.
+	NEW_STORY:      {Push: true, Inbox: true},
+	STORY_REACTION: {Push: true, Inbox: true},
+
+	// feed refreshes are silent, they are only pushed.
+	FEED_REFRESH: {Push: true},
+
+	// account security notifications are not configurable and always emailed.
+	ACCOUNT_SECURITY: {Push: true, Inbox: true, Email: true},
+}
+
+// preferenceCategories maps categories to the category whose preferences they share.
+var preferenceCategories = map[NotificationCategory]NotificationCategory{
+	NEW_FOLLOWER_DIGEST: NEW_FOLLOWER,
+	NEW_STORY_DIGEST:    NEW_STORY,
+}
+
+// IsConfigurableCategory returns whether users can set preferences for a category.
+func IsConfigurableCategory(category NotificationCategory) bool {
+	for _, c := range Categories {
+		if c == category {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DefaultPreferencesFor returns the preferences for a category a user has not configured.
+func DefaultPreferencesFor(category NotificationCategory) ChannelPreferences {
+	if p, ok := defaultPreferences[category]; ok {
+		return p
+	}
+
+	return defaultChannelPreferences
+}
+
+// PreferenceCategory returns the category whose preferences apply to a category.
+func PreferenceCategory(category NotificationCategory) NotificationCategory {
+	if c, ok := preferenceCategories[category]; ok {
+		return c
+	}
+
+	return category
+}
+
+// PreferencesFor returns the targets preferences for a category.
+func (t Target) PreferencesFor(category NotificationCategory) ChannelPreferences {
+	category = PreferenceCategory(category)
+
+	if p, ok := t.Preferences[category]; ok && IsConfigurableCategory(category) {
+		return p
+	}
+
+	return DefaultPreferencesFor(category)
+}
+
+// Allows returns whether the target wants to receive a category on a channel.
+func (t Target) Allows(category NotificationCategory, channel Channel) bool {
+	return t.PreferencesFor(category).Allows(channel)
+}
+
+// AllowsAny returns whether the target wants to receive a category on any channel.
+func (t Target) AllowsAny(category NotificationCategory) bool {
+	return t.PreferencesFor(category).Any()
+}
+
+// AllPreferences returns the complete matrix of preferences for every configurable category.
+func (t Target) AllPreferences() Preferences {
+	all := make(Preferences)
+	for _, category := range Categories {
+		all[category] = t.PreferencesFor(category)
+	}
+
+	return all
+}
+
+// applyLegacySettings disables push for categories that were turned off with the settings that predate preferences.
+// Preferences that were explicitly set take precedence.
+func (t *Target) applyLegacySettings() {
+	legacy := []struct {
+		category NotificationCategory
+		enabled  bool
+	}{
+		{NEW_ROOM, t.RoomFrequency != FrequencyOff},
+		{ROOM_JOINED, t.RoomFrequency != FrequencyOff},
+		{NEW_FOLLOWER, t.Follows},
+		{WELCOME_ROOM, t.WelcomeRooms},
+	}
+
+	for _, setting := range legacy {
+		if setting.enabled {
+			continue
+		}
+
+		if _, ok := t.Preferences[setting.category]; ok {
+			continue
+		}
+
+		p := DefaultPreferencesFor(setting.category)
+		p.Push = false
+
+		if t.Preferences == nil {
+			t.Preferences = make(Preferences)
+		}
+
+		t.Preferences[setting.category] = p
+	}
+}
@@ -0,0 +1,69 @@
+package notifications
+
+import (
+	"time"
+
+	// quiet hours depend on the IANA timezone database, which may not be installed where we run.
+	_ "time/tzdata"
+)
+
+const minutesPerDay = 24 * 60
+
+// QuietHours is a daily window in the targets timezone during which notifications are held back.
+type QuietHours struct {
+	Enabled bool `json:"enabled"`
+
+	// Start and End are minutes after midnight, the window wraps around midnight when Start is after End.
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// IsValid returns whether the window is within a day.
+func (q QuietHours) IsValid() bool {
+	return q.Start >= 0 && q.Start < minutesPerDay && q.End >= 0 && q.End < minutesPerDay
+}
+
+// IsValidTimezone returns whether tz is a known IANA timezone.
+func IsValidTimezone(tz string) bool {
+	if tz == "" || tz == "Local" {
+		return false
+	}
+
+	_, err := time.LoadLocation(tz)
+	return err == nil
+}
+
+// QuietHoursEnd returns when the quiet hours the target is currently in end.
+// False is returned if the target is not in quiet hours at the given time.
+func (t Target) QuietHoursEnd(now time.Time) (time.Time, bool) {
+	q := t.QuietHours
+	if !q.Enabled || q.Start == q.End {
+		return time.Time{}, false
+	}
+
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Day()
+
+	if q.Start < q.End {
+		if minute < q.Start || minute >= q.End {
+			return time.Time{}, false
+		}
+	} else {
+		if minute < q.Start && minute >= q.End {
+			return time.Time{}, false
+		}
+
+		// the window ends tomorrow.
+		if minute >= q.Start {
+			day++
+		}
+	}
+
+	return time.Date(local.Year(), local.Month(), day, q.End/60, q.End%60, 0, 0, loc), true
+}
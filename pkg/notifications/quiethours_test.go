@@ -0,0 +1,88 @@
+package notifications_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestTarget_QuietHoursEnd(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overnight := notifications.QuietHours{Enabled: true, Start: 22 * 60, End: 7 * 60}
+	daytime := notifications.QuietHours{Enabled: true, Start: 9 * 60, End: 17 * 60}
+
+	var tests = []struct {
+		name     string
+		target   notifications.Target
+		now      time.Time
+		expected time.Time
+		quiet    bool
+	}{
+		{
+			name:   "disabled",
+			target: notifications.Target{Timezone: "UTC", QuietHours: notifications.QuietHours{Start: 22 * 60, End: 7 * 60}},
+			now:    time.Date(2021, 3, 1, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "before midnight",
+			target:   notifications.Target{Timezone: "UTC", QuietHours: overnight},
+			now:      time.Date(2021, 3, 1, 23, 0, 0, 0, time.UTC),
+			expected: time.Date(2021, 3, 2, 7, 0, 0, 0, time.UTC),
+			quiet:    true,
+		},
+		{
+			name:     "after midnight",
+			target:   notifications.Target{Timezone: "UTC", QuietHours: overnight},
+			now:      time.Date(2021, 3, 2, 3, 0, 0, 0, time.UTC),
+			expected: time.Date(2021, 3, 2, 7, 0, 0, 0, time.UTC),
+			quiet:    true,
+		},
+		{
+			name:   "outside overnight window",
+			target: notifications.Target{Timezone: "UTC", QuietHours: overnight},
+			now:    time.Date(2021, 3, 2, 7, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "daytime window",
+			target:   notifications.Target{Timezone: "UTC", QuietHours: daytime},
+			now:      time.Date(2021, 3, 2, 12, 30, 0, 0, time.UTC),
+			expected: time.Date(2021, 3, 2, 17, 0, 0, 0, time.UTC),
+			quiet:    true,
+		},
+		{
+			name:   "outside daytime window",
+			target: notifications.Target{Timezone: "UTC", QuietHours: daytime},
+			now:    time.Date(2021, 3, 2, 8, 59, 0, 0, time.UTC),
+		},
+		{
+			name:     "timezone",
+			target:   notifications.Target{Timezone: "Europe/Berlin", QuietHours: overnight},
+			now:      time.Date(2021, 3, 1, 22, 0, 0, 0, time.UTC),
+			expected: time.Date(2021, 3, 2, 7, 0, 0, 0, berlin),
+			quiet:    true,
+		},
+		{
+			name:   "timezone outside window",
+			target: notifications.Target{Timezone: "Europe/Berlin", QuietHours: overnight},
+			now:    time.Date(2021, 3, 1, 20, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, quiet := tt.target.QuietHoursEnd(tt.now)
+			if quiet != tt.quiet {
+				t.Fatalf("expected quiet %v actual %v", tt.quiet, quiet)
+			}
+
+			if !end.Equal(tt.expected) {
+				t.Fatalf("expected %v actual %v", tt.expected, end)
+			}
+		})
+	}
+}
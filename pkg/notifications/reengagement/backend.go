@@ -0,0 +1,146 @@
+package reengagement
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// InactiveUser is a user that has not been active for a while.
+type InactiveUser struct {
+	ID         int
+	LastActive time.Time
+}
+
+// Member is a user in a room.
+type Member struct {
+	ID   int
+	Name string
+	Room string
+}
+
+// Room is a room someone a user follows is in.
+type Room struct {
+	ID      string
+	Members int
+
+	// Followed is the member the user follows.
+	Followed Member
+}
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+// InactiveUsers returns the users last active between the given amount of days ago, accounts scheduled for deletion are excluded.
+func (b *Backend) InactiveUsers(minDays, maxDays int) ([]InactiveUser, error) {
+	stmt, err := b.db.Prepare("SELECT user_id, last_active FROM user_active_times WHERE last_active < NOW() - make_interval(days => $1) AND last_active >= NOW() - make_interval(days => $2) AND user_id NOT IN (SELECT user_id FROM account_deletions) ORDER BY user_id;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(minDays, maxDays)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]InactiveUser, 0)
+	for rows.Next() {
+		user := InactiveUser{}
+		err := rows.Scan(&user.ID, &user.LastActive)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// LiveFriends returns the users that follow each other with the user and are currently in a room.
+func (b *Backend) LiveFriends(user int) ([]Member, error) {
+	stmt, err := b.db.Prepare(`SELECT users.id, users.display_name, current_rooms.room FROM current_rooms
+		INNER JOIN users ON users.id = current_rooms.user_id
+		WHERE current_rooms.user_id IN (SELECT user_id FROM followers WHERE follower = $1 INTERSECT SELECT follower FROM followers WHERE user_id = $1)
+		ORDER BY current_rooms.room, users.id;`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(user)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]Member, 0)
+	for rows.Next() {
+		member := Member{}
+		err := rows.Scan(&member.ID, &member.Name, &member.Room)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// NewFollowers returns how many users followed the user since the given time, according to their inbox.
+func (b *Backend) NewFollowers(user int, since time.Time) (int, error) {
+	stmt, err := b.db.Prepare(`SELECT COALESCE(SUM(CASE WHEN actors IS NULL THEN 1 ELSE jsonb_array_length(actors) END), 0) FROM notifications
+		WHERE user_id = $1 AND category = ANY($2) AND created > $3;`)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = stmt.QueryRow(user, pq.Array([]string{"NEW_FOLLOWER", "NEW_FOLLOWER_DIGEST"}), since).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// PopularRooms returns the rooms with at least the given amount of members that someone the user follows is in, largest first.
+func (b *Backend) PopularRooms(user, members int) ([]Room, error) {
+	stmt, err := b.db.Prepare(`SELECT current_rooms.room, COUNT(*), users.id, users.display_name FROM current_rooms
+		INNER JOIN (
+			SELECT DISTINCT ON (current_rooms.room) current_rooms.room, current_rooms.user_id FROM current_rooms
+			WHERE current_rooms.user_id IN (SELECT user_id FROM followers WHERE follower = $1)
+			ORDER BY current_rooms.room, current_rooms.user_id
+		) followed ON followed.room = current_rooms.room
+		INNER JOIN users ON users.id = followed.user_id
+		GROUP BY current_rooms.room, users.id, users.display_name
+		HAVING COUNT(*) >= $2
+		ORDER BY COUNT(*) DESC;`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(user, members)
+	if err != nil {
+		return nil, err
+	}
+
+	rooms := make([]Room, 0)
+	for rows.Next() {
+		room := Room{}
+		err := rows.Scan(&room.ID, &room.Members, &room.Followed.ID, &room.Followed.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		room.Followed.Room = room.ID
+		rooms = append(rooms, room)
+	}
+
+	return rooms, nil
+}
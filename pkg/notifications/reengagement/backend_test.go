@@ -0,0 +1,114 @@
+package reengagement_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications/reengagement"
+)
+
+func TestBackend_InactiveUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := reengagement.NewBackend(db)
+
+	now := time.Now()
+	mock.
+		ExpectPrepare("SELECT (.+) account_deletions").
+		ExpectQuery().
+		WithArgs(7, 60).
+		WillReturnRows(mock.NewRows([]string{"user_id", "last_active"}).AddRow(1, now).AddRow(2, now))
+
+	users, err := backend.InactiveUsers(7, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []reengagement.InactiveUser{{ID: 1, LastActive: now}, {ID: 2, LastActive: now}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Fatalf("expected %v actual %v", expected, users)
+	}
+}
+
+func TestBackend_LiveFriends(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := reengagement.NewBackend(db)
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(mock.NewRows([]string{"id", "display_name", "room"}).FromCSVString("2,foo,abc\n3,bar,abc"))
+
+	members, err := backend.LiveFriends(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []reengagement.Member{{ID: 2, Name: "foo", Room: "abc"}, {ID: 3, Name: "bar", Room: "abc"}}
+	if !reflect.DeepEqual(members, expected) {
+		t.Fatalf("expected %v actual %v", expected, members)
+	}
+}
+
+func TestBackend_NewFollowers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := reengagement.NewBackend(db)
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("4"))
+
+	count, err := backend.NewFollowers(1, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 4 {
+		t.Fatalf("expected 4 actual %d", count)
+	}
+}
+
+func TestBackend_PopularRooms(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := reengagement.NewBackend(db)
+
+	mock.
+		ExpectPrepare("SELECT").
+		ExpectQuery().
+		WithArgs(1, 5).
+		WillReturnRows(mock.NewRows([]string{"room", "count", "id", "display_name"}).FromCSVString("abc,12,2,foo"))
+
+	rooms, err := backend.PopularRooms(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []reengagement.Room{{ID: "abc", Members: 12, Followed: reengagement.Member{ID: 2, Name: "foo", Room: "abc"}}}
+	if !reflect.DeepEqual(rooms, expected) {
+		t.Fatalf("expected %v actual %v", expected, rooms)
+	}
+}
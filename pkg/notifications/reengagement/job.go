@@ -0,0 +1,193 @@
+package reengagement
+
+import (
+	"context"
+	"log"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
+)
+
+// Reason is why an inactive user is asked to come back, it is reported as the handler of the notification.
+type Reason string
+
+const (
+	ReasonFriendsLive  Reason = "ReEngagementFriendsLive"
+	ReasonNewFollowers Reason = "ReEngagementNewFollowers"
+	ReasonPopularRoom  Reason = "ReEngagementPopularRoom"
+)
+
+// popularRoomMembers is the amount of members a room needs to be popular.
+const popularRoomMembers = 5
+
+// batchSize is the amount of users whose settings are loaded at once.
+const batchSize = 500
+
+// Dispatcher queues notifications for delivery.
+type Dispatcher interface {
+	Dispatch(origin int, targets []notifications.Target, notification *notifications.PushNotification)
+}
+
+// Job sends inactive users a notification with a personalised reason to come back.
+// The worker limits how often a user is re-engaged, so the job can run as often as needed.
+type Job struct {
+	backend    *Backend
+	settings   *notifications.Settings
+	metadata   pb.RoomServiceClient
+	dispatcher Dispatcher
+}
+
+func NewJob(backend *Backend, settings *notifications.Settings, metadata pb.RoomServiceClient, dispatcher Dispatcher) *Job {
+	return &Job{
+		backend:    backend,
+		settings:   settings,
+		metadata:   metadata,
+		dispatcher: dispatcher,
+	}
+}
+
+// Run notifies users inactive for at least minDays and at most maxDays, it returns the amount of users notified.
+func (j *Job) Run(minDays, maxDays int) (int, error) {
+	users, err := j.backend.InactiveUsers(minDays, maxDays)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for start := 0; start < len(users); start += batchSize {
+		end := start + batchSize
+		if end > len(users) {
+			end = len(users)
+		}
+
+		sent += j.send(users[start:end])
+	}
+
+	return sent, nil
+}
+
+func (j *Job) send(users []InactiveUser) int {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, int64(user.ID))
+	}
+
+	targets, err := j.settings.GetSettingsForUsers(ids)
+	if err != nil {
+		log.Printf("settings.GetSettingsForUsers err: %v\n", err)
+		return 0
+	}
+
+	byID := make(map[int]notifications.Target)
+	for _, target := range targets {
+		byID[target.ID] = target
+	}
+
+	sent := 0
+	for _, user := range users {
+		target, ok := byID[user.ID]
+		if !ok || !target.AllowsAny(notifications.REENGAGEMENT) {
+			continue
+		}
+
+		notification, origin, err := j.Notification(user)
+		if err != nil {
+			log.Printf("failed to build re-engagement for %d err: %v\n", user.ID, err)
+			continue
+		}
+
+		if notification == nil {
+			continue
+		}
+
+		j.dispatcher.Dispatch(origin, []notifications.Target{target}, notification)
+		sent++
+	}
+
+	return sent
+}
+
+// Notification returns the notification for the most relevant reason for the user to come back and the user it is about.
+// Friends that are live are preferred over new followers, which are preferred over popular rooms.
+// It returns nil when there is no reason.
+func (j *Job) Notification(user InactiveUser) (*notifications.PushNotification, int, error) {
+	friends, err := j.backend.LiveFriends(user.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if room, members := j.firstPublicRoom(friends); len(members) > 0 {
+		names := make([]string, 0, len(members))
+		for _, member := range members {
+			names = append(names, member.Name)
+		}
+
+		return withReason(notifications.NewReEngagementFriendsLiveNotification(room, names), ReasonFriendsLive), members[0].ID, nil
+	}
+
+	followers, err := j.backend.NewFollowers(user.ID, user.LastActive)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if followers > 0 {
+		return withReason(notifications.NewReEngagementNewFollowersNotification(followers), ReasonNewFollowers), 0, nil
+	}
+
+	rooms, err := j.backend.PopularRooms(user.ID, popularRoomMembers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, room := range rooms {
+		if !j.isPublic(room.ID) {
+			continue
+		}
+
+		n := notifications.NewReEngagementPopularRoomNotification(room.ID, room.Followed.Name, room.Members)
+		return withReason(n, ReasonPopularRoom), room.Followed.ID, nil
+	}
+
+	return nil, 0, nil
+}
+
+// firstPublicRoom returns the first public room members are in and the members in it, members are ordered by room.
+func (j *Job) firstPublicRoom(members []Member) (string, []Member) {
+	checked := make(map[string]bool)
+
+	for _, member := range members {
+		if _, ok := checked[member.Room]; ok {
+			continue
+		}
+
+		checked[member.Room] = true
+		if !j.isPublic(member.Room) {
+			continue
+		}
+
+		inRoom := make([]Member, 0)
+		for _, m := range members {
+			if m.Room == member.Room {
+				inRoom = append(inRoom, m)
+			}
+		}
+
+		return member.Room, inRoom
+	}
+
+	return "", nil
+}
+
+func (j *Job) isPublic(room string) bool {
+	response, err := j.metadata.GetRoom(context.Background(), &pb.GetRoomRequest{Id: room})
+	if err != nil || response == nil || response.State == nil {
+		return false
+	}
+
+	return response.State.Visibility == pb.Visibility_VISIBILITY_PUBLIC
+}
+
+func withReason(notification *notifications.PushNotification, reason Reason) *notifications.PushNotification {
+	notification.Handler = string(reason)
+	return notification
+}
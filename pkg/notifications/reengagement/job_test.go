@@ -0,0 +1,201 @@
+package reengagement_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang/mock/gomock"
+
+	"github.com/soapboxsocial/soapbox/mocks"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/reengagement"
+	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
+)
+
+type dispatched struct {
+	origin       int
+	targets      []notifications.Target
+	notification *notifications.PushNotification
+}
+
+type dispatcher struct {
+	calls []dispatched
+}
+
+func (d *dispatcher) Dispatch(origin int, targets []notifications.Target, notification *notifications.PushNotification) {
+	d.calls = append(d.calls, dispatched{origin: origin, targets: targets, notification: notification})
+}
+
+func TestJob_Notification(t *testing.T) {
+	public := &pb.GetRoomResponse{State: &pb.RoomState{Id: "abc", Visibility: pb.Visibility_VISIBILITY_PUBLIC}}
+	private := &pb.GetRoomResponse{State: &pb.RoomState{Id: "abc", Visibility: pb.Visibility_VISIBILITY_PRIVATE}}
+
+	tests := []struct {
+		name     string
+		setup    func(mock sqlmock.Sqlmock, m *mocks.MockRoomServiceClient)
+		origin   int
+		expected *notifications.PushNotification
+	}{
+		{
+			name: "friends live",
+			setup: func(mock sqlmock.Sqlmock, m *mocks.MockRoomServiceClient) {
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"id", "display_name", "room"}).FromCSVString("2,foo,abc\n3,bar,abc"))
+
+				m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(public, nil)
+			},
+			origin: 2,
+			expected: &notifications.PushNotification{
+				Category:  notifications.REENGAGEMENT,
+				Alert:     notifications.Alert{Key: "reengagement_friends_live_notification", Arguments: []string{"foo", "1"}},
+				Arguments: map[string]interface{}{"id": "abc"},
+				Handler:   string(reengagement.ReasonFriendsLive),
+			},
+		},
+		{
+			name: "new followers",
+			setup: func(mock sqlmock.Sqlmock, m *mocks.MockRoomServiceClient) {
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"id", "display_name", "room"}).FromCSVString("2,foo,abc"))
+
+				m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(private, nil)
+
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("3"))
+			},
+			origin: 0,
+			expected: &notifications.PushNotification{
+				Category:  notifications.REENGAGEMENT,
+				Alert:     notifications.Alert{Key: "reengagement_new_followers_notification", Arguments: []string{"3"}},
+				Arguments: map[string]interface{}{},
+				Handler:   string(reengagement.ReasonNewFollowers),
+			},
+		},
+		{
+			name: "popular room",
+			setup: func(mock sqlmock.Sqlmock, m *mocks.MockRoomServiceClient) {
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"id", "display_name", "room"}))
+
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("0"))
+
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"room", "count", "id", "display_name"}).FromCSVString("abc,12,2,foo"))
+
+				m.EXPECT().GetRoom(gomock.Any(), gomock.Any(), gomock.Any()).Return(public, nil)
+			},
+			origin: 2,
+			expected: &notifications.PushNotification{
+				Category:  notifications.REENGAGEMENT,
+				Alert:     notifications.Alert{Key: "reengagement_popular_room_notification", Arguments: []string{"foo", "11"}},
+				Arguments: map[string]interface{}{"id": "abc"},
+				Handler:   string(reengagement.ReasonPopularRoom),
+			},
+		},
+		{
+			name: "no reason",
+			setup: func(mock sqlmock.Sqlmock, m *mocks.MockRoomServiceClient) {
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"id", "display_name", "room"}))
+
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("0"))
+
+				mock.ExpectPrepare("SELECT").ExpectQuery().
+					WillReturnRows(mock.NewRows([]string{"room", "count", "id", "display_name"}))
+			},
+			origin:   0,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			m := mocks.NewMockRoomServiceClient(ctrl)
+			tt.setup(mock, m)
+
+			job := reengagement.NewJob(reengagement.NewBackend(db), notifications.NewSettings(db), m, &dispatcher{})
+
+			n, origin, err := job.Notification(reengagement.InactiveUser{ID: 1, LastActive: time.Now()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if origin != tt.origin {
+				t.Fatalf("expected origin %d actual %d", tt.origin, origin)
+			}
+
+			if !reflect.DeepEqual(n, tt.expected) {
+				t.Fatalf("expected %v actual %v", tt.expected, n)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestJob_Run(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	m := mocks.NewMockRoomServiceClient(ctrl)
+	d := &dispatcher{}
+
+	job := reengagement.NewJob(reengagement.NewBackend(db), notifications.NewSettings(db), m, d)
+
+	now := time.Now()
+	mock.ExpectPrepare("SELECT").ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "last_active"}).AddRow(1, now).AddRow(2, now))
+
+	// user 2 turned off re-engagement notifications.
+	mock.ExpectPrepare("SELECT").ExpectQuery().
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				AddRow(1, 2, true, true, "UTC", false, 1320, 420, "en", nil, nil).
+				AddRow(2, 2, true, true, "UTC", false, 1320, 420, "en", `{"REENGAGEMENT": {"push": false, "inbox": false, "email": false}}`, nil),
+		)
+
+	mock.ExpectPrepare("SELECT").ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"id", "display_name", "room"}))
+
+	mock.ExpectPrepare("SELECT").ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"count"}).FromCSVString("2"))
+
+	sent, err := job.Run(7, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent != 1 {
+		t.Fatalf("expected 1 sent actual %d", sent)
+	}
+
+	if len(d.calls) != 1 || d.calls[0].targets[0].ID != 1 {
+		t.Fatalf("unexpected dispatches %v", d.calls)
+	}
+
+	if d.calls[0].notification.Handler != string(reengagement.ReasonNewFollowers) {
+		t.Fatalf("unexpected handler %s", d.calls[0].notification.Handler)
+	}
+}
@@ -1,11 +1,25 @@
 package notifications
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
+
+	"github.com/lib/pq"
+
+	"github.com/soapboxsocial/soapbox/pkg/roles"
 )
 
+// settingsColumns are the notification_settings columns scanned into a Target, preferences are aggregated into a JSON object and roles into an array.
+const settingsColumns = "notification_settings.user_id, notification_settings.room_frequency, notification_settings.follows, notification_settings.welcome_rooms, notification_settings.timezone, notification_settings.quiet_hours, notification_settings.quiet_hours_start, notification_settings.quiet_hours_end, notification_settings.locale, " +
+	"(SELECT json_object_agg(category, json_build_object('push', push, 'inbox', inbox, 'email', email)) FROM notification_preferences WHERE notification_preferences.user_id = notification_settings.user_id), " +
+	"(SELECT json_agg(role) FROM user_roles WHERE user_roles.user_id = notification_settings.user_id)"
+
+// notScheduledForDeletion excludes users whose account is scheduled for deletion, they are not notified until they restore it.
+const notScheduledForDeletion = "notification_settings.user_id NOT IN (SELECT user_id FROM account_deletions)"
+
 type Settings struct {
 	db *sql.DB
 }
@@ -15,7 +29,7 @@ func NewSettings(db *sql.DB) *Settings {
 }
 
 func (s *Settings) GetSettingsFor(user int) (*Target, error) {
-	stmt, err := s.db.Prepare("SELECT user_id, room_frequency, follows, welcome_rooms FROM notification_settings WHERE user_id = $1;")
+	stmt, err := s.db.Prepare("SELECT " + settingsColumns + " FROM notification_settings WHERE user_id = $1 AND " + notScheduledForDeletion + ";")
 	if err != nil {
 		return nil, err
 	}
@@ -23,7 +37,7 @@ func (s *Settings) GetSettingsFor(user int) (*Target, error) {
 	row := stmt.QueryRow(user)
 
 	target := &Target{}
-	err = row.Scan(&target.ID, &target.RoomFrequency, &target.Follows, &target.WelcomeRooms)
+	err = scanTarget(row, target)
 	if err != nil {
 		return nil, err
 	}
@@ -33,15 +47,73 @@ func (s *Settings) GetSettingsFor(user int) (*Target, error) {
 
 func (s *Settings) GetSettingsFollowingUser(user int) ([]Target, error) {
 	return s.getSettings(
-		"SELECT notification_settings.user_id, notification_settings.room_frequency, notification_settings.follows, notification_settings.welcome_rooms FROM notification_settings INNER JOIN followers ON (notification_settings.user_id = followers.follower) WHERE followers.user_id = $1",
+		"SELECT "+settingsColumns+" FROM notification_settings INNER JOIN followers ON (notification_settings.user_id = followers.follower) WHERE followers.user_id = $1 AND "+notScheduledForDeletion,
 		user,
 	)
 }
 
+// GetSettingsForRoomAlerts returns the settings of the users alerted of rooms by a creator.
+// These are followers that have not muted the creator, and users subscribed to live alerts who are marked as subscribed.
+// Users that blocked the creator, or were blocked by them, are never alerted.
+func (s *Settings) GetSettingsForRoomAlerts(creator int) ([]Target, error) {
+	stmt, err := s.db.Prepare(
+		`SELECT ` + settingsColumns + `, COALESCE(creator_subscriptions.level = 'live', false) FROM notification_settings
+		LEFT JOIN creator_subscriptions ON creator_subscriptions.user_id = notification_settings.user_id AND creator_subscriptions.creator = $1
+		WHERE ((creator_subscriptions.level = 'live')
+		OR (creator_subscriptions.level IS NULL AND notification_settings.user_id IN (SELECT follower FROM followers WHERE user_id = $1)))
+		AND NOT EXISTS (
+			SELECT 1 FROM blocks WHERE (blocks.user_id = notification_settings.user_id AND blocks.blocked = $1) OR (blocks.user_id = $1 AND blocks.blocked = notification_settings.user_id)
+		)
+		AND ` + notScheduledForDeletion,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(creator)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0)
+	for rows.Next() {
+		target := Target{}
+		err = scanTarget(rows, &target, &target.Subscribed)
+		if err != nil {
+			continue
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// GetSettingsForTrendingRoom returns the settings of the users following any of the members of a room, who did not mute them.
+// Members and the followers of the user that joined, who are alerted of the join, are excluded.
+func (s *Settings) GetSettingsForTrendingRoom(members []int64, joined int) ([]Target, error) {
+	return s.getSettings(
+		`SELECT `+settingsColumns+` FROM notification_settings
+		WHERE notification_settings.user_id IN (
+			SELECT followers.follower FROM followers WHERE followers.user_id = ANY($1)
+			AND NOT EXISTS (
+				SELECT 1 FROM creator_subscriptions WHERE creator_subscriptions.user_id = followers.follower AND creator_subscriptions.creator = followers.user_id AND creator_subscriptions.level = 'muted'
+			)
+		)
+		AND NOT notification_settings.user_id = ANY($1)
+		AND notification_settings.user_id NOT IN (SELECT follower FROM followers WHERE user_id = $2)
+		AND `+notScheduledForDeletion+`
+		ORDER BY notification_settings.user_id`,
+		pq.Array(members),
+		joined,
+	)
+}
+
 // @TODO THIS NEEDS FIXING
 func (s *Settings) GetSettingsForRecentlyActiveUsers() ([]Target, error) {
 	return s.getSettings(
-		`SELECT notification_settings.user_id, notification_settings.room_frequency, notification_settings.follows, notification_settings.welcome_rooms FROM notification_settings
+		`SELECT `+settingsColumns+` FROM notification_settings
 		INNER JOIN (
 			SELECT user_id
 		    FROM (
@@ -50,26 +122,105 @@ func (s *Settings) GetSettingsForRecentlyActiveUsers() ([]Target, error) {
 		        SELECT user_id FROM user_active_times WHERE last_active > (NOW() - INTERVAL '15 MINUTE')
 			) foo GROUP BY user_id) active
 		ON notification_settings.user_id = active.user_id
-		INNER JOIN user_room_time ON user_room_time.user_id = active.user_id WHERE seconds >= 36000 AND visibility = 'public' AND active.user_id NOT IN (1, 75, 962);`,
+		INNER JOIN user_room_time ON user_room_time.user_id = active.user_id WHERE seconds >= 36000 AND visibility = 'public'
+		AND active.user_id NOT IN (SELECT user_id FROM user_roles WHERE role = ANY($1))
+		AND `+notScheduledForDeletion+`;`,
+		pq.Array(roles.Names(roles.WithPermission(roles.PermissionGreet))),
+	)
+}
+
+// GetSettingsForPermission returns the settings of all users with a role granting the permission.
+func (s *Settings) GetSettingsForPermission(permission roles.Permission) ([]Target, error) {
+	return s.getSettings(
+		"SELECT "+settingsColumns+" FROM notification_settings WHERE user_id IN (SELECT user_id FROM user_roles WHERE role = ANY($1)) AND "+notScheduledForDeletion+" ORDER BY user_id",
+		pq.Array(roles.Names(roles.WithPermission(permission))),
 	)
 }
 
 func (s *Settings) GetSettingsForUsers(users []int64) ([]Target, error) {
 	query := fmt.Sprintf(
-		"SELECT notification_settings.user_id, notification_settings.room_frequency, notification_settings.follows, notification_settings.welcome_rooms FROM notification_settings WHERE user_id IN (%s)",
+		"SELECT %s FROM notification_settings WHERE user_id IN (%s) AND %s",
+		settingsColumns,
 		join(users, ","),
+		notScheduledForDeletion,
 	)
 
 	return s.getSettings(query)
 }
 
+// UpdateSettingsFor updates the settings that predate preferences, the push preferences of the categories they cover are kept in sync.
 func (s *Settings) UpdateSettingsFor(user int, frequency Frequency, follows, welcomeRooms bool) error {
-	stmt, err := s.db.Prepare("UPDATE notification_settings SET room_frequency = $1, follows = $2, welcome_rooms = $3 WHERE user_id = $4;")
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	stmt, err := tx.Prepare("UPDATE notification_settings SET room_frequency = $1, follows = $2, welcome_rooms = $3 WHERE user_id = $4;")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
 	_, err = stmt.Exec(frequency, follows, welcomeRooms, user)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	push := map[NotificationCategory]bool{
+		NEW_ROOM:     frequency != FrequencyOff,
+		ROOM_JOINED:  frequency != FrequencyOff,
+		NEW_FOLLOWER: follows,
+		WELCOME_ROOM: welcomeRooms,
+	}
+
+	stmt, err = tx.Prepare("INSERT INTO notification_preferences (user_id, category, push, inbox, email) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id, category) DO UPDATE SET push = $3;")
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	for _, category := range []NotificationCategory{NEW_ROOM, ROOM_JOINED, NEW_FOLLOWER, WELCOME_ROOM} {
+		defaults := DefaultPreferencesFor(category)
+
+		_, err = stmt.Exec(user, category, push[category], defaults.Inbox, defaults.Email)
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Settings) UpdatePreferencesFor(user int, category NotificationCategory, preferences ChannelPreferences) error {
+	stmt, err := s.db.Prepare("INSERT INTO notification_preferences (user_id, category, push, inbox, email) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id, category) DO UPDATE SET push = $3, inbox = $4, email = $5;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user, category, preferences.Push, preferences.Inbox, preferences.Email)
+	return err
+}
+
+func (s *Settings) UpdateQuietHoursFor(user int, timezone string, quietHours QuietHours) error {
+	stmt, err := s.db.Prepare("UPDATE notification_settings SET timezone = $1, quiet_hours = $2, quiet_hours_start = $3, quiet_hours_end = $4 WHERE user_id = $5;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(timezone, quietHours.Enabled, quietHours.Start, quietHours.End, user)
+	return err
+}
+
+func (s *Settings) UpdateLocaleFor(user int, locale string) error {
+	stmt, err := s.db.Prepare("UPDATE notification_settings SET locale = $1 WHERE user_id = $2;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(locale, user)
 	return err
 }
 
@@ -104,7 +255,7 @@ func (s *Settings) getSettings(query string, args ...interface{}) ([]Target, err
 	targets := make([]Target, 0)
 	for rows.Next() {
 		target := Target{}
-		err = rows.Scan(&target.ID, &target.RoomFrequency, &target.Follows, &target.WelcomeRooms)
+		err = scanTarget(rows, &target)
 		if err != nil {
 			continue
 		}
@@ -114,3 +265,45 @@ func (s *Settings) getSettings(query string, args ...interface{}) ([]Target, err
 
 	return targets, nil
 }
+
+// scanTarget scans the settingsColumns into the target, followed by any extra columns a query selects.
+func scanTarget(row interface{ Scan(...interface{}) error }, target *Target, extra ...interface{}) error {
+	var preferences, targetRoles []byte
+
+	dest := []interface{}{
+		&target.ID,
+		&target.RoomFrequency,
+		&target.Follows,
+		&target.WelcomeRooms,
+		&target.Timezone,
+		&target.QuietHours.Enabled,
+		&target.QuietHours.Start,
+		&target.QuietHours.End,
+		&target.Locale,
+		&preferences,
+		&targetRoles,
+	}
+
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+
+	if len(preferences) > 0 {
+		err = json.Unmarshal(preferences, &target.Preferences)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(targetRoles) > 0 {
+		err = json.Unmarshal(targetRoles, &target.Roles)
+		if err != nil {
+			return err
+		}
+	}
+
+	target.applyLegacySettings()
+
+	return nil
+}
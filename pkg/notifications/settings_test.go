@@ -0,0 +1,123 @@
+package notifications_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestSettings_GetSettingsFor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	settings := notifications.NewSettings(db)
+
+	mock.
+		ExpectPrepare("^SELECT (.+) account_deletions").
+		ExpectQuery().
+		WithArgs(1).
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				AddRow(1, 0, false, true, "UTC", false, 1320, 420, "en", `{"NEW_FOLLOWER": {"push": true, "inbox": false, "email": true}, "ACCOUNT_SECURITY": {}}`, nil),
+		)
+
+	target, err := settings.GetSettingsFor(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// explicit preferences take precedence over the legacy settings.
+	expected := notifications.Preferences{
+		notifications.NEW_FOLLOWER:     {Push: true, Email: true},
+		notifications.NEW_ROOM:         {Inbox: true},
+		notifications.ROOM_JOINED:      {Inbox: true},
+		notifications.ACCOUNT_SECURITY: {},
+	}
+
+	if !reflect.DeepEqual(target.Preferences, expected) {
+		t.Fatalf("expected %v actual %v", expected, target.Preferences)
+	}
+
+	if target.Allows(notifications.NEW_ROOM, notifications.ChannelPush) {
+		t.Fatal("expected room push to be disabled")
+	}
+
+	if !target.Allows(notifications.NEW_FOLLOWER_DIGEST, notifications.ChannelEmail) {
+		t.Fatal("expected digest to share follower preferences")
+	}
+
+	if !target.Allows(notifications.ROOM_INVITE, notifications.ChannelPush) {
+		t.Fatal("expected defaults for categories without preferences")
+	}
+
+	if !target.Allows(notifications.ACCOUNT_SECURITY, notifications.ChannelEmail) {
+		t.Fatal("expected security notifications to ignore preferences")
+	}
+}
+
+func TestSettings_GetSettingsForRoomAlerts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	settings := notifications.NewSettings(db)
+
+	mock.
+		ExpectPrepare("^SELECT (.+) LEFT JOIN creator_subscriptions (.+) NOT EXISTS (.+) blocks (.+)").
+		ExpectQuery().
+		WithArgs(12).
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles", "subscribed"}).
+				AddRow(1, 0, true, true, "UTC", false, 1320, 420, "en", nil, nil, true).
+				AddRow(2, 2, true, true, "UTC", false, 1320, 420, "en", nil, nil, false),
+		)
+
+	targets, err := settings.GetSettingsForRoomAlerts(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("expected 2 targets actual %d", len(targets))
+	}
+
+	if !targets[0].Subscribed || targets[1].Subscribed {
+		t.Fatalf("unexpected subscriptions %v", targets)
+	}
+}
+
+func TestSettings_GetSettingsForTrendingRoom(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	settings := notifications.NewSettings(db)
+
+	mock.
+		ExpectPrepare("^SELECT (.+) FROM followers (.+)").
+		ExpectQuery().
+		WithArgs(sqlmock.AnyArg(), 12).
+		WillReturnRows(
+			mock.NewRows([]string{"user_id", "room_frequency", "follows", "welcome_rooms", "timezone", "quiet_hours", "quiet_hours_start", "quiet_hours_end", "locale", "preferences", "roles"}).
+				AddRow(1, 2, true, true, "UTC", false, 1320, 420, "en", nil, nil),
+		)
+
+	targets, err := settings.GetSettingsForTrendingRoom([]int64{12, 13}, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(targets) != 1 || targets[0].ID != 1 {
+		t.Fatalf("unexpected targets %v", targets)
+	}
+}
@@ -1,55 +1,88 @@
 package notifications
 
 import (
+	"database/sql"
 	"encoding/json"
-	"fmt"
-	"log"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/lib/pq"
 )
 
-const placeholder = "val"
-
+// Storage is the notification inbox of users.
 type Storage struct {
-	rdb *redis.Client
+	db *sql.DB
 }
 
-func NewStorage(rdb *redis.Client) *Storage {
+func NewStorage(db *sql.DB) *Storage {
 	return &Storage{
-		rdb: rdb,
+		db: db,
 	}
 }
 
 func (s *Storage) Store(user int, notification *Notification) error {
-	data, err := json.Marshal(notification)
+	alert, err := json.Marshal(notification.Alert)
+	if err != nil {
+		return err
+	}
+
+	arguments, err := json.Marshal(notification.Arguments)
 	if err != nil {
 		return err
 	}
 
-	key := notificationListKey(user)
-	err = s.rdb.LPush(s.rdb.Context(), key, string(data)).Err()
+	actors, err := json.Marshal(notification.Actors)
 	if err != nil {
 		return err
 	}
 
-	s.setHasNewNotifications(user)
+	from := sql.NullInt64{Int64: int64(notification.From), Valid: notification.From != 0}
 
-	return s.rdb.LTrim(s.rdb.Context(), key, 0, 39).Err()
+	stmt, err := s.db.Prepare("INSERT INTO notifications (user_id, from_id, category, alert, arguments, actors, created, read) VALUES ($1, $2, $3, $4, $5, $6, to_timestamp($7), $8);")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user, from, notification.Category, string(alert), string(arguments), string(actors), notification.Timestamp, notification.Read)
+	return err
 }
 
-func (s *Storage) GetNotifications(user int) ([]*Notification, error) {
-	data, err := s.rdb.LRange(s.rdb.Context(), notificationListKey(user), 0, -1).Result()
+// GetNotifications returns up to limit notifications older than the cursor, newest first.
+// The cursor is the ID of the last notification on the previous page, or 0 for the first page.
+func (s *Storage) GetNotifications(user, cursor, limit int) ([]*Notification, error) {
+	stmt, err := s.db.Prepare("SELECT id, COALESCE(from_id, 0), category, alert, arguments, actors, extract(epoch from created)::bigint, read FROM notifications WHERE user_id = $1 AND ($2 = 0 OR id < $2) ORDER BY id DESC LIMIT $3;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(user, cursor, limit)
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	notifications := make([]*Notification, 0)
-	for _, item := range data {
+	for rows.Next() {
 		n := &Notification{}
-		err := json.Unmarshal([]byte(item), n)
+
+		var alert, arguments, actors []byte
+		err := rows.Scan(&n.ID, &n.From, &n.Category, &alert, &arguments, &actors, &n.Timestamp, &n.Read)
+		if err != nil {
+			return nil, err
+		}
+
+		err = unmarshal(alert, &n.Alert)
+		if err != nil {
+			return nil, err
+		}
+
+		err = unmarshal(arguments, &n.Arguments)
 		if err != nil {
-			log.Printf("failed to unmarshal notification err: %v\n", err)
-			continue
+			return nil, err
+		}
+
+		err = unmarshal(actors, &n.Actors)
+		if err != nil {
+			return nil, err
 		}
 
 		notifications = append(notifications, n)
@@ -58,27 +91,114 @@ func (s *Storage) GetNotifications(user int) ([]*Notification, error) {
 	return notifications, nil
 }
 
-func (s *Storage) MarkNotificationsViewed(user int) {
-	s.rdb.Del(s.rdb.Context(), hasNewNotificationsKey(user))
+// MarkRead sets the read state of a notification, sql.ErrNoRows is returned if the user has no such notification.
+func (s *Storage) MarkRead(user, id int, read bool) error {
+	stmt, err := s.db.Prepare("UPDATE notifications SET read = $1 WHERE id = $2 AND user_id = $3;")
+	if err != nil {
+		return err
+	}
+
+	return execAffectingRow(stmt, read, id, user)
+}
+
+func (s *Storage) MarkAllRead(user int) error {
+	stmt, err := s.db.Prepare("UPDATE notifications SET read = true WHERE user_id = $1 AND read = false;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user)
+	return err
+}
+
+// Delete removes a notification, sql.ErrNoRows is returned if the user has no such notification.
+func (s *Storage) Delete(user, id int) error {
+	stmt, err := s.db.Prepare("DELETE FROM notifications WHERE id = $1 AND user_id = $2;")
+	if err != nil {
+		return err
+	}
+
+	return execAffectingRow(stmt, id, user)
+}
+
+func (s *Storage) UnreadCount(user int) (int, error) {
+	stmt, err := s.db.Prepare("SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read = false;")
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = stmt.QueryRow(user).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// UnreadCounts returns the unread count of every user with unread notifications.
+func (s *Storage) UnreadCounts(users []int) (map[int]int, error) {
+	stmt, err := s.db.Prepare("SELECT user_id, COUNT(*) FROM notifications WHERE user_id = ANY($1) AND read = false GROUP BY user_id;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(pq.Array(users))
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var user, count int
+		err := rows.Scan(&user, &count)
+		if err != nil {
+			return nil, err
+		}
+
+		counts[user] = count
+	}
+
+	return counts, nil
 }
 
 func (s *Storage) HasNewNotifications(user int) bool {
-	res, err := s.rdb.Get(s.rdb.Context(), hasNewNotificationsKey(user)).Result()
+	stmt, err := s.db.Prepare("SELECT EXISTS (SELECT 1 FROM notifications WHERE user_id = $1 AND read = false);")
 	if err != nil {
 		return false
 	}
 
-	return res == placeholder
-}
+	var has bool
+	err = stmt.QueryRow(user).Scan(&has)
+	if err != nil {
+		return false
+	}
 
-func (s *Storage) setHasNewNotifications(user int) {
-	s.rdb.Set(s.rdb.Context(), hasNewNotificationsKey(user), placeholder, 0)
+	return has
 }
 
-func hasNewNotificationsKey(user int) string {
-	return fmt.Sprintf("has_new_notifications_%d", user)
+func execAffectingRow(stmt *sql.Stmt, args ...interface{}) error {
+	res, err := stmt.Exec(args...)
+	if err != nil {
+		return err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
 }
 
-func notificationListKey(user int) string {
-	return fmt.Sprintf("notifications_%d", user)
+func unmarshal(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
 }
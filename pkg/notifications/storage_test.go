@@ -0,0 +1,105 @@
+package notifications_test
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestStorage_GetNotifications(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	storage := notifications.NewStorage(db)
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WithArgs(1, 10, 20).
+		WillReturnRows(
+			mock.NewRows([]string{"id", "from_id", "category", "alert", "arguments", "actors", "created", "read"}).
+				AddRow(9, 2, "NEW_FOLLOWER_DIGEST", `{"loc-key":"new_followers_digest_two_notification","loc-args":["foo","bar"]}`, `{"id":2}`, `[2,3]`, 100, false).
+				AddRow(8, 0, "INFO", `{"body":"hello","loc-key":"","loc-args":null}`, nil, nil, 50, true),
+		)
+
+	res, err := storage.GetNotifications(1, 10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*notifications.Notification{
+		{
+			ID:        9,
+			Timestamp: 100,
+			From:      2,
+			Category:  notifications.NEW_FOLLOWER_DIGEST,
+			Alert:     notifications.Alert{Key: "new_followers_digest_two_notification", Arguments: []string{"foo", "bar"}},
+			Arguments: map[string]interface{}{"id": float64(2)},
+			Actors:    []int{2, 3},
+		},
+		{
+			ID:        8,
+			Timestamp: 50,
+			Category:  notifications.INFO,
+			Alert:     notifications.Alert{Body: "hello"},
+			Read:      true,
+		},
+	}
+
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %v actual %v", expected, res)
+	}
+}
+
+func TestStorage_MarkReadWithoutNotification(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	storage := notifications.NewStorage(db)
+
+	mock.
+		ExpectPrepare("^UPDATE (.+)").
+		ExpectExec().
+		WithArgs(true, 5, 1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = storage.MarkRead(1, 5, true)
+	if err != sql.ErrNoRows {
+		t.Fatalf("expected %v actual %v", sql.ErrNoRows, err)
+	}
+}
+
+func TestStorage_UnreadCounts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	storage := notifications.NewStorage(db)
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(1, 3).AddRow(2, 1))
+
+	counts, err := storage.UnreadCounts([]int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int]int{1: 3, 2: 1}
+	if !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("expected %v actual %v", expected, counts)
+	}
+}
@@ -0,0 +1,45 @@
+package templates
+
+import "strings"
+
+// plural forms as defined by CLDR, we only implement the rules for the languages we ship.
+const (
+	one   = "one"
+	few   = "few"
+	many  = "many"
+	other = "other"
+)
+
+// pluralForm returns the plural form of n for a locale.
+func pluralForm(locale string, n int) string {
+	language := locale
+	if i := strings.Index(locale, "-"); i > 0 {
+		language = locale[:i]
+	}
+
+	switch language {
+	case "fr", "pt":
+		if n == 0 || n == 1 {
+			return one
+		}
+
+		return other
+	case "ru", "uk":
+		switch {
+		case n%10 == 1 && n%100 != 11:
+			return one
+		case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+			return few
+		default:
+			return many
+		}
+	case "ja", "ko", "zh":
+		return other
+	default:
+		if n == 1 {
+			return one
+		}
+
+		return other
+	}
+}
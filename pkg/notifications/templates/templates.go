@@ -0,0 +1,209 @@
+// Package templates renders notification alerts to plain text for channels that cannot localize on the device.
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// DefaultLocale is used when a template does not exist for the requested locale.
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// ErrTemplateNotFound is returned when no locale has a template for a key.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// Template is a localized text, it either has a single text or plural forms selected by one of the arguments.
+//
+// Arguments are referenced by their index, for example `{0} followed you`.
+type Template struct {
+	Text string
+
+	// Count is the index of the argument selecting the plural form.
+	Count int
+	Forms map[string]string
+}
+
+func (t *Template) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &t.Text)
+	}
+
+	raw := make(map[string]json.RawMessage)
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	t.Forms = make(map[string]string)
+	for form, value := range raw {
+		if form == "count" {
+			err = json.Unmarshal(value, &t.Count)
+		} else {
+			var text string
+			err = json.Unmarshal(value, &text)
+			t.Forms[form] = text
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := t.Forms[other]; !ok {
+		return errors.New("plural template is missing the other form")
+	}
+
+	return nil
+}
+
+// Registry holds the templates of all locales.
+type Registry struct {
+	locales map[string]map[string]Template
+}
+
+// NewRegistry creates a registry with the locales shipped with the server.
+func NewRegistry() (*Registry, error) {
+	r := &Registry{locales: make(map[string]map[string]Template)}
+
+	err := r.LoadFS(locales, "locales")
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// LoadFS loads every `<locale>.json` file in a directory.
+func (r *Registry) LoadFS(fsys fs.FS, dir string) error {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != ".json" {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+
+		err = r.Load(strings.TrimSuffix(file.Name(), ".json"), data)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", file.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// Load adds the templates of a locale, replacing existing ones with the same key.
+func (r *Registry) Load(locale string, data []byte) error {
+	templates := make(map[string]Template)
+	err := json.Unmarshal(data, &templates)
+	if err != nil {
+		return err
+	}
+
+	locale = normalize(locale)
+	if r.locales[locale] == nil {
+		r.locales[locale] = make(map[string]Template)
+	}
+
+	for key, template := range templates {
+		r.locales[locale][key] = template
+	}
+
+	return nil
+}
+
+// Supports returns whether templates exist for a locale or its language.
+func (r *Registry) Supports(locale string) bool {
+	for _, l := range candidates(locale) {
+		if _, ok := r.locales[l]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Render returns the text for a key in the locale, falling back to the language and then the default locale.
+func (r *Registry) Render(locale, key string, args []string) (string, error) {
+	for _, l := range append(candidates(locale), DefaultLocale) {
+		template, ok := r.locales[l][key]
+		if !ok {
+			continue
+		}
+
+		return template.render(l, args), nil
+	}
+
+	return "", ErrTemplateNotFound
+}
+
+// RenderAlert returns the plain text of an alert, alerts without a key are returned as is.
+func (r *Registry) RenderAlert(locale string, alert notifications.Alert) (string, error) {
+	if alert.Key == "" {
+		return alert.Body, nil
+	}
+
+	return r.Render(locale, alert.Key, alert.Arguments)
+}
+
+func (t Template) render(locale string, args []string) string {
+	text := t.Text
+
+	if t.Forms != nil {
+		n := 0
+		if t.Count < len(args) {
+			n, _ = strconv.Atoi(args[t.Count])
+		}
+
+		var ok bool
+		text, ok = t.Forms[pluralForm(locale, n)]
+		if !ok {
+			text = t.Forms[other]
+		}
+	}
+
+	replacements := make([]string, 0, len(args)*2)
+	for i, arg := range args {
+		replacements = append(replacements, "{"+strconv.Itoa(i)+"}", arg)
+	}
+
+	return strings.NewReplacer(replacements...).Replace(text)
+}
+
+// normalize turns locales like `en_US` into `en-us`.
+func normalize(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+// candidates returns the locale followed by its language.
+func candidates(locale string) []string {
+	locale = normalize(locale)
+	if locale == "" {
+		return []string{}
+	}
+
+	res := []string{locale}
+	if i := strings.Index(locale, "-"); i > 0 {
+		res = append(res, locale[:i])
+	}
+
+	return res
+}
@@ -0,0 +1,156 @@
+package templates_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
+)
+
+func TestRegistry_Render(t *testing.T) {
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		locale   string
+		key      string
+		args     []string
+		expected string
+	}{
+		{"en", "new_follower_notification", []string{"foo"}, "foo started following you"},
+		{"de", "new_follower_notification", []string{"foo"}, "foo folgt dir jetzt"},
+		{"de-CH", "new_follower_notification", []string{"foo"}, "foo folgt dir jetzt"},
+		{"de_AT", "new_follower_notification", []string{"foo"}, "foo folgt dir jetzt"},
+		{"xx", "new_follower_notification", []string{"foo"}, "foo started following you"},
+		{"", "new_follower_notification", []string{"foo"}, "foo started following you"},
+		{"en", "new_followers_digest_notification", []string{"foo", "1"}, "foo and 1 other started following you"},
+		{"en", "new_followers_digest_notification", []string{"foo", "5"}, "foo and 5 others started following you"},
+		{"de", "new_followers_digest_notification", []string{"foo", "2"}, "foo und 2 weitere Personen folgen dir jetzt"},
+		{
+			"en",
+			"join_room_name_with_3_and_more_notification",
+			[]string{"room", "a", "b", "c", "1"},
+			"a, b, c and 1 other are talking in \"room\", why not join them?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.locale+"/"+tt.key, func(t *testing.T) {
+			text, err := registry.Render(tt.locale, tt.key, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if text != tt.expected {
+				t.Fatalf("expected %s actual %s", tt.expected, text)
+			}
+		})
+	}
+}
+
+func TestRegistry_RenderWithUnknownKey(t *testing.T) {
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = registry.Render("en", "foo", []string{})
+	if err != templates.ErrTemplateNotFound {
+		t.Fatalf("expected %v actual %v", templates.ErrTemplateNotFound, err)
+	}
+}
+
+func TestRegistry_RenderAlert(t *testing.T) {
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := registry.RenderAlert("en", notifications.Alert{Body: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text != "hello" {
+		t.Fatalf("expected hello actual %s", text)
+	}
+
+	alert := notifications.NewFollowRecommendationsNotification([]string{"a", "b", "c", "d"}, 4).Alert
+
+	text, err = registry.RenderAlert("en", alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "a, b, c and 1 other who you may know are on Soapbox, why not follow them?"
+	if text != expected {
+		t.Fatalf("expected %s actual %s", expected, text)
+	}
+}
+
+func TestRegistry_LoadFS(t *testing.T) {
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"locales/ru.json": {Data: []byte(`{"new_followers_digest_notification": {"count": 1, "one": "{0} и еще {1} человек", "few": "{0} и еще {1} человека", "many": "{0} и еще {1} человек", "other": "{0} и еще {1} человека"}}`)},
+	}
+
+	err = registry.LoadFS(fsys, "locales")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !registry.Supports("ru-RU") {
+		t.Fatal("expected ru to be supported")
+	}
+
+	text, err := registry.Render("ru", "new_followers_digest_notification", []string{"foo", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text != "foo и еще 3 человека" {
+		t.Fatalf("unexpected text %s", text)
+	}
+
+	err = registry.Load("fr", []byte(`{"foo": {"count": 0, "one": "bar"}}`))
+	if err == nil {
+		t.Fatal("expected error for template without other form")
+	}
+}
+
+func TestNewRegistry_LocalesAreComplete(t *testing.T) {
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{
+		"new_room_notification",
+		"new_room_with_name_notification",
+		"room_invite_notification",
+		"room_invite_with_name_notification",
+		"new_follower_notification",
+		"new_followers_digest_two_notification",
+		"new_followers_digest_notification",
+		"welcome_room_notification",
+		"join_room_with_3_and_more_notification",
+		"join_room_name_with_3_and_more_notification",
+		"3_and_more_follow_recommendations_notification",
+	}
+
+	for _, locale := range []string{"en", "de"} {
+		for _, key := range keys {
+			text, err := registry.Render(locale, key, []string{"a", "b", "c", "d", "5"})
+			if err != nil || text == "" {
+				t.Errorf("missing %s for %s", key, locale)
+			}
+		}
+	}
+}
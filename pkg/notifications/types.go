@@ -1,6 +1,11 @@
 package notifications
 
-import "github.com/soapboxsocial/soapbox/pkg/analytics"
+import (
+	"strconv"
+
+	"github.com/soapboxsocial/soapbox/pkg/analytics"
+	"github.com/soapboxsocial/soapbox/pkg/roles"
+)
 
 type NotificationCategory string
 
@@ -14,6 +19,13 @@ const (
 	TEST                   NotificationCategory = "TEST"
 	INFO                   NotificationCategory = "INFO"
 	FOLLOW_RECOMMENDATIONS NotificationCategory = "FOLLOW_RECOMMENDATIONS"
+	NEW_FOLLOWER_DIGEST    NotificationCategory = "NEW_FOLLOWER_DIGEST"
+	ACCOUNT_SECURITY       NotificationCategory = "ACCOUNT_SECURITY"
+	NEW_STORY              NotificationCategory = "NEW_STORY"
+	NEW_STORY_DIGEST       NotificationCategory = "NEW_STORY_DIGEST"
+	STORY_REACTION         NotificationCategory = "STORY_REACTION"
+	FEED_REFRESH           NotificationCategory = "FEED_REFRESH"
+	TRENDING_ROOM          NotificationCategory = "TRENDING_ROOM"
 )
 
 type Frequency int
@@ -31,6 +43,26 @@ type Target struct {
 	RoomFrequency Frequency `json:"room_frequency"`
 	Follows       bool      `json:"follows"`
 	WelcomeRooms  bool      `json:"welcome_rooms"`
+
+	Timezone   string     `json:"timezone"`
+	QuietHours QuietHours `json:"quiet_hours"`
+
+	// Locale is used to render notifications for channels that cannot localize on the device.
+	Locale string `json:"locale"`
+
+	// Preferences only contains the categories that differ from the defaults.
+	Preferences Preferences `json:"preferences,omitempty"`
+
+	Roles []roles.Role `json:"roles,omitempty"`
+
+	// Subscribed is set when the target subscribed to live alerts of the notifications origin.
+	// Room notifications to subscribed targets bypass the room frequency.
+	Subscribed bool `json:"subscribed,omitempty"`
+}
+
+// Can returns whether the roles of the target grant a permission.
+func (t Target) Can(permission roles.Permission) bool {
+	return roles.Can(t.Roles, permission)
 }
 
 type Alert struct {
@@ -46,14 +78,44 @@ type PushNotification struct {
 	Arguments  map[string]interface{} `json:"arguments"`
 	UUID       string                 `json:"uuid"`
 	CollapseID string                 `json:"-"`
+
+	// Campaign is set for notifications sent as part of a campaign, it is used for reporting.
+	Campaign int `json:"-"`
+
+	// Handler is the name of the handler that built the notification, it is used for reporting.
+	Handler string `json:"-"`
+
+	// Variants are alternative alert keys that are tested against each other, see SplitVariants.
+	Variants []Variant `json:"-"`
+
+	// Variant is the name of the variant the notification is sent with.
+	Variant string `json:"-"`
+
+	// Rendered is set when the apps do not ship the alert key, the alert is pushed in the locale of the target instead.
+	Rendered bool `json:"-"`
+
+	// Image is the file name of the profile picture shown with the notification.
+	Image string `json:"-"`
+
+	// Badge is the unread count shown on the app icon, it is set per device when sending.
+	Badge *int `json:"-"`
+
+	// Recipient is the address the notification is emailed to instead of the address of the target.
+	Recipient string `json:"-"`
 }
 
-// Notification is stored in redis for the notification endpoint.
+// Notification is stored in the inbox for the notification endpoint.
 type Notification struct {
+	ID        int                    `json:"id"`
 	Timestamp int64                  `json:"timestamp"`
 	From      int                    `json:"from"`
 	Category  NotificationCategory   `json:"category"`
+	Alert     Alert                  `json:"alert"`
 	Arguments map[string]interface{} `json:"arguments"`
+	Read      bool                   `json:"read"`
+
+	// Actors are the users aggregated into a digest.
+	Actors []int `json:"actors,omitempty"`
 }
 
 func NewRoomNotification(id, creator string, creatorID int) *PushNotification {
@@ -104,9 +166,171 @@ func NewRoomInviteNotificationWithName(id, from, room string) *PushNotification
 	}
 }
 
+// NewStoryNotification is sent to the followers of a user that posted a story.
+func NewStoryNotification(creator int, name string) *PushNotification {
+	return &PushNotification{
+		Category: NEW_STORY,
+		Alert: Alert{
+			Key:       "new_story_notification",
+			Arguments: []string{name},
+		},
+		Arguments: map[string]interface{}{"id": creator},
+	}
+}
+
+// NewStoryReactionNotification is sent to the owner of a story when someone reacts to it.
+func NewStoryReactionNotification(story string, from int, name, reaction string) *PushNotification {
+	return &PushNotification{
+		Category: STORY_REACTION,
+		Alert: Alert{
+			Key:       "story_reaction_notification",
+			Arguments: []string{name, reaction},
+		},
+		Arguments:  map[string]interface{}{"id": story, "from": from},
+		CollapseID: story,
+	}
+}
+
+// NewTrendingRoomNotification is sent when a room grows quickly, member is someone in the room and count is the amount of members.
+func NewTrendingRoomNotification(id, name, member string, count int) *PushNotification {
+	alert := Alert{Key: "trending_room_notification", Arguments: []string{member, strconv.Itoa(count - 1)}}
+	if name != "" {
+		alert = Alert{Key: "trending_room_name_notification", Arguments: []string{name, strconv.Itoa(count)}}
+	}
+
+	return &PushNotification{
+		Category:   TRENDING_ROOM,
+		Alert:      alert,
+		Arguments:  map[string]interface{}{"id": id},
+		CollapseID: id,
+	}
+}
+
+// NewRoomJoinedNotification is sent when someone joins a room, members are the names shown and count is the amount of members in the room.
+func NewRoomJoinedNotification(id string, creator int, name string, members []string, count int) *PushNotification {
+	keys := []string{
+		"join_room_with_1_notification",
+		"join_room_with_2_notification",
+		"join_room_with_3_notification",
+		"join_room_with_3_and_more_notification",
+	}
+
+	args := make([]string, 0)
+	if name != "" {
+		keys = []string{
+			"join_room_name_with_1_notification",
+			"join_room_name_with_2_notification",
+			"join_room_name_with_3_notification",
+			"join_room_name_with_3_and_more_notification",
+		}
+
+		args = append(args, name)
+	}
+
+	key, args := keyForCount(keys, args, members, count)
+
+	return &PushNotification{
+		Category: ROOM_JOINED,
+		Alert: Alert{
+			Key:       key,
+			Arguments: args,
+		},
+		CollapseID: id,
+		Arguments:  map[string]interface{}{"id": id, "creator": creator},
+	}
+}
+
+// NewFollowRecommendationsNotification recommends users to follow, names are the users shown and count is the amount of recommendations.
+func NewFollowRecommendationsNotification(names []string, count int) *PushNotification {
+	key, args := keyForCount(
+		[]string{
+			"1_follow_recommendations_notification",
+			"2_follow_recommendations_notification",
+			"3_follow_recommendations_notification",
+			"3_and_more_follow_recommendations_notification",
+		},
+		[]string{},
+		names,
+		count,
+	)
+
+	return &PushNotification{
+		Category: FOLLOW_RECOMMENDATIONS,
+		Alert: Alert{
+			Key:       key,
+			Arguments: args,
+		},
+	}
+}
+
+// NewReEngagementFriendsLiveNotification brings back an inactive user whose friends are live in a room, names are the friends in it.
+func NewReEngagementFriendsLiveNotification(room string, names []string) *PushNotification {
+	alert := Alert{Key: "reengagement_friends_live_notification", Arguments: []string{names[0], strconv.Itoa(len(names) - 1)}}
+	if len(names) == 1 {
+		alert = Alert{Key: "reengagement_friend_live_notification", Arguments: []string{names[0]}}
+	}
+
+	return &PushNotification{
+		Category:  REENGAGEMENT,
+		Alert:     alert,
+		Arguments: map[string]interface{}{"id": room},
+	}
+}
+
+// NewReEngagementNewFollowersNotification brings back an inactive user who gained followers while they were away.
+func NewReEngagementNewFollowersNotification(count int) *PushNotification {
+	return &PushNotification{
+		Category: REENGAGEMENT,
+		Alert: Alert{
+			Key:       "reengagement_new_followers_notification",
+			Arguments: []string{strconv.Itoa(count)},
+		},
+		Arguments: map[string]interface{}{},
+	}
+}
+
+// NewReEngagementPopularRoomNotification brings back an inactive user to a popular room someone they follow is in.
+func NewReEngagementPopularRoomNotification(room, name string, members int) *PushNotification {
+	return &PushNotification{
+		Category: REENGAGEMENT,
+		Alert: Alert{
+			Key:       "reengagement_popular_room_notification",
+			Arguments: []string{name, strconv.Itoa(members - 1)},
+		},
+		Arguments: map[string]interface{}{"id": room},
+	}
+}
+
+// NewFeedRefreshNotification is a silent push that has the app refresh its feed in the background.
+func NewFeedRefreshNotification() *PushNotification {
+	return &PushNotification{
+		Category:   FEED_REFRESH,
+		Arguments:  map[string]interface{}{},
+		CollapseID: "feed_refresh",
+	}
+}
+
+// keyForCount selects the key for listing up to 3 names, larger counts list 3 names followed by the amount of others.
+func keyForCount(keys, args, names []string, count int) (string, []string) {
+	if count > len(keys)-1 {
+		return keys[len(keys)-1], append(append(args, names[:len(keys)-1]...), strconv.Itoa(count-len(keys)+1))
+	}
+
+	return keys[count-1], append(args, names[:count]...)
+}
+
 func (n PushNotification) AnalyticsNotification() analytics.Notification {
-	return analytics.Notification{
+	an := analytics.Notification{
 		ID:       n.UUID,
 		Category: string(n.Category),
+		Handler:  n.Handler,
+		Variant:  n.Variant,
 	}
+
+	if n.Campaign != 0 {
+		campaign := n.Campaign
+		an.Campaign = &campaign
+	}
+
+	return an
 }
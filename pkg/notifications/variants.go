@@ -0,0 +1,83 @@
+package notifications
+
+import (
+	"hash/fnv"
+	"strconv"
+)
+
+// Variant is an alternative alert key, variants are used to compare the open rates of different copy.
+type Variant struct {
+	Name string
+	Key  string
+
+	// Weight is the share of targets receiving the variant relative to the other variants.
+	Weight int
+
+	// Rendered is set for keys the apps do not ship, their alerts are rendered on the server.
+	Rendered bool
+}
+
+// VariantGroup is a notification with the targets receiving it.
+type VariantGroup struct {
+	Notification *PushNotification
+	Targets      []Target
+}
+
+// SplitVariants assigns every target one of the notification variants and returns a notification per variant.
+// A target always receives the same variant of an alert key, so users see consistent copy while it is tested.
+func SplitVariants(notification *PushNotification, targets []Target) []VariantGroup {
+	total := 0
+	for _, v := range notification.Variants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+
+	if total == 0 {
+		return []VariantGroup{{Notification: notification, Targets: targets}}
+	}
+
+	groups := make([]VariantGroup, 0, len(notification.Variants))
+	indexes := make(map[string]int)
+
+	for _, target := range targets {
+		variant := selectVariant(notification.Variants, total, notification.Alert.Key, target.ID)
+
+		i, ok := indexes[variant.Name]
+		if !ok {
+			n := *notification
+			n.Alert.Key = variant.Key
+			n.Variant = variant.Name
+			n.Rendered = variant.Rendered
+			n.Variants = nil
+
+			i = len(groups)
+			indexes[variant.Name] = i
+			groups = append(groups, VariantGroup{Notification: &n})
+		}
+
+		groups[i].Targets = append(groups[i].Targets, target)
+	}
+
+	return groups
+}
+
+func selectVariant(variants []Variant, total int, key string, target int) Variant {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key + ":" + strconv.Itoa(target)))
+
+	bucket := int(h.Sum32() % uint32(total))
+	for _, v := range variants {
+		if v.Weight <= 0 {
+			continue
+		}
+
+		if bucket < v.Weight {
+			return v
+		}
+
+		bucket -= v.Weight
+	}
+
+	return variants[len(variants)-1]
+}
@@ -0,0 +1,74 @@
+package notifications_test
+
+import (
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+func TestSplitVariants(t *testing.T) {
+	notification := &notifications.PushNotification{
+		Category: notifications.ROOM_JOINED,
+		Alert:    notifications.Alert{Key: "join_room_with_1_notification", Arguments: []string{"foo"}},
+		Variants: []notifications.Variant{
+			{Name: "control", Key: "join_room_with_1_notification", Weight: 1},
+			{Name: "short", Key: "join_room_with_1_short_notification", Weight: 1},
+		},
+	}
+
+	targets := make([]notifications.Target, 0)
+	for i := 1; i <= 1000; i++ {
+		targets = append(targets, notifications.Target{ID: i})
+	}
+
+	groups := notifications.SplitVariants(notification, targets)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups actual %d", len(groups))
+	}
+
+	received := make(map[int]string)
+	for _, group := range groups {
+		n := group.Notification
+		if n.Variants != nil {
+			t.Fatal("expected variants to be cleared")
+		}
+
+		if (n.Variant == "control") != (n.Alert.Key == "join_room_with_1_notification") {
+			t.Fatalf("variant %s has unexpected key %s", n.Variant, n.Alert.Key)
+		}
+
+		if len(group.Targets) < 400 {
+			t.Fatalf("variant %s received only %d targets", n.Variant, len(group.Targets))
+		}
+
+		for _, target := range group.Targets {
+			received[target.ID] = n.Variant
+		}
+	}
+
+	if len(received) != len(targets) {
+		t.Fatalf("expected %d targets actual %d", len(targets), len(received))
+	}
+
+	for _, group := range notifications.SplitVariants(notification, targets) {
+		for _, target := range group.Targets {
+			if received[target.ID] != group.Notification.Variant {
+				t.Fatalf("target %d received a different variant", target.ID)
+			}
+		}
+	}
+
+	if notification.Alert.Key != "join_room_with_1_notification" {
+		t.Fatal("expected original notification to be unchanged")
+	}
+}
+
+func TestSplitVariants_WithoutVariants(t *testing.T) {
+	notification := &notifications.PushNotification{Category: notifications.NEW_FOLLOWER}
+	targets := []notifications.Target{{ID: 1}, {ID: 2}}
+
+	groups := notifications.SplitVariants(notification, targets)
+	if len(groups) != 1 || groups[0].Notification != notification || len(groups[0].Targets) != 2 {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+}
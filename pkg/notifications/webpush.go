@@ -0,0 +1,7 @@
+package notifications
+
+import "github.com/soapboxsocial/soapbox/pkg/devices"
+
+type WebPush interface {
+	Send(subscription devices.WebPushSubscription, notification PushNotification) error
+}
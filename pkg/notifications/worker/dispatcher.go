@@ -1,54 +1,144 @@
 package worker
 
-import "github.com/soapboxsocial/soapbox/pkg/notifications"
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+const (
+	// reclaimInterval is how many schedule ticks pass between reclaiming stale jobs.
+	reclaimInterval = 30
+
+	// reclaimIdle is how long a job may be pending before another worker takes over.
+	reclaimIdle = 5 * time.Minute
+)
 
 type Dispatcher struct {
-	jobs chan Job
-	pool chan chan Job
+	pool  chan chan Job
+	queue *Queue
 
 	maxWorkers int
 
 	config *Config
 }
 
-func NewDispatcher(maxWorkers int, config *Config) *Dispatcher {
+func NewDispatcher(maxWorkers int, queue *Queue, config *Config) *Dispatcher {
 	return &Dispatcher{
-		jobs:       make(chan Job),
 		pool:       make(chan chan Job),
+		queue:      queue,
 		maxWorkers: maxWorkers,
 		config:     config,
 	}
 }
 
-func (d *Dispatcher) Run() {
+func (d *Dispatcher) Run() error {
+	err := d.queue.Init()
+	if err != nil {
+		return err
+	}
+
 	// starting n number of workers
 	for i := 0; i < d.maxWorkers; i++ {
-		worker := NewWorker(d.pool, d.config)
+		worker := NewWorker(d.pool, d.queue, d.config)
 		worker.Start()
 	}
 
 	go d.dispatch()
+	go d.schedule()
+
+	return nil
 }
 
 func (d *Dispatcher) dispatch() {
 	for {
-		select {
-		case job := <-d.jobs:
-			// a job request has been received
-			go func(job Job) {
-				// try to obtain a worker job channel that is available.
-				// this will block until a worker is idle
-				jobChannel := <-d.pool
+		jobs, err := d.queue.Pop(context.Background(), d.maxWorkers, 5*time.Second)
+		if err != nil {
+			log.Printf("queue.Pop err: %v\n", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
 
-				// dispatch the job to the worker job channel
-				jobChannel <- job
-			}(job)
+		for _, job := range jobs {
+			d.assign(job)
 		}
 	}
 }
 
+// schedule moves due retries back onto the queue and reclaims jobs abandoned by other workers.
+func (d *Dispatcher) schedule() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	ticks := 0
+	for range ticker.C {
+		_, err := d.queue.Schedule()
+		if err != nil {
+			log.Printf("queue.Schedule err: %v\n", err)
+		}
+
+		d.flushDigests()
+
+		ticks++
+		if ticks%reclaimInterval != 0 {
+			continue
+		}
+
+		jobs, err := d.queue.Reclaim(reclaimIdle, 100)
+		if err != nil {
+			log.Printf("queue.Reclaim err: %v\n", err)
+			continue
+		}
+
+		for _, job := range jobs {
+			d.assign(job)
+		}
+	}
+}
+
+// flushDigests queues every digest that is due as a single notification.
+func (d *Dispatcher) flushDigests() {
+	if d.config.Digests == nil {
+		return
+	}
+
+	digests, err := d.config.Digests.Due(time.Now())
+	if err != nil {
+		log.Printf("digests.Due err: %v\n", err)
+	}
+
+	for _, digest := range digests {
+		notification := digest.Notification()
+		if notification == nil {
+			continue
+		}
+
+		job := Job{Origin: digest.Actors[0].ID, Targets: []notifications.Target{digest.Target}, Notification: notification}
+
+		err := d.queue.Push(job)
+		if err != nil {
+			log.Printf("queue.Push err: %v\n", err)
+		}
+	}
+}
+
+func (d *Dispatcher) assign(job Job) {
+	// try to obtain a worker job channel that is available.
+	// this will block until a worker is idle
+	jobChannel := <-d.pool
+
+	// dispatch the job to the worker job channel
+	jobChannel <- job
+}
+
+// Dispatch queues a notification, notifications with variants are queued once per variant.
 func (d *Dispatcher) Dispatch(origin int, targets []notifications.Target, notification *notifications.PushNotification) {
-	go func() {
-		d.jobs <- Job{Origin: origin, Targets: targets, Notification: notification}
-	}()
+	for _, group := range notifications.SplitVariants(notification, targets) {
+		err := d.queue.Push(Job{Origin: origin, Targets: group.Targets, Notification: group.Notification})
+		if err != nil {
+			log.Printf("queue.Push err: %v\n", err)
+		}
+	}
 }
@@ -0,0 +1,492 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/devices"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+const (
+	jobsStream        = "notifications_jobs"
+	retriesKey        = "notifications_jobs_retries"
+	deadLettersStream = "notifications_jobs_dead"
+	consumerGroup     = "workers"
+)
+
+var (
+	baseBackoff = 1 * time.Second
+	maxBackoff  = 5 * time.Minute
+
+	// maxAttempts is the amount of times a job is tried before it is dead-lettered.
+	maxAttempts = 5
+)
+
+var (
+	errInvalidMessage = errors.New("invalid message")
+	errDeliveryFailed = errors.New("delivery failed")
+)
+
+// DeadLetter is a job that failed too many times.
+type DeadLetter struct {
+	ID     string
+	Job    Job
+	Error  string
+	Failed time.Time
+}
+
+// Queue is a durable job queue backed by a redis stream and consumer group.
+// Jobs are only removed once they are acknowledged, so jobs that were in-flight during a crash are picked up again.
+type Queue struct {
+	rdb      *redis.Client
+	consumer string
+}
+
+// NewQueue creates a queue, the consumer should uniquely identify the process reading from it.
+func NewQueue(rdb *redis.Client, consumer string) *Queue {
+	return &Queue{
+		rdb:      rdb,
+		consumer: consumer,
+	}
+}
+
+// Init creates the consumer group if it does not exist yet.
+func (q *Queue) Init() error {
+	err := q.rdb.XGroupCreateMkStream(q.rdb.Context(), jobsStream, consumerGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	return nil
+}
+
+// Push adds a job to the queue.
+func (q *Queue) Push(job Job) error {
+	data, err := encode(job)
+	if err != nil {
+		return err
+	}
+
+	return q.rdb.XAdd(q.rdb.Context(), &redis.XAddArgs{
+		Stream: jobsStream,
+		Values: map[string]interface{}{"job": data},
+	}).Err()
+}
+
+// Pop blocks until jobs are available or the timeout expires.
+func (q *Queue) Pop(ctx context.Context, count int, timeout time.Duration) ([]Job, error) {
+	res, err := q.rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    consumerGroup,
+		Consumer: q.consumer,
+		Streams:  []string{jobsStream, ">"},
+		Count:    int64(count),
+		Block:    timeout,
+	}).Result()
+
+	if err == redis.Nil {
+		return []Job{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]Job, 0)
+	for _, stream := range res {
+		jobs = append(jobs, q.decodeMessages(stream.Messages)...)
+	}
+
+	return jobs, nil
+}
+
+// Reclaim takes over jobs that were delivered to a consumer but not acknowledged within idle.
+// This recovers jobs from workers that crashed or were restarted.
+func (q *Queue) Reclaim(idle time.Duration, count int) ([]Job, error) {
+	pending, err := q.rdb.XPendingExt(q.rdb.Context(), &redis.XPendingExtArgs{
+		Stream: jobsStream,
+		Group:  consumerGroup,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(count),
+	}).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0)
+	for _, p := range pending {
+		if p.Idle >= idle {
+			ids = append(ids, p.ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return []Job{}, nil
+	}
+
+	messages, err := q.rdb.XClaim(q.rdb.Context(), &redis.XClaimArgs{
+		Stream:   jobsStream,
+		Group:    consumerGroup,
+		Consumer: q.consumer,
+		MinIdle:  idle,
+		Messages: ids,
+	}).Result()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return q.decodeMessages(messages), nil
+}
+
+// Ack marks a job as completed and removes it from the stream.
+func (q *Queue) Ack(job Job) error {
+	if job.ID == "" {
+		return nil
+	}
+
+	pipe := q.rdb.TxPipeline()
+	pipe.XAck(q.rdb.Context(), jobsStream, consumerGroup, job.ID)
+	pipe.XDel(q.rdb.Context(), jobsStream, job.ID)
+	_, err := pipe.Exec(q.rdb.Context())
+	return err
+}
+
+// Retry schedules a job to be retried with exponential backoff, once a job has been attempted too often it is dead-lettered.
+// The original job is acknowledged.
+func (q *Queue) Retry(job Job, reason error) error {
+	original := job
+
+	job.ID = ""
+	job.Attempts++
+
+	var err error
+	if job.Attempts >= maxAttempts {
+		err = q.deadLetter(job, reason)
+	} else {
+		err = q.schedule(job, time.Now().Add(backoff(job.Attempts)))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return q.Ack(original)
+}
+
+// Defer adds a job to the queue once the given time is reached.
+func (q *Queue) Defer(job Job, at time.Time) error {
+	job.ID = ""
+	return q.schedule(job, at)
+}
+
+// Schedule moves retries and deferred jobs that are due onto the queue, it returns the amount of moved jobs.
+func (q *Queue) Schedule() (int, error) {
+	ctx := q.rdb.Context()
+
+	due, err := q.rdb.ZRangeByScore(ctx, retriesKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(millis(time.Now()), 10),
+		Count: 100,
+	}).Result()
+
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, data := range due {
+		// only the process that removes the retry may enqueue it, this prevents duplicates with multiple schedulers.
+		removed, err := q.rdb.ZRem(ctx, retriesKey, data).Result()
+		if err != nil {
+			return moved, err
+		}
+
+		if removed == 0 {
+			continue
+		}
+
+		err = q.rdb.XAdd(ctx, &redis.XAddArgs{
+			Stream: jobsStream,
+			Values: map[string]interface{}{"job": data},
+		}).Err()
+
+		if err != nil {
+			return moved, err
+		}
+
+		moved++
+	}
+
+	return moved, nil
+}
+
+// DeadLetters returns up to count dead-lettered jobs, oldest first. All jobs are returned if count is not positive.
+func (q *Queue) DeadLetters(count int) ([]DeadLetter, error) {
+	var messages []redis.XMessage
+	var err error
+
+	if count > 0 {
+		messages, err = q.rdb.XRangeN(q.rdb.Context(), deadLettersStream, "-", "+", int64(count)).Result()
+	} else {
+		messages, err = q.rdb.XRange(q.rdb.Context(), deadLettersStream, "-", "+").Result()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0)
+	for _, message := range messages {
+		letter, err := decodeDeadLetter(message)
+		if err != nil {
+			continue
+		}
+
+		letters = append(letters, *letter)
+	}
+
+	return letters, nil
+}
+
+// Replay moves a dead-lettered job back onto the queue with its attempts reset.
+func (q *Queue) Replay(id string) error {
+	ctx := q.rdb.Context()
+
+	messages, err := q.rdb.XRange(ctx, deadLettersStream, id, id).Result()
+	if err != nil {
+		return err
+	}
+
+	if len(messages) == 0 {
+		return errors.New("dead letter not found")
+	}
+
+	letter, err := decodeDeadLetter(messages[0])
+	if err != nil {
+		return err
+	}
+
+	job := letter.Job
+	job.Attempts = 0
+
+	err = q.Push(job)
+	if err != nil {
+		return err
+	}
+
+	return q.rdb.XDel(ctx, deadLettersStream, id).Err()
+}
+
+func (q *Queue) schedule(job Job, at time.Time) error {
+	data, err := encode(job)
+	if err != nil {
+		return err
+	}
+
+	return q.rdb.ZAdd(q.rdb.Context(), retriesKey, &redis.Z{Score: float64(millis(at)), Member: data}).Err()
+}
+
+func (q *Queue) deadLetter(job Job, reason error) error {
+	data, err := encode(job)
+	if err != nil {
+		return err
+	}
+
+	msg := ""
+	if reason != nil {
+		msg = reason.Error()
+	}
+
+	return q.rdb.XAdd(q.rdb.Context(), &redis.XAddArgs{
+		Stream: deadLettersStream,
+		Values: map[string]interface{}{"job": data, "error": msg, "failed": time.Now().Unix()},
+	}).Err()
+}
+
+func (q *Queue) decodeMessages(messages []redis.XMessage) []Job {
+	jobs := make([]Job, 0)
+	for _, message := range messages {
+		job, err := decodeMessage(message)
+		if err != nil {
+			// a job we cannot read will never succeed, so we remove it.
+			_ = q.Ack(Job{ID: message.ID})
+			continue
+		}
+
+		jobs = append(jobs, *job)
+	}
+
+	return jobs
+}
+
+// backoff returns the delay before an attempt, it grows exponentially and uses jitter to spread out retries.
+func backoff(attempt int) time.Duration {
+	d := time.Duration(float64(baseBackoff) * math.Pow(2, float64(attempt-1)))
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// target is used to serialize a notification target, whose ID is hidden from JSON.
+type target struct {
+	ID int `json:"id"`
+	notifications.Target
+}
+
+// message is the serialized form of a Job.
+type message struct {
+	Origin        int                             `json:"origin"`
+	Targets       []target                        `json:"targets"`
+	Notification  *notifications.PushNotification `json:"notification"`
+	CollapseID    string                          `json:"collapse_id,omitempty"`
+	Campaign      int                             `json:"campaign,omitempty"`
+	Handler       string                          `json:"handler,omitempty"`
+	Variant       string                          `json:"variant,omitempty"`
+	Rendered      bool                            `json:"rendered,omitempty"`
+	Image         string                          `json:"image,omitempty"`
+	Recipient     string                          `json:"recipient,omitempty"`
+	Devices       []devices.Device                `json:"devices,omitempty"`
+	Subscriptions []devices.WebPushSubscription   `json:"subscriptions,omitempty"`
+	Emails        []target                        `json:"emails,omitempty"`
+	Attempts      int                             `json:"attempts"`
+}
+
+func encode(job Job) (string, error) {
+	if job.Notification == nil {
+		return "", errInvalidMessage
+	}
+
+	m := message{
+		Origin:        job.Origin,
+		Targets:       make([]target, 0, len(job.Targets)),
+		Notification:  job.Notification,
+		CollapseID:    job.Notification.CollapseID,
+		Campaign:      job.Notification.Campaign,
+		Handler:       job.Notification.Handler,
+		Variant:       job.Notification.Variant,
+		Rendered:      job.Notification.Rendered,
+		Image:         job.Notification.Image,
+		Recipient:     job.Notification.Recipient,
+		Devices:       job.Devices,
+		Subscriptions: job.Subscriptions,
+		Attempts:      job.Attempts,
+	}
+
+	for _, t := range job.Targets {
+		m.Targets = append(m.Targets, target{ID: t.ID, Target: t})
+	}
+
+	for _, t := range job.Emails {
+		m.Emails = append(m.Emails, target{ID: t.ID, Target: t})
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func decode(data string) (*Job, error) {
+	m := &message{}
+	err := json.Unmarshal([]byte(data), m)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.Notification == nil {
+		return nil, errInvalidMessage
+	}
+
+	m.Notification.CollapseID = m.CollapseID
+	m.Notification.Campaign = m.Campaign
+	m.Notification.Handler = m.Handler
+	m.Notification.Variant = m.Variant
+	m.Notification.Rendered = m.Rendered
+	m.Notification.Image = m.Image
+	m.Notification.Recipient = m.Recipient
+
+	// JSON decodes all numbers as floats, our handlers build arguments with ints.
+	for key, val := range m.Notification.Arguments {
+		if f, ok := val.(float64); ok && f == math.Trunc(f) {
+			m.Notification.Arguments[key] = int(f)
+		}
+	}
+
+	job := &Job{
+		Origin:        m.Origin,
+		Targets:       make([]notifications.Target, 0, len(m.Targets)),
+		Notification:  m.Notification,
+		Devices:       m.Devices,
+		Subscriptions: m.Subscriptions,
+		Attempts:      m.Attempts,
+	}
+
+	for _, t := range m.Targets {
+		tt := t.Target
+		tt.ID = t.ID
+		job.Targets = append(job.Targets, tt)
+	}
+
+	for _, t := range m.Emails {
+		tt := t.Target
+		tt.ID = t.ID
+		job.Emails = append(job.Emails, tt)
+	}
+
+	return job, nil
+}
+
+func decodeMessage(msg redis.XMessage) (*Job, error) {
+	data, ok := msg.Values["job"].(string)
+	if !ok {
+		return nil, errInvalidMessage
+	}
+
+	job, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	job.ID = msg.ID
+	return job, nil
+}
+
+func decodeDeadLetter(msg redis.XMessage) (*DeadLetter, error) {
+	job, err := decodeMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	job.ID = ""
+
+	letter := &DeadLetter{ID: msg.ID, Job: *job}
+	letter.Error, _ = msg.Values["error"].(string)
+
+	failed, _ := msg.Values["failed"].(string)
+	ts, err := strconv.ParseInt(failed, 10, 64)
+	if err == nil {
+		letter.Failed = time.Unix(ts, 0)
+	}
+
+	return letter, nil
+}
@@ -0,0 +1,204 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/worker"
+)
+
+func newQueue(t *testing.T) *worker.Queue {
+	t.Helper()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(mr.Close)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	queue := worker.NewQueue(rdb, "test")
+	err = queue.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return queue
+}
+
+func newJob() worker.Job {
+	return worker.Job{
+		Origin:  12,
+		Targets: []notifications.Target{{ID: 1, RoomFrequency: notifications.Frequent, Follows: true}},
+		Notification: &notifications.PushNotification{
+			Category:   notifications.NEW_FOLLOWER,
+			Alert:      notifications.Alert{Key: "new_follower_notification", Arguments: []string{"foo"}},
+			Arguments:  map[string]interface{}{"id": 12},
+			CollapseID: "abc",
+			Handler:    "Follower",
+			Variant:    "control",
+			Rendered:   true,
+		},
+	}
+}
+
+func pop(t *testing.T, queue *worker.Queue) []worker.Job {
+	t.Helper()
+
+	jobs, err := queue.Pop(context.Background(), 10, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return jobs
+}
+
+func TestQueue_PushPop(t *testing.T) {
+	queue := newQueue(t)
+	job := newJob()
+
+	err := queue.Push(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := pop(t, queue)
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job actual %d", len(jobs))
+	}
+
+	if jobs[0].ID == "" {
+		t.Fatal("expected job ID")
+	}
+
+	expected := job
+	expected.ID = jobs[0].ID
+
+	if !reflect.DeepEqual(jobs[0], expected) {
+		t.Fatalf("expected %v actual %v", expected, jobs[0])
+	}
+
+	err = queue.Ack(jobs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pop(t, queue)) != 0 {
+		t.Fatal("expected empty queue")
+	}
+}
+
+func TestQueue_RetryAndSchedule(t *testing.T) {
+	queue := newQueue(t)
+
+	err := queue.Push(newJob())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := pop(t, queue)
+
+	err = queue.Retry(jobs[0], errors.New("failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := queue.Schedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moved != 0 {
+		t.Fatalf("expected retry to be delayed, moved %d", moved)
+	}
+
+	// the first retry happens after at most one second.
+	time.Sleep(1100 * time.Millisecond)
+
+	moved, err = queue.Schedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if moved != 1 {
+		t.Fatalf("expected 1 moved actual %d", moved)
+	}
+
+	jobs = pop(t, queue)
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job actual %d", len(jobs))
+	}
+
+	if jobs[0].Attempts != 1 {
+		t.Fatalf("expected 1 attempt actual %d", jobs[0].Attempts)
+	}
+}
+
+func TestQueue_DeadLetters(t *testing.T) {
+	queue := newQueue(t)
+
+	job := newJob()
+	job.Attempts = 4
+
+	err := queue.Push(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := pop(t, queue)
+
+	err = queue.Retry(jobs[0], errors.New("failed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	letters, err := queue.DeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(letters) != 1 {
+		t.Fatalf("expected 1 dead letter actual %d", len(letters))
+	}
+
+	if letters[0].Error != "failed" {
+		t.Fatalf("unexpected error %s", letters[0].Error)
+	}
+
+	// miniredis derives stream IDs from the clock and does not remember deleted entries, so we make sure the replay gets a newer ID.
+	time.Sleep(2 * time.Millisecond)
+
+	err = queue.Replay(letters[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs = pop(t, queue)
+	if len(jobs) != 1 {
+		t.Fatalf("expected 1 job actual %d", len(jobs))
+	}
+
+	if jobs[0].Attempts != 0 {
+		t.Fatalf("expected attempts to be reset actual %d", jobs[0].Attempts)
+	}
+
+	letters, err = queue.DeadLetters(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(letters) != 0 {
+		t.Fatalf("expected no dead letters actual %d", len(letters))
+	}
+}
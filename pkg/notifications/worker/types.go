@@ -1,9 +1,34 @@
 package worker
 
-import "github.com/soapboxsocial/soapbox/pkg/notifications"
+import (
+	"github.com/soapboxsocial/soapbox/pkg/devices"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
 
 type Job struct {
+	// ID is the queue entry of the job, it is empty for jobs that were not read from the queue.
+	ID string
+
 	Origin       int
 	Targets      []notifications.Target
 	Notification *notifications.PushNotification
+
+	// Devices, Subscriptions and Emails are set when retrying a delivery, only these will be sent to.
+	Devices       []devices.Device
+	Subscriptions []devices.WebPushSubscription
+	Emails        []notifications.Target
+
+	Attempts int
+}
+
+// IsRetry returns whether the job is a retry of a previously failed delivery.
+func (j Job) IsRetry() bool {
+	return len(j.Devices) > 0 || len(j.Subscriptions) > 0 || len(j.Emails) > 0
+}
+
+// recipient is a notification target with the channels it receives a notification on.
+type recipient struct {
+	notifications.Target
+
+	channels notifications.ChannelPreferences
 }
@@ -6,18 +6,24 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 
 	"github.com/soapboxsocial/soapbox/pkg/analytics"
 	"github.com/soapboxsocial/soapbox/pkg/devices"
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
 )
 
 type Config struct {
 	APNS      notifications.APNS
+	WebPush   notifications.WebPush
+	Email     notifications.Email
 	Limiter   *notifications.Limiter
 	Devices   *devices.Backend
 	Store     *notifications.Storage
+	Digests   *notifications.Digests
 	Analytics *analytics.Backend
+	Templates *templates.Registry
 }
 
 type Worker struct {
@@ -26,22 +32,24 @@ type Worker struct {
 	quit    chan bool
 
 	unregistered chan string
+	expired      chan string
+	queue        *Queue
 	config       *Config
-
-	maxRetries int
 }
 
-func NewWorker(pool chan<- chan Job, config *Config) *Worker {
+func NewWorker(pool chan<- chan Job, queue *Queue, config *Config) *Worker {
 	w := &Worker{
 		workers:      pool,
 		jobs:         make(chan Job),
 		quit:         make(chan bool),
 		unregistered: make(chan string, 100),
+		expired:      make(chan string, 100),
+		queue:        queue,
 		config:       config,
-		maxRetries:   5,
 	}
 
 	go w.wipeDevices()
+	go w.wipeSubscriptions()
 
 	return w
 }
@@ -57,10 +65,11 @@ func (w *Worker) Start() {
 			select {
 			case job := <-w.jobs:
 				// Receive a work request.
-				w.handle(job)
+				w.process(job)
 			case <-w.quit:
 				// We have been asked to stop.
 				close(w.unregistered)
+				close(w.expired)
 				return
 			}
 		}
@@ -73,85 +82,221 @@ func (w *Worker) Stop() {
 	}()
 }
 
-func (w *Worker) handle(job Job) {
-	ids := make([]int, 0)
-	targets := make([]notifications.Target, 0)
+// process handles a job and acknowledges it, failed deliveries are handed back to the queue to be retried.
+func (w *Worker) process(job Job) {
+	retry, err := w.handle(job)
+	if err != nil {
+		log.Printf("failed to handle job err: %v\n", err)
 
-	for _, target := range job.Targets {
-		if !w.config.Limiter.ShouldSendNotification(target, job.Notification) {
-			continue
+		err = w.queue.Retry(job, err)
+		if err != nil {
+			log.Printf("queue.Retry err: %v\n", err)
 		}
 
-		ids = append(ids, target.ID)
-		targets = append(targets, target)
+		return
 	}
 
-	if len(ids) == 0 {
+	if retry != nil {
+		retry.ID = job.ID
+		retry.Attempts = job.Attempts
+
+		err = w.queue.Retry(*retry, errDeliveryFailed)
+		if err != nil {
+			log.Printf("queue.Retry err: %v\n", err)
+		}
+
 		return
 	}
 
-	d, err := w.config.Devices.GetDevicesForUsers(ids)
+	err = w.queue.Ack(job)
 	if err != nil {
-		log.Printf("devicesBackend.GetDevicesForUsers err: %v\n", err)
-		return
+		log.Printf("queue.Ack err: %v\n", err)
 	}
+}
+
+// handle sends the job, it returns a job containing the deliveries that need to be retried.
+func (w *Worker) handle(job Job) (*Job, error) {
+	if job.IsRetry() {
+		return w.deliver(*job.Notification, job.Devices, job.Subscriptions, job.Emails), nil
+	}
+
+	ids := make([]int, 0)
+	emails := make([]notifications.Target, 0)
+	recipients := make([]recipient, 0)
 
-	log.Printf("pushing %s to %d targets", job.Notification.Category, len(targets))
+	for _, t := range job.Targets {
+		if !w.config.Limiter.ShouldSendNotification(t, job.Notification) {
+			continue
+		}
+
+		if until, ok := w.config.Limiter.DeferNotification(t, job.Notification); ok {
+			w.deferNotification(job, t, until)
+			continue
+		}
+
+		if w.config.Digests != nil {
+			if until, ok := w.config.Limiter.DigestNotification(t, job.Notification); ok {
+				err := w.config.Digests.Add(t, job.Notification, until)
+				if err != nil {
+					log.Printf("digests.Add err: %v\n", err)
+				}
+
+				continue
+			}
+		}
+
+		channels := w.config.Limiter.ChannelsFor(t, job.Notification)
+		if w.config.Email == nil {
+			channels.Email = false
+		}
+
+		if channels.Push {
+			ids = append(ids, t.ID)
+		}
+
+		if channels.Email {
+			emails = append(emails, t)
+		}
+
+		recipients = append(recipients, recipient{Target: t, channels: channels})
+	}
+
+	if len(recipients) == 0 {
+		return nil, nil
+	}
 
 	notification := *job.Notification
 	notification.UUID = uuid.NewString()
 
-	for i := 0; i < w.maxRetries; i++ {
-		d = w.sendNotifications(d, notification)
-		if len(d) == 0 {
-			break
+	// silent pushes are only delivered to the app, browsers must show every push they receive.
+	silent := notifications.IsSilent(notification.Category)
+
+	d := make([]devices.Device, 0)
+	subscriptions := make([]devices.WebPushSubscription, 0)
+	if len(ids) > 0 {
+		var err error
+		d, err = w.config.Devices.GetDevicesForUsers(ids)
+		if err != nil {
+			return nil, errors.Wrap(err, "devicesBackend.GetDevicesForUsers")
 		}
-	}
 
-	for _, target := range targets {
-		an := notification.AnalyticsNotification()
-		if job.Origin != 0 {
-			an.Origin = &job.Origin
+		if !silent {
+			w.setBadges(d, recipients)
 		}
 
-		err := w.config.Analytics.AddSentNotification(target.ID, an)
-		if err != nil {
-			log.Printf("analytics.AddSentNotification err: %s\n", err)
+		if notification.Rendered {
+			setDeviceLocales(d, recipients)
 		}
 
-		w.config.Limiter.SentNotification(target, job.Notification)
+		if w.config.WebPush != nil && !silent {
+			subscriptions, err = w.config.Devices.GetWebPushSubscriptionsForUsers(ids)
+			if err != nil {
+				return nil, errors.Wrap(err, "devicesBackend.GetWebPushSubscriptionsForUsers")
+			}
+
+			setLocales(subscriptions, recipients)
+		}
+
+		log.Printf("pushing %s to %d targets", job.Notification.Category, len(ids))
+	}
+
+	if len(emails) > 0 {
+		log.Printf("emailing %s to %d targets", job.Notification.Category, len(emails))
+	}
+
+	retry := w.deliver(notification, d, subscriptions, emails)
+
+	for _, t := range recipients {
+		if (t.channels.Push || t.channels.Email) && !silent {
+			an := notification.AnalyticsNotification()
+			if job.Origin != 0 {
+				an.Origin = &job.Origin
+			}
+
+			err := w.config.Analytics.AddSentNotification(t.ID, an)
+			if err != nil {
+				log.Printf("analytics.AddSentNotification err: %s\n", err)
+			}
+		}
 
-		store := getNotificationForStore(job.Notification)
-		if store == nil {
-			return
+		w.config.Limiter.SentNotification(t.Target, job.Notification)
+
+		if !t.channels.Inbox {
+			continue
 		}
 
-		err = w.config.Store.Store(target.ID, store)
+		err := w.config.Store.Store(t.ID, getNotificationForStore(job.Origin, job.Notification))
 		if err != nil {
 			log.Printf("notificationStorage.Store err: %v\n", err)
 		}
 	}
+
+	return retry, nil
+}
+
+// deferNotification queues the notification for a single target to be handled again at the given time.
+func (w *Worker) deferNotification(job Job, target notifications.Target, until time.Time) {
+	err := w.queue.Defer(Job{Origin: job.Origin, Targets: []notifications.Target{target}, Notification: job.Notification}, until)
+	if err != nil {
+		log.Printf("queue.Defer err: %v\n", err)
+	}
+}
+
+// deliver sends the notification to all devices, subscriptions and emails once, it returns a job for those that need a retry.
+func (w *Worker) deliver(notification notifications.PushNotification, tokens []devices.Device, subscriptions []devices.WebPushSubscription, emails []notifications.Target) *Job {
+	retryDevices := w.sendNotifications(tokens, notification)
+
+	var retrySubscriptions []devices.WebPushSubscription
+	if w.config.WebPush != nil {
+		retrySubscriptions = w.sendWebPushNotifications(subscriptions, notification)
+	}
+
+	var retryEmails []notifications.Target
+	if w.config.Email != nil {
+		retryEmails = w.sendEmails(emails, notification)
+	}
+
+	if len(retryDevices) == 0 && len(retrySubscriptions) == 0 && len(retryEmails) == 0 {
+		return nil
+	}
+
+	return &Job{
+		Notification:  &notification,
+		Devices:       retryDevices,
+		Subscriptions: retrySubscriptions,
+		Emails:        retryEmails,
+	}
 }
 
 // @TODO THIS SHOULD PROBABLY BE MOVED INTO APNS, especially once we add iOS
-func (w *Worker) sendNotifications(devices []string, notification notifications.PushNotification) []string {
+func (w *Worker) sendNotifications(targets []devices.Device, notification notifications.PushNotification) []devices.Device {
 	var wg sync.WaitGroup
+	var mu sync.Mutex
 
-	retry := make([]string, 0)
+	retry := make([]devices.Device, 0)
 
-	for _, device := range devices {
+	for _, device := range targets {
 		wg.Add(1)
-		go func(device string) {
-			err := w.config.APNS.Send(device, notification)
+		go func(device devices.Device) {
+			n := notification
+			n.Badge = device.Badge
+
+			if n.Rendered {
+				n = w.render(device.Locale, n)
+			}
+
+			err := w.config.APNS.Send(device.Token, n)
 			if err != nil {
 				switch err {
 				case notifications.ErrDeviceUnregistered:
-					w.unregistered <- device
+					w.unregistered <- device.Token
 				case notifications.ErrRetryRequired:
+					mu.Lock()
 					retry = append(retry, device)
+					mu.Unlock()
 				}
 
-				log.Printf("failed to send to target \"%s\" with error: %s\n", device, err)
+				log.Printf("failed to send to target \"%s\" with error: %s\n", device.Token, err)
 			}
 
 			wg.Done()
@@ -163,6 +308,98 @@ func (w *Worker) sendNotifications(devices []string, notification notifications.
 	return retry
 }
 
+func (w *Worker) sendWebPushNotifications(subscriptions []devices.WebPushSubscription, notification notifications.PushNotification) []devices.WebPushSubscription {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	retry := make([]devices.WebPushSubscription, 0)
+
+	for _, subscription := range subscriptions {
+		wg.Add(1)
+		go func(subscription devices.WebPushSubscription) {
+			defer wg.Done()
+
+			err := w.config.WebPush.Send(subscription, w.render(subscription.Locale, notification))
+			if err == nil {
+				return
+			}
+
+			switch err {
+			case notifications.ErrSubscriptionExpired:
+				w.expired <- subscription.Endpoint
+			case notifications.ErrRetryRequired:
+				mu.Lock()
+				retry = append(retry, subscription)
+				mu.Unlock()
+			}
+
+			log.Printf("failed to send to subscription \"%s\" with error: %s\n", subscription.Endpoint, err)
+		}(subscription)
+	}
+
+	wg.Wait()
+
+	return retry
+}
+
+func (w *Worker) sendEmails(targets []notifications.Target, notification notifications.PushNotification) []notifications.Target {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	retry := make([]notifications.Target, 0)
+
+	for _, target := range targets {
+		wg.Add(1)
+		go func(target notifications.Target) {
+			defer wg.Done()
+
+			err := w.config.Email.Send(target, notification)
+			if err == nil {
+				return
+			}
+
+			if err == notifications.ErrRetryRequired {
+				mu.Lock()
+				retry = append(retry, target)
+				mu.Unlock()
+			}
+
+			log.Printf("failed to email target \"%d\" with error: %s\n", target.ID, err)
+		}(target)
+	}
+
+	wg.Wait()
+
+	return retry
+}
+
+// render sets the alert body to the text of the notification in the locale, for channels that cannot localize it themselves.
+func (w *Worker) render(locale string, notification notifications.PushNotification) notifications.PushNotification {
+	if w.config.Templates == nil {
+		return notification
+	}
+
+	body, err := w.config.Templates.RenderAlert(locale, notification.Alert)
+	if err != nil {
+		log.Printf("templates.RenderAlert err: %v\n", err)
+		return notification
+	}
+
+	notification.Alert.Body = body
+	return notification
+}
+
+func (w *Worker) wipeSubscriptions() {
+	for endpoint := range w.expired {
+		log.Printf("removing web push subscription: %s", endpoint)
+
+		err := w.config.Devices.RemoveWebPushSubscription(endpoint)
+		if err != nil {
+			log.Printf("failed to remove web push subscription err: %s", err)
+		}
+	}
+}
+
 func (w *Worker) wipeDevices() {
 	for device := range w.unregistered {
 		log.Printf("removing device: %s", device)
@@ -174,27 +411,98 @@ func (w *Worker) wipeDevices() {
 	}
 }
 
-func getNotificationForStore(notification *notifications.PushNotification) *notifications.Notification {
-	switch notification.Category {
-	case notifications.NEW_FOLLOWER:
-		return &notifications.Notification{
-			Timestamp: time.Now().Unix(),
-			From:      notification.Arguments["id"].(int),
-			Category:  notification.Category,
-		}
-	case notifications.WELCOME_ROOM:
-		return &notifications.Notification{
-			Timestamp: time.Now().Unix(),
-			From:      notification.Arguments["from"].(int),
-			Category:  notification.Category,
-			Arguments: map[string]interface{}{"room": notification.Arguments["id"]},
+// setBadges sets the badge of every device to the unread count of its user, including the notification being sent to their inbox.
+func (w *Worker) setBadges(targets []devices.Device, recipients []recipient) {
+	if w.config.Store == nil || len(targets) == 0 {
+		return
+	}
+
+	ids := make([]int, 0, len(recipients))
+	for _, r := range recipients {
+		ids = append(ids, r.ID)
+	}
+
+	counts, err := w.config.Store.UnreadCounts(ids)
+	if err != nil {
+		log.Printf("notificationStorage.UnreadCounts err: %v\n", err)
+		return
+	}
+
+	for _, r := range recipients {
+		if r.channels.Inbox {
+			counts[r.ID]++
 		}
-	case notifications.FOLLOW_RECOMMENDATIONS:
-		return &notifications.Notification{
-			Timestamp: time.Now().Unix(),
-			From:      0,
-			Category:  notification.Category,
+	}
+
+	for i := range targets {
+		badge := counts[targets[i].UserID]
+		targets[i].Badge = &badge
+	}
+}
+
+func setDeviceLocales(targets []devices.Device, recipients []recipient) {
+	locales := make(map[int]string)
+	for _, r := range recipients {
+		locales[r.ID] = r.Locale
+	}
+
+	for i := range targets {
+		targets[i].Locale = locales[targets[i].UserID]
+	}
+}
+
+func setLocales(subscriptions []devices.WebPushSubscription, recipients []recipient) {
+	locales := make(map[int]string)
+	for _, r := range recipients {
+		locales[r.ID] = r.Locale
+	}
+
+	for i := range subscriptions {
+		subscriptions[i].Locale = locales[subscriptions[i].UserID]
+	}
+}
+
+func getNotificationForStore(origin int, notification *notifications.PushNotification) *notifications.Notification {
+	return &notifications.Notification{
+		Timestamp: time.Now().Unix(),
+		From:      getFrom(origin, notification),
+		Category:  notification.Category,
+		Alert:     notification.Alert,
+		Arguments: notification.Arguments,
+		Actors:    getActors(notification),
+	}
+}
+
+// getFrom returns the sender of the notification, welcome rooms have no origin and name the sender in the arguments.
+func getFrom(origin int, notification *notifications.PushNotification) int {
+	if origin != 0 {
+		return origin
+	}
+
+	switch from := notification.Arguments["from"].(type) {
+	case int:
+		return from
+	case float64:
+		return int(from)
+	default:
+		return 0
+	}
+}
+
+// getActors returns the digest actors, these are decoded as floats when the job was read from the queue.
+func getActors(notification *notifications.PushNotification) []int {
+	switch actors := notification.Arguments["actors"].(type) {
+	case []int:
+		return actors
+	case []interface{}:
+		ids := make([]int, 0, len(actors))
+		for _, actor := range actors {
+			if id, ok := actor.(float64); ok {
+				ids = append(ids, int(id))
+			}
 		}
+
+		return ids
 	default:
 		return nil
 	}
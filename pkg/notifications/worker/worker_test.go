@@ -1,17 +1,23 @@
 package worker_test
 
 import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 	"github.com/golang/mock/gomock"
 
 	"github.com/soapboxsocial/soapbox/mocks"
 	"github.com/soapboxsocial/soapbox/pkg/analytics"
+	"github.com/soapboxsocial/soapbox/pkg/apple"
 	"github.com/soapboxsocial/soapbox/pkg/devices"
 	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/notifications/templates"
 	"github.com/soapboxsocial/soapbox/pkg/notifications/worker"
 	"github.com/soapboxsocial/soapbox/pkg/rooms"
 )
@@ -40,11 +46,12 @@ func TestWorker(t *testing.T) {
 	pool := make(chan chan worker.Job)
 	w := worker.NewWorker(
 		pool,
+		worker.NewQueue(rdb, "test"),
 		&worker.Config{
 			APNS:      apns,
 			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
 			Devices:   devices.NewBackend(db),
-			Store:     notifications.NewStorage(rdb),
+			Store:     notifications.NewStorage(db),
 			Analytics: analytics.NewBackend(db),
 		},
 	)
@@ -65,13 +72,112 @@ func TestWorker(t *testing.T) {
 	mock.
 		ExpectPrepare("^SELECT (.+)").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"token"}).FromCSVString(device))
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 2))
+
+	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).DoAndReturn(func(_ string, n notifications.PushNotification) error {
+		// the unread notifications include the one being sent.
+		if n.Badge == nil || *n.Badge != 3 {
+			t.Errorf("unexpected badge %v", n.Badge)
+		}
+
+		return nil
+	})
+
+	mock.
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.
+		ExpectPrepare("^INSERT INTO notifications (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Targets: []notifications.Target{{
+			ID:            id,
+			RoomFrequency: notifications.Frequent,
+			Follows:       true,
+			Preferences:   notifications.Preferences{notifications.ROOM_JOINED: {Push: true, Inbox: true}},
+		}},
+		Notification: &notification,
+	}
+
+	<-pool
+}
+
+func TestWorker_StoresWelcomeRoomSender(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	id := 1
+	device := "1234"
+	notification := notifications.PushNotification{
+		Category:  notifications.WELCOME_ROOM,
+		Arguments: map[string]interface{}{"id": "123", "from": 7},
+	}
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 0))
 
 	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).Return(nil)
 
 	mock.
-		ExpectPrepare("^INSERT (.+)").
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	// welcome rooms have no origin, the sender is taken from the arguments.
+	mock.
+		ExpectPrepare("^INSERT INTO notifications (.+)").
 		ExpectExec().
+		WithArgs(id, sql.NullInt64{Int64: 7, Valid: true}, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	w.Start()
@@ -79,11 +185,16 @@ func TestWorker(t *testing.T) {
 	queue := <-pool
 
 	queue <- worker.Job{
-		Targets:      []notifications.Target{{ID: id, RoomFrequency: notifications.Frequent, Follows: true}},
+		Targets:      []notifications.Target{{ID: id, RoomFrequency: notifications.Frequent, Follows: true, WelcomeRooms: true}},
 		Notification: &notification,
 	}
 
 	<-pool
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestWorker_WithUnregistered(t *testing.T) {
@@ -110,11 +221,12 @@ func TestWorker_WithUnregistered(t *testing.T) {
 	pool := make(chan chan worker.Job)
 	w := worker.NewWorker(
 		pool,
+		worker.NewQueue(rdb, "test"),
 		&worker.Config{
 			APNS:      apns,
 			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
 			Devices:   devices.NewBackend(db),
-			Store:     notifications.NewStorage(rdb),
+			Store:     notifications.NewStorage(db),
 			Analytics: analytics.NewBackend(db),
 		},
 	)
@@ -135,7 +247,12 @@ func TestWorker_WithUnregistered(t *testing.T) {
 	mock.
 		ExpectPrepare("^SELECT (.+)").
 		ExpectQuery().
-		WillReturnRows(mock.NewRows([]string{"token"}).FromCSVString(device))
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 2))
 
 	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).Return(notifications.ErrDeviceUnregistered)
 
@@ -156,3 +273,688 @@ func TestWorker_WithUnregistered(t *testing.T) {
 
 	<-pool
 }
+
+func TestWorker_WithExpiredWebPushSubscription(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+	webpush := mocks.NewMockWebPush(ctrl)
+
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			WebPush:   webpush,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+			Templates: registry,
+		},
+	)
+
+	id := 1
+	device := "1234"
+	subscription := devices.WebPushSubscription{Endpoint: "https://push.example.com/1", P256dh: "key", Auth: "auth", UserID: id, Locale: "de"}
+	notification := notifications.PushNotification{
+		Category:  notifications.ROOM_JOINED,
+		Alert:     notifications.Alert{Key: "join_room_with_1_notification", Arguments: []string{"foo"}},
+		Arguments: map[string]interface{}{"creator": 1, "id": "123"},
+	}
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WithArgs(id).
+		WillReturnRows(mock.NewRows([]string{"room"}).FromCSVString("0"))
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 2))
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"endpoint", "p256dh", "auth", "user_id"}).AddRow(subscription.Endpoint, subscription.P256dh, subscription.Auth, subscription.UserID))
+
+	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).Return(nil)
+
+	webpush.EXPECT().Send(gomock.Eq(subscription), gomock.Any()).DoAndReturn(
+		func(_ devices.WebPushSubscription, n notifications.PushNotification) error {
+			expected := "foo spricht in einem Raum, komm doch dazu!"
+			if n.Alert.Body != expected {
+				t.Errorf("expected body %s actual %s", expected, n.Alert.Body)
+			}
+
+			return notifications.ErrSubscriptionExpired
+		},
+	)
+
+	mock.
+		ExpectPrepare("^DELETE (.+)").
+		ExpectExec().
+		WithArgs(subscription.Endpoint).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.
+		ExpectPrepare("^INSERT INTO notifications (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Targets:      []notifications.Target{{ID: id, RoomFrequency: notifications.Frequent, Follows: true, Locale: "de"}},
+		Notification: &notification,
+	}
+
+	<-pool
+}
+
+func TestWorker_WithRetryRequired(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	id := 1
+	device := "1234"
+	notification := notifications.PushNotification{
+		Category:  notifications.ROOM_JOINED,
+		Arguments: map[string]interface{}{"creator": 1, "id": "123"},
+	}
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WithArgs(id).
+		WillReturnRows(mock.NewRows([]string{"room"}).FromCSVString("0"))
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 2))
+
+	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).Return(notifications.ErrRetryRequired)
+
+	mock.
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.
+		ExpectPrepare("^INSERT INTO notifications (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Targets:      []notifications.Target{{ID: id, RoomFrequency: notifications.Frequent, Follows: true}},
+		Notification: &notification,
+	}
+
+	<-pool
+
+	retries, err := mr.ZMembers("notifications_jobs_retries")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(retries) != 1 {
+		t.Fatalf("expected 1 retry actual %d", len(retries))
+	}
+}
+
+func TestWorker_DefersDuringQuietHours(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	// quiet hours from an hour ago until an hour from now.
+	now := time.Now().UTC()
+	minute := now.Hour()*60 + now.Minute()
+	quietHours := notifications.QuietHours{Enabled: true, Start: (minute + 23*60) % (24 * 60), End: (minute + 60) % (24 * 60)}
+
+	notification := notifications.PushNotification{
+		Category:  notifications.NEW_FOLLOWER,
+		Arguments: map[string]interface{}{"id": 12},
+	}
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Targets:      []notifications.Target{{ID: 1, Follows: true, Timezone: "UTC", QuietHours: quietHours}},
+		Notification: &notification,
+	}
+
+	<-pool
+
+	deferred, err := mr.ZMembers("notifications_jobs_retries")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deferred) != 1 {
+		t.Fatalf("expected 1 deferred job actual %d", len(deferred))
+	}
+}
+
+func TestWorker_DigestsFollowers(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+	limiter := notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db))
+	digests := notifications.NewDigests(rdb)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   limiter,
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Digests:   digests,
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	target := notifications.Target{ID: 1, Follows: true}
+
+	// the target was just sent a follower notification.
+	limiter.SentNotification(target, &notifications.PushNotification{Category: notifications.NEW_FOLLOWER, Arguments: map[string]interface{}{"id": 2}})
+
+	notification := notifications.PushNotification{
+		Category:  notifications.NEW_FOLLOWER,
+		Alert:     notifications.Alert{Key: "new_follower_notification", Arguments: []string{"bob"}},
+		Arguments: map[string]interface{}{"id": 3},
+	}
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Targets:      []notifications.Target{target},
+		Notification: &notification,
+	}
+
+	<-pool
+
+	res, err := digests.Due(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || len(res[0].Actors) != 1 || res[0].Actors[0].ID != 3 {
+		t.Fatalf("unexpected digests %v", res)
+	}
+}
+
+func TestWorker_WithPushDisabled(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	notification := notifications.PushNotification{
+		Category:  notifications.NEW_FOLLOWER,
+		Arguments: map[string]interface{}{"id": 12},
+	}
+
+	// only the inbox entry is stored, no devices are looked up.
+	mock.
+		ExpectPrepare("^INSERT INTO notifications (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Origin: 12,
+		Targets: []notifications.Target{
+			{ID: 1, Follows: true, Preferences: notifications.Preferences{notifications.NEW_FOLLOWER: {Inbox: true}}},
+		},
+		Notification: &notification,
+	}
+
+	<-pool
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorker_WithEmail(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+	email := mocks.NewMockEmail(ctrl)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Email:     email,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	target := notifications.Target{ID: 1, Preferences: notifications.Preferences{notifications.INFO: {Email: true}}}
+	notification := notifications.PushNotification{
+		Category: notifications.INFO,
+		Alert:    notifications.Alert{Body: "hello"},
+	}
+
+	// no devices are looked up for email only targets.
+	email.EXPECT().Send(gomock.Eq(target), gomock.Any()).Return(notifications.ErrRetryRequired)
+
+	mock.
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{
+		Targets:      []notifications.Target{target},
+		Notification: &notification,
+	}
+
+	<-pool
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retries, err := mr.ZMembers("notifications_jobs_retries")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(retries) != 1 {
+		t.Fatalf("expected 1 retry actual %d", len(retries))
+	}
+}
+
+func TestWorker_RendersVariants(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+
+	registry, err := templates.NewRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+			Templates: registry,
+		},
+	)
+
+	id := 1
+	device := "1234"
+
+	notification := notifications.NewRoomJoinedNotification("123", 2, "", []string{"foo"}, 1)
+	notification.Variants = []notifications.Variant{
+		{Name: "short", Key: "join_room_with_1_short_notification", Weight: 1, Rendered: true},
+	}
+
+	targets := []notifications.Target{{
+		ID:            id,
+		RoomFrequency: notifications.Frequent,
+		Follows:       true,
+		Locale:        "de",
+		Preferences:   notifications.Preferences{notifications.ROOM_JOINED: {Push: true}},
+	}}
+
+	groups := notifications.SplitVariants(notification, targets)
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WithArgs(id).
+		WillReturnRows(mock.NewRows([]string{"room"}).FromCSVString("0"))
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 0))
+
+	// the app does not ship the variant key, so the alert is pushed as text in the locale of the target.
+	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).DoAndReturn(func(_ string, n notifications.PushNotification) error {
+		data, err := apple.NewAPNS("com.example", "", nil).Payload(n)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+
+		payload := make(map[string]map[string]interface{})
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			t.Error(err)
+			return nil
+		}
+
+		expected := map[string]interface{}{"body": "foo ist live, hör doch rein"}
+		if !reflect.DeepEqual(payload["aps"]["alert"], expected) {
+			t.Errorf("expected %v actual %v", expected, payload["aps"]["alert"])
+		}
+
+		return nil
+	})
+
+	mock.
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	queue <- worker.Job{Targets: groups[0].Targets, Notification: groups[0].Notification}
+
+	<-pool
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorker_StoresRoomInvites(t *testing.T) {
+	notification := notifications.NewRoomInviteNotification("123", "bob")
+	testStoresInInbox(t, worker.Job{Origin: 7, Notification: notification}, 7)
+}
+
+func TestWorker_StoresStories(t *testing.T) {
+	notification := notifications.NewStoryNotification(7, "bob")
+	testStoresInInbox(t, worker.Job{Origin: 7, Notification: notification}, 7)
+}
+
+func TestWorker_StoresStoryReactions(t *testing.T) {
+	notification := notifications.NewStoryReactionNotification("123", 7, "bob", "👍")
+	testStoresInInbox(t, worker.Job{Origin: 7, Notification: notification}, 7)
+}
+
+// testStoresInInbox sends the job to a target with default preferences, and expects it to be stored in their inbox.
+func testStoresInInbox(t *testing.T, job worker.Job, from int) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	apns := mocks.NewMockAPNS(ctrl)
+
+	pool := make(chan chan worker.Job)
+	w := worker.NewWorker(
+		pool,
+		worker.NewQueue(rdb, "test"),
+		&worker.Config{
+			APNS:      apns,
+			Limiter:   notifications.NewLimiter(rdb, rooms.NewCurrentRoomBackend(db)),
+			Devices:   devices.NewBackend(db),
+			Store:     notifications.NewStorage(db),
+			Analytics: analytics.NewBackend(db),
+		},
+	)
+
+	id := 1
+	device := "1234"
+
+	mock.
+		ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"token", "user_id"}).AddRow(device, id))
+
+	mock.
+		ExpectPrepare("^SELECT user_id, COUNT(.+)").
+		ExpectQuery().
+		WillReturnRows(mock.NewRows([]string{"user_id", "count"}).AddRow(id, 0))
+
+	apns.EXPECT().Send(gomock.Eq(device), gomock.Any()).Return(nil)
+
+	mock.
+		ExpectPrepare("^INSERT INTO notification_analytics (.+)").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.
+		ExpectPrepare("^INSERT INTO notifications (.+)").
+		ExpectExec().
+		WithArgs(id, sql.NullInt64{Int64: int64(from), Valid: true}, string(job.Notification.Category), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w.Start()
+
+	queue := <-pool
+
+	job.Targets = []notifications.Target{{ID: id, RoomFrequency: notifications.Frequent, Follows: true}}
+	queue <- job
+
+	<-pool
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
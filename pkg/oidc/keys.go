@@ -0,0 +1,160 @@
+package oidc
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultKeysExpiration is how long keys are cached when the provider does not send a max-age.
+	defaultKeysExpiration = time.Hour
+
+	// minRefreshInterval limits how often keys are fetched when a token is signed with an unknown key.
+	minRefreshInterval = time.Minute
+)
+
+var ErrUnknownKey = errors.New("unknown signing key")
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// KeySet caches the signing keys a provider publishes as a JWKS.
+type KeySet struct {
+	sync.Mutex
+
+	client *http.Client
+	url    string
+
+	keys    map[string]*rsa.PublicKey
+	expires time.Time
+	fetched time.Time
+}
+
+func NewKeySet(client *http.Client, url string) *KeySet {
+	return &KeySet{
+		client: client,
+		url:    url,
+		keys:   make(map[string]*rsa.PublicKey),
+	}
+}
+
+// Key returns the key with the ID, keys are fetched again once they expire or when the ID is unknown.
+func (k *KeySet) Key(id string) (*rsa.PublicKey, error) {
+	k.Lock()
+	defer k.Unlock()
+
+	now := time.Now()
+
+	key, ok := k.keys[id]
+	if ok && now.Before(k.expires) {
+		return key, nil
+	}
+
+	// keys are rotated by the provider, an unknown key can mean new keys were published.
+	if !now.Before(k.expires) || now.Sub(k.fetched) >= minRefreshInterval {
+		err := k.fetch(now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key, ok = k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return key, nil
+}
+
+func (k *KeySet) fetch(now time.Time) error {
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch keys: %d", resp.StatusCode)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue
+		}
+
+		pub, err := parseKey(key)
+		if err != nil {
+			return err
+		}
+
+		keys[key.Kid] = pub
+	}
+
+	k.keys = keys
+	k.fetched = now
+	k.expires = now.Add(maxAge(resp.Header.Get("Cache-Control")))
+
+	return nil
+}
+
+func parseKey(key jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid exponent for key %s", key.Kid)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+// maxAge returns how long keys can be cached according to the Cache-Control header.
+func maxAge(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(directive, "max-age=") {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+		if err != nil || seconds <= 0 {
+			break
+		}
+
+		return time.Duration(seconds) * time.Second
+	}
+
+	return defaultKeysExpiration
+}
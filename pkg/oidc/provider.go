@@ -0,0 +1,211 @@
+// Package oidc validates ID tokens issued by OpenID Connect login providers.
+package oidc
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	Google = "google"
+
+	GoogleJWKS = "https://www.googleapis.com/oauth2/v3/certs"
+)
+
+// googleIssuers are the issuers of google ID tokens, both forms are used.
+var googleIssuers = []string{"https://accounts.google.com", "accounts.google.com"}
+
+// leeway allows for clock skew when validating the token lifetime.
+const leeway = time.Minute
+
+var (
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrInvalidIssuer    = errors.New("invalid issuer")
+	ErrInvalidAudience  = errors.New("invalid audience")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrEmailNotVerified = errors.New("email not verified")
+)
+
+// Identity is the user a provider authenticated.
+type Identity struct {
+	Provider string
+	Subject  string
+	Email    string
+}
+
+// Validator defines an interface for validating tokens of a login provider.
+type Validator interface {
+	Validate(token string) (*Identity, error)
+}
+
+// Config describes a provider, tokens must be issued by one of the issuers for one of the audiences.
+type Config struct {
+	Name      string
+	Issuers   []string
+	Audiences []string
+	JWKS      string
+}
+
+// Provider validates ID tokens against the keys the provider publishes.
+type Provider struct {
+	config Config
+	keys   *KeySet
+}
+
+func NewProvider(client *http.Client, config Config) *Provider {
+	return &Provider{
+		config: config,
+		keys:   NewKeySet(client, config.JWKS),
+	}
+}
+
+// NewGoogleProvider returns a provider for google sign in, the client IDs are those of our apps.
+func NewGoogleProvider(client *http.Client, clientIDs []string) *Provider {
+	return NewProvider(client, Config{
+		Name:      Google,
+		Issuers:   googleIssuers,
+		Audiences: clientIDs,
+		JWKS:      GoogleJWKS,
+	})
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	Expires       int64    `json:"exp"`
+	Email         string   `json:"email"`
+	EmailVerified verified `json:"email_verified"`
+}
+
+// audience is either a single audience or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	err := json.Unmarshal(data, &list)
+	if err != nil {
+		return err
+	}
+
+	*a = list
+	return nil
+}
+
+// verified is sent as a boolean or a string by some providers.
+type verified bool
+
+func (v *verified) UnmarshalJSON(data []byte) error {
+	*v = verified(strings.Trim(string(data), `"`) == "true")
+	return nil
+}
+
+// Validate verifies the signature and claims of an ID token and returns the identity it was issued for.
+func (p *Provider) Validate(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	h := &header{}
+	err := decode(parts[0], h)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if h.Alg != "RS256" {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := p.keys.Key(h.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	c := &claims{}
+	err = decode(parts[1], c)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if !contains(p.config.Issuers, c.Issuer) {
+		return nil, ErrInvalidIssuer
+	}
+
+	if !containsAny(p.config.Audiences, c.Audience) {
+		return nil, ErrInvalidAudience
+	}
+
+	if time.Now().Add(-leeway).After(time.Unix(c.Expires, 0)) {
+		return nil, ErrTokenExpired
+	}
+
+	if c.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	if c.Email == "" || !c.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	return &Identity{Provider: p.config.Name, Subject: c.Subject, Email: strings.ToLower(c.Email)}, nil
+}
+
+func decode(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsAny(values []string, others []string) bool {
+	for _, v := range others {
+		if contains(values, v) {
+			return true
+		}
+	}
+
+	return false
+}
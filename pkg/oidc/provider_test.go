@@ -0,0 +1,178 @@
+package oidc_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/oidc"
+)
+
+type jwksStub struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	kid      string
+	requests int32
+}
+
+func newJWKSStub(t *testing.T) *jwksStub {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &jwksStub{key: key, kid: "key-1"}
+	stub.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&stub.requests, 1)
+
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{
+				{
+					"kid": stub.kid,
+					"kty": "RSA",
+					"alg": "RS256",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	}))
+
+	t.Cleanup(stub.server.Close)
+
+	return stub
+}
+
+func (s *jwksStub) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (s *jwksStub) provider() *oidc.Provider {
+	return oidc.NewProvider(s.server.Client(), oidc.Config{
+		Name:      oidc.Google,
+		Issuers:   []string{"https://accounts.google.com", "accounts.google.com"},
+		Audiences: []string{"client"},
+		JWKS:      s.server.URL,
+	})
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"iss":            "https://accounts.google.com",
+		"aud":            "client",
+		"sub":            "1234",
+		"email":          "Foo@example.com",
+		"email_verified": true,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestProvider_Validate(t *testing.T) {
+	stub := newJWKSStub(t)
+	provider := stub.provider()
+
+	identity, err := provider.Validate(stub.sign(t, stub.kid, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &oidc.Identity{Provider: oidc.Google, Subject: "1234", Email: "foo@example.com"}
+	if !reflect.DeepEqual(identity, expected) {
+		t.Fatalf("expected %v actual %v", expected, identity)
+	}
+
+	_, err = provider.Validate(stub.sign(t, stub.kid, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stub.requests != 1 {
+		t.Fatalf("expected keys to be fetched once, fetched %d times", stub.requests)
+	}
+}
+
+func TestProvider_ValidateErrors(t *testing.T) {
+	stub := newJWKSStub(t)
+
+	tests := []struct {
+		name   string
+		modify func(map[string]interface{})
+		kid    string
+		err    error
+	}{
+		{name: "issuer", modify: func(c map[string]interface{}) { c["iss"] = "https://example.com" }, err: oidc.ErrInvalidIssuer},
+		{name: "audience", modify: func(c map[string]interface{}) { c["aud"] = []string{"other"} }, err: oidc.ErrInvalidAudience},
+		{name: "expired", modify: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, err: oidc.ErrTokenExpired},
+		{name: "unverified", modify: func(c map[string]interface{}) { c["email_verified"] = "false" }, err: oidc.ErrEmailNotVerified},
+		{name: "unknown key", modify: func(c map[string]interface{}) {}, kid: "key-2", err: oidc.ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.modify(claims)
+
+			kid := stub.kid
+			if tt.kid != "" {
+				kid = tt.kid
+			}
+
+			_, err := stub.provider().Validate(stub.sign(t, kid, claims))
+			if err != tt.err {
+				t.Fatalf("expected %v actual %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestProvider_ValidateSignature(t *testing.T) {
+	stub := newJWKSStub(t)
+
+	token := stub.sign(t, stub.kid, validClaims())
+
+	claims := validClaims()
+	claims["sub"] = "5678"
+
+	parts := strings.Split(token, ".")
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	_, err = stub.provider().Validate(tampered)
+	if err != oidc.ErrInvalidSignature {
+		t.Fatalf("expected %v actual %v", oidc.ErrInvalidSignature, err)
+	}
+}
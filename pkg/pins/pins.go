@@ -0,0 +1,25 @@
+// Package pins generates the pins users verify their email or phone with.
+package pins
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// length is the amount of digits in a pin.
+const length = 6
+
+// Generate returns a random pin, every digit is equally likely.
+func Generate() (string, error) {
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			return "", err
+		}
+
+		b[i] = '0' + byte(n.Int64())
+	}
+
+	return string(b), nil
+}
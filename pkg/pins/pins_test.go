@@ -0,0 +1,19 @@
+package pins_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/pins"
+)
+
+func TestGenerate(t *testing.T) {
+	pin, err := pins.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !regexp.MustCompile(`^[0-9]{6}$`).MatchString(pin) {
+		t.Fatalf("unexpected pin %s", pin)
+	}
+}
@@ -23,6 +23,7 @@ const (
 	EventTypeRoomOpenMini
 	EventTypeDeleteUser
 	EventTypeFollowRecommendations
+	EventTypeEmailChanged
 )
 
 type RoomVisibility string
@@ -81,17 +82,17 @@ func NewRoomJoinEvent(id string, creator int, visibility RoomVisibility) Event {
 	}
 }
 
-func NewStoryCreationEvent(creator int) Event {
+func NewStoryCreationEvent(creator int, story string) Event {
 	return Event{
 		Type:   EventTypeNewStory,
-		Params: map[string]interface{}{"creator": creator},
+		Params: map[string]interface{}{"creator": creator, "story": story},
 	}
 }
 
-func NewStoryReactionEvent(user int) Event {
+func NewStoryReactionEvent(user int, story, reaction string) Event {
 	return Event{
 		Type:   EventTypeStoryReaction,
-		Params: map[string]interface{}{"id": user},
+		Params: map[string]interface{}{"id": user, "story": story, "reaction": reaction},
 	}
 }
 
@@ -150,3 +151,11 @@ func NewFollowRecommendationsEvent(user int) Event {
 		Params: map[string]interface{}{"id": user},
 	}
 }
+
+// NewEmailChangedEvent is published when the email of a user changes, previous and email are equal when logging in with the email was enabled.
+func NewEmailChangedEvent(user int, previous, email string) Event {
+	return Event{
+		Type:   EventTypeEmailChanged,
+		Params: map[string]interface{}{"id": user, "previous": previous, "email": email},
+	}
+}
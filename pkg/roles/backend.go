@@ -0,0 +1,84 @@
+package roles
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidRole is returned when assigning a role that does not exist.
+var ErrInvalidRole = errors.New("invalid role")
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+func (b *Backend) GetRolesFor(user int) ([]Role, error) {
+	stmt, err := b.db.Prepare("SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role;")
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := stmt.Query(user)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]Role, 0)
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// HasPermission returns whether any of the users roles grants the permission.
+func (b *Backend) HasPermission(user int, permission Permission) (bool, error) {
+	stmt, err := b.db.Prepare("SELECT COUNT(*) FROM user_roles WHERE user_id = $1 AND role = ANY($2);")
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	err = stmt.QueryRow(user, pq.Array(Names(WithPermission(permission)))).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (b *Backend) AddRole(user int, role Role) error {
+	if !IsValid(role) {
+		return ErrInvalidRole
+	}
+
+	stmt, err := b.db.Prepare("INSERT INTO user_roles (user_id, role) VALUES ($1, $2) ON CONFLICT DO NOTHING;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user, role)
+	return err
+}
+
+func (b *Backend) RemoveRole(user int, role Role) error {
+	stmt, err := b.db.Prepare("DELETE FROM user_roles WHERE user_id = $1 AND role = $2;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user, role)
+	return err
+}
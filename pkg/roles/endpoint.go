@@ -0,0 +1,97 @@
+package roles
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+)
+
+// Endpoint manages the roles of users, it should only be mounted behind a check for PermissionAdmin.
+type Endpoint struct {
+	backend *Backend
+}
+
+func NewEndpoint(backend *Backend) *Endpoint {
+	return &Endpoint{
+		backend: backend,
+	}
+}
+
+func (e *Endpoint) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/{id:[0-9]+}", e.getRoles).Methods("GET")
+	r.HandleFunc("/{id:[0-9]+}", e.addRole).Methods("POST")
+	r.HandleFunc("/{id:[0-9]+}/{role}", e.removeRole).Methods("DELETE")
+
+	return r
+}
+
+func (e *Endpoint) getRoles(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	roles, err := e.backend.GetRolesFor(id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to get roles")
+		return
+	}
+
+	err = httputil.JsonEncode(w, roles)
+	if err != nil {
+		log.Printf("failed to write roles response: %s\n", err.Error())
+	}
+}
+
+func (e *Endpoint) addRole(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	role := Role(r.Form.Get("role"))
+	if !IsValid(role) {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid role")
+		return
+	}
+
+	err = e.backend.AddRole(id, role)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to add role")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (e *Endpoint) removeRole(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = e.backend.RemoveRole(id, Role(params["role"]))
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to remove role")
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
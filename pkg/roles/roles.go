@@ -0,0 +1,92 @@
+// Package roles assigns users roles that grant them permissions, replacing checks for specific user IDs.
+package roles
+
+import "sort"
+
+// Role is a set of permissions granted to a user.
+type Role string
+
+const (
+	Staff     Role = "staff"
+	Tester    Role = "tester"
+	Moderator Role = "moderator"
+	Greeter   Role = "greeter"
+)
+
+// Permission is an action that is restricted to users with specific roles.
+type Permission string
+
+const (
+	// PermissionAdmin allows using admin endpoints.
+	PermissionAdmin Permission = "admin"
+
+	// PermissionModerate allows moderating users and rooms.
+	PermissionModerate Permission = "moderate"
+
+	// PermissionReceiveTestNotifications allows receiving notifications in the TEST category.
+	PermissionReceiveTestNotifications Permission = "receive_test_notifications"
+
+	// PermissionGreet receives every welcome room notification, regardless of preferences and limits.
+	PermissionGreet Permission = "greet"
+)
+
+var permissions = map[Role][]Permission{
+	Staff:     {PermissionAdmin, PermissionModerate, PermissionReceiveTestNotifications},
+	Tester:    {PermissionReceiveTestNotifications},
+	Moderator: {PermissionModerate},
+	Greeter:   {PermissionGreet},
+}
+
+// IsValid returns whether a role exists.
+func IsValid(role Role) bool {
+	_, ok := permissions[role]
+	return ok
+}
+
+// Can returns whether a role grants a permission.
+func (r Role) Can(permission Permission) bool {
+	for _, p := range permissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Can returns whether any of the roles grants a permission.
+func Can(roles []Role, permission Permission) bool {
+	for _, role := range roles {
+		if role.Can(permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithPermission returns all roles that grant a permission.
+func WithPermission(permission Permission) []Role {
+	res := make([]Role, 0)
+	for role := range permissions {
+		if role.Can(permission) {
+			res = append(res, role)
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i] < res[j]
+	})
+
+	return res
+}
+
+// Names returns the roles as strings, for use as query parameters.
+func Names(roles []Role) []string {
+	res := make([]string, 0, len(roles))
+	for _, role := range roles {
+		res = append(res, string(role))
+	}
+
+	return res
+}
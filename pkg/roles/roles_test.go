@@ -0,0 +1,40 @@
+package roles_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/roles"
+)
+
+func TestCan(t *testing.T) {
+	tests := []struct {
+		roles      []roles.Role
+		permission roles.Permission
+		expected   bool
+	}{
+		{[]roles.Role{roles.Staff}, roles.PermissionAdmin, true},
+		{[]roles.Role{roles.Staff}, roles.PermissionGreet, false},
+		{[]roles.Role{roles.Tester}, roles.PermissionReceiveTestNotifications, true},
+		{[]roles.Role{roles.Moderator, roles.Greeter}, roles.PermissionGreet, true},
+		{[]roles.Role{roles.Moderator}, roles.PermissionAdmin, false},
+		{nil, roles.PermissionReceiveTestNotifications, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.permission), func(t *testing.T) {
+			if roles.Can(tt.roles, tt.permission) != tt.expected {
+				t.Fatalf("expected %v for %v", tt.expected, tt.roles)
+			}
+		})
+	}
+}
+
+func TestWithPermission(t *testing.T) {
+	expected := []roles.Role{roles.Staff, roles.Tester}
+
+	actual := roles.WithPermission(roles.PermissionReceiveTestNotifications)
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v actual %v", expected, actual)
+	}
+}
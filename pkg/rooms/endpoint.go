@@ -6,7 +6,9 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
 	"github.com/soapboxsocial/soapbox/pkg/rooms/pb"
 )
 
@@ -39,10 +41,13 @@ func NewEndpoint(repository *Repository, server *Server, auth *Auth) *Endpoint {
 
 func (e *Endpoint) Router() *mux.Router {
 	r := mux.NewRouter()
+	scopes := middlewares.NewScopes()
 
-	r.HandleFunc("/v1/rooms", e.rooms).Methods("GET")
-	r.HandleFunc("/v1/rooms/{id}", e.room).Methods("GET")
-	r.HandleFunc("/v1/signal", e.server.Signal).Methods("GET")
+	scopes.Require(r.HandleFunc("/v1/rooms", e.rooms).Methods("GET"), accesstokens.ScopeRoomsJoin)
+	scopes.Require(r.HandleFunc("/v1/rooms/{id}", e.room).Methods("GET"), accesstokens.ScopeRoomsJoin)
+	scopes.Require(r.HandleFunc("/v1/signal", e.server.Signal).Methods("GET"), accesstokens.ScopeRoomsJoin)
+
+	r.Use(scopes.Middleware)
 
 	return r
 }
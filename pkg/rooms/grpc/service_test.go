@@ -4,7 +4,7 @@ import (
 	"context"
 	"testing"
 
-	"github.com/alicebob/miniredis"
+	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
 
 	"github.com/soapboxsocial/soapbox/pkg/rooms"
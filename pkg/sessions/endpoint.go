@@ -0,0 +1,156 @@
+package sessions
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+)
+
+// maxLabelLength is the maximum amount of characters in a session label.
+const maxLabelLength = 64
+
+type Endpoint struct {
+	sm *SessionManager
+}
+
+func NewEndpoint(sm *SessionManager) *Endpoint {
+	return &Endpoint{sm: sm}
+}
+
+func (e *Endpoint) Router() *mux.Router {
+	r := mux.NewRouter()
+
+	r.HandleFunc("/", e.list).Methods("GET")
+	r.HandleFunc("/", e.closeOthers).Methods("DELETE")
+	r.HandleFunc("/logout", e.logout).Methods("POST")
+	r.HandleFunc("/{id:[a-f0-9]+}", e.label).Methods("POST")
+	r.HandleFunc("/{id:[a-f0-9]+}", e.close).Methods("DELETE")
+
+	return r
+}
+
+func (e *Endpoint) list(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	sessions, err := e.sm.Sessions(id)
+	if err != nil {
+		log.Printf("sessions.Sessions err: %v\n", err)
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to get sessions")
+		return
+	}
+
+	type session struct {
+		Session
+		Current bool `json:"current"`
+	}
+
+	_, current, err := e.sm.GetSessionForToken(r.Header.Get("Authorization"))
+	if err != nil {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
+		return
+	}
+
+	result := make([]session, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, session{Session: s, Current: s.ID == current})
+	}
+
+	err = httputil.JsonEncode(w, result)
+	if err != nil {
+		log.Printf("failed to write sessions response: %s\n", err.Error())
+	}
+}
+
+func (e *Endpoint) label(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	label := strings.TrimSpace(r.Form.Get("label"))
+	if len([]rune(label)) > maxLabelLength {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "label too long")
+		return
+	}
+
+	err = e.sm.LabelSession(id, mux.Vars(r)["id"], label)
+	if err != nil {
+		e.handleError(w, err)
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (e *Endpoint) close(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err := e.sm.CloseSessionByID(id, mux.Vars(r)["id"])
+	if err != nil {
+		e.handleError(w, err)
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (e *Endpoint) closeOthers(w http.ResponseWriter, r *http.Request) {
+	id, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	_, current, err := e.sm.GetSessionForToken(r.Header.Get("Authorization"))
+	if err != nil {
+		httputil.JsonError(w, http.StatusUnauthorized, httputil.ErrorCodeUnauthorized, "unauthorized")
+		return
+	}
+
+	err = e.sm.CloseOtherSessions(id, current)
+	if err != nil {
+		e.handleError(w, err)
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (e *Endpoint) logout(w http.ResponseWriter, r *http.Request) {
+	err := e.sm.CloseSession(r.Header.Get("Authorization"))
+	if err != nil {
+		e.handleError(w, err)
+		return
+	}
+
+	httputil.JsonSuccess(w)
+}
+
+func (e *Endpoint) handleError(w http.ResponseWriter, err error) {
+	if err == ErrSessionNotFound {
+		httputil.JsonError(w, http.StatusNotFound, httputil.ErrorCodeNotFound, "session not found")
+		return
+	}
+
+	log.Printf("sessions err: %v\n", err)
+	httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to update sessions")
+}
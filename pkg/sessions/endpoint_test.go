@@ -0,0 +1,162 @@
+package sessions_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/sessions"
+)
+
+func TestEndpoint_List(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, _ = sm.NewSession("foo", 1, sessions.Metadata{})
+	_, _ = sm.NewSession("bar", 1, sessions.Metadata{})
+
+	handler := sessions.NewEndpoint(sm).Router()
+
+	r, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+	req.Header.Set("Authorization", "foo")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var result []struct {
+		ID      string `json:"id"`
+		Current bool   `json:"current"`
+	}
+
+	err = json.Unmarshal(rr.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 sessions actual %d", len(result))
+	}
+
+	for _, s := range result {
+		if s.Current != (s.ID == sessionID(t, sm, "foo")) {
+			t.Fatalf("unexpected current session %v", s)
+		}
+	}
+}
+
+func TestEndpoint_Close(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, _ = sm.NewSession("foo", 1, sessions.Metadata{})
+	_, _ = sm.NewSession("bar", 1, sessions.Metadata{})
+
+	handler := sessions.NewEndpoint(sm).Router()
+
+	bar := sessionID(t, sm, "bar")
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/" + bar, http.StatusOK},
+		{"/" + bar, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		r, err := http.NewRequest("DELETE", tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+		req.Header.Set("Authorization", "foo")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != tt.status {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.status)
+		}
+	}
+}
+
+func TestEndpoint_Label(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, _ = sm.NewSession("foo", 1, sessions.Metadata{})
+
+	handler := sessions.NewEndpoint(sm).Router()
+
+	tests := []struct {
+		label  string
+		status int
+	}{
+		{"work phone", http.StatusOK},
+		{strings.Repeat("a", 65), http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		r, err := http.NewRequest("POST", "/"+sessionID(t, sm, "foo"), strings.NewReader("label="+tt.label))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if status := rr.Code; status != tt.status {
+			t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.status)
+		}
+	}
+}
+
+func TestEndpoint_Logout(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, _ = sm.NewSession("foo", 1, sessions.Metadata{})
+	_, _ = sm.NewSession("bar", 1, sessions.Metadata{})
+
+	handler := sessions.NewEndpoint(sm).Router()
+
+	r, err := http.NewRequest("POST", "/logout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+	req.Header.Set("Authorization", "foo")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	if _, err := sm.GetUserIDForSession("foo"); err == nil {
+		t.Fatal("expected session to be closed")
+	}
+
+	if id, _ := sm.GetUserIDForSession("bar"); id != 1 {
+		t.Fatal("expected other session to remain open")
+	}
+}
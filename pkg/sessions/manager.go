@@ -1,12 +1,71 @@
 package sessions
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+const (
+	// AccessExpiration is how long an access token is valid, clients refresh it with their refresh token.
+	AccessExpiration = time.Hour
+
+	// RefreshExpiration is how long a session stays logged in without being used.
+	RefreshExpiration = 30 * 24 * time.Hour
+)
+
+// lastSeenInterval is how often the last seen time of a session is updated.
+const lastSeenInterval = 5 * time.Minute
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+	// ErrRefreshTokenReused is returned when a refresh token that was already rotated is used, the session is closed.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Session describes where a user is logged in.
+// Sessions with refresh tokens keep their ID when the tokens are rotated, other sessions are identified by a hash of their token.
+type Session struct {
+	ID       string `json:"id"`
+	Label    string `json:"label,omitempty"`
+	Device   string `json:"device"`
+	Version  string `json:"version"`
+	IP       string `json:"ip"`
+	Created  int64  `json:"created"`
+	LastSeen int64  `json:"last_seen"`
+}
+
+// Tokens are the credentials of a session.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+// record is how a session is stored in the users sessions hash.
+type record struct {
+	Token   string `json:"token"`
+	Refresh string `json:"refresh,omitempty"`
+	Session
+}
+
+// refreshToken is stored for every refresh token until it expires, so reuse of rotated tokens is detected.
+type refreshToken struct {
+	User    int    `json:"user"`
+	Session string `json:"session"`
+}
+
 type SessionManager struct {
 	db *redis.Client
 }
@@ -15,24 +74,481 @@ func NewSessionManager(db *redis.Client) *SessionManager {
 	return &SessionManager{db: db}
 }
 
-func (sm *SessionManager) NewSession(id string, user int, expiration time.Duration) error {
-	return sm.db.Set(sm.db.Context(), generateSessionKey(id), user, expiration).Err()
+// NewSession creates a session using the access token, a refresh token is generated for it.
+func (sm *SessionManager) NewSession(access string, user int, metadata Metadata) (*Tokens, error) {
+	id, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+
+	r := record{
+		Token:   access,
+		Refresh: refresh,
+		Session: Session{
+			ID:       id,
+			Device:   metadata.Device,
+			Version:  metadata.Version,
+			IP:       metadata.IP,
+			Created:  now,
+			LastSeen: now,
+		},
+	}
+
+	err = sm.store(user, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: access, RefreshToken: refresh, ExpiresIn: AccessExpiration}, nil
+}
+
+// Refresh rotates the tokens of the session the refresh token belongs to.
+// Using a refresh token that was already rotated closes the session, as it was likely stolen.
+func (sm *SessionManager) Refresh(refresh string, metadata Metadata) (*Tokens, error) {
+	ctx := sm.db.Context()
+
+	data, err := sm.db.Get(ctx, generateRefreshTokenKey(refresh)).Result()
+	if err == redis.Nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := &refreshToken{}
+	err = json.Unmarshal([]byte(data), token)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := sm.record(token.User, token.Session)
+	if err == ErrSessionNotFound {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// only the first use of a refresh token succeeds.
+	first, err := sm.db.SetNX(ctx, generateUsedRefreshTokenKey(refresh), true, RefreshExpiration).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if !first || r.Refresh != refresh {
+		err = sm.CloseSessionByID(token.User, token.Session)
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	access, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = sm.db.Del(ctx, generateSessionKey(r.Token)).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	r.Token = access
+	r.Refresh = next
+	r.LastSeen = time.Now().Unix()
+	r.Device = metadata.Device
+	r.Version = metadata.Version
+	r.IP = metadata.IP
+
+	err = sm.store(token.User, *r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: access, RefreshToken: next, ExpiresIn: AccessExpiration}, nil
 }
 
 func (sm *SessionManager) GetUserIDForSession(id string) (int, error) {
-	str, err := sm.db.Get(sm.db.Context(), generateSessionKey(id)).Result()
+	user, _, err := sm.GetSessionForToken(id)
+	return user, err
+}
+
+// GetSessionForToken returns the user and the ID of the session an access token belongs to.
+func (sm *SessionManager) GetSessionForToken(token string) (int, string, error) {
+	str, err := sm.db.Get(sm.db.Context(), generateSessionKey(token)).Result()
+	if err != nil {
+		return 0, "", err
+	}
+
+	// sessions created before refresh tokens only store the user.
+	parts := strings.SplitN(str, ":", 2)
+
+	user, err := strconv.Atoi(parts[0])
 	if err != nil {
-		return 0, err
+		return 0, "", err
+	}
+
+	if len(parts) == 1 {
+		return user, IDForToken(token), nil
 	}
 
-	return strconv.Atoi(str)
+	return user, parts[1], nil
 }
 
-func (sm *SessionManager) CloseSession(id string) error {
-	_, err := sm.db.Del(sm.db.Context(), generateSessionKey(id)).Result()
+// Touch updates the last seen time and metadata of a session, sessions created before metadata was stored are recorded.
+// The record is only written if it was not changed in the meantime, so tokens stored by a concurrent refresh are never overwritten.
+func (sm *SessionManager) Touch(token string, user int, id string, metadata Metadata) error {
+	ctx := sm.db.Context()
+	key := generateUserSessionsKey(user)
+
+	err := sm.db.Watch(ctx, func(tx *redis.Tx) error {
+		current := &record{Token: token, Session: Session{ID: id}}
+
+		data, err := tx.HGet(ctx, key, id).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+
+		if data != "" {
+			err = json.Unmarshal([]byte(data), current)
+			if err != nil {
+				return err
+			}
+		} else {
+			// sessions without a record are only recorded while they are open.
+			exists, err := tx.Exists(ctx, generateSessionKey(token)).Result()
+			if err != nil {
+				return err
+			}
+
+			if exists == 0 {
+				return nil
+			}
+		}
+
+		now := time.Now()
+		if now.Sub(time.Unix(current.LastSeen, 0)) < lastSeenInterval && current.IP == metadata.IP && current.Version == metadata.Version {
+			return nil
+		}
+
+		current.LastSeen = now.Unix()
+		current.Device = metadata.Device
+		current.Version = metadata.Version
+		current.IP = metadata.IP
+
+		updated, err := json.Marshal(current)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, current.ID, string(updated))
+			return nil
+		})
+
+		return err
+	}, key, generateSessionKey(token))
+
+	// the session was changed by another request, the next request updates the metadata.
+	if err == redis.TxFailedErr {
+		return nil
+	}
+
 	return err
 }
 
+// Sessions returns the active sessions of a user, most recently seen first.
+func (sm *SessionManager) Sessions(user int) ([]Session, error) {
+	records, err := sm.records(user)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(records))
+	for _, r := range records {
+		sessions = append(sessions, r.Session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen > sessions[j].LastSeen
+	})
+
+	return sessions, nil
+}
+
+// LabelSession sets a name the user recognizes a session by.
+func (sm *SessionManager) LabelSession(user int, id, label string) error {
+	r, err := sm.record(user, id)
+	if err != nil {
+		return err
+	}
+
+	r.Label = label
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return sm.db.HSet(sm.db.Context(), generateUserSessionsKey(user), id, string(data)).Err()
+}
+
+// CloseSession logs out the session the access token belongs to.
+func (sm *SessionManager) CloseSession(token string) error {
+	user, id, err := sm.GetSessionForToken(token)
+	if err == redis.Nil {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = sm.CloseSessionByID(user, id)
+	if err == ErrSessionNotFound {
+		return sm.db.Del(sm.db.Context(), generateSessionKey(token)).Err()
+	}
+
+	return err
+}
+
+// CloseSessionByID logs out a session of the user.
+func (sm *SessionManager) CloseSessionByID(user int, id string) error {
+	r, err := sm.record(user, id)
+	if err != nil {
+		return err
+	}
+
+	return sm.close(user, []record{*r})
+}
+
+// CloseOtherSessions logs out all sessions of the user except the current session.
+func (sm *SessionManager) CloseOtherSessions(user int, current string) error {
+	records, err := sm.records(user)
+	if err != nil {
+		return err
+	}
+
+	others := make([]record, 0, len(records))
+	for _, r := range records {
+		if r.ID != current {
+			others = append(others, r)
+		}
+	}
+
+	return sm.close(user, others)
+}
+
+// CloseAllSessions logs out all sessions of the user.
+func (sm *SessionManager) CloseAllSessions(user int) error {
+	records, err := sm.records(user)
+	if err != nil {
+		return err
+	}
+
+	return sm.close(user, records)
+}
+
+// IndexLegacySessions records the sessions created before refresh tokens which were not used since, so closing all sessions of a user includes them.
+// It returns the amount of sessions that were recorded.
+func (sm *SessionManager) IndexLegacySessions() (int, error) {
+	ctx := sm.db.Context()
+
+	indexed := 0
+
+	var cursor uint64
+	for {
+		keys, next, err := sm.db.Scan(ctx, cursor, generateSessionKey("*"), 1000).Result()
+		if err != nil {
+			return indexed, err
+		}
+
+		for _, key := range keys {
+			value, err := sm.db.Get(ctx, key).Result()
+			if err == redis.Nil {
+				continue
+			}
+
+			if err != nil {
+				return indexed, err
+			}
+
+			// sessions with an ID are already recorded.
+			if strings.Contains(value, ":") {
+				continue
+			}
+
+			user, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+
+			token := strings.TrimPrefix(key, generateSessionKey(""))
+
+			data, err := json.Marshal(record{Token: token, Session: Session{ID: IDForToken(token)}})
+			if err != nil {
+				return indexed, err
+			}
+
+			added, err := sm.db.HSetNX(ctx, generateUserSessionsKey(user), IDForToken(token), string(data)).Result()
+			if err != nil {
+				return indexed, err
+			}
+
+			if added {
+				indexed++
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return indexed, nil
+		}
+	}
+}
+
+func (sm *SessionManager) close(user int, records []record) error {
+	ctx := sm.db.Context()
+
+	pipe := sm.db.TxPipeline()
+	for _, r := range records {
+		pipe.Del(ctx, generateSessionKey(r.Token))
+		if r.Refresh != "" {
+			pipe.Del(ctx, generateRefreshTokenKey(r.Refresh))
+		}
+
+		pipe.HDel(ctx, generateUserSessionsKey(user), r.ID)
+	}
+
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// store saves a session with its access and refresh token.
+func (sm *SessionManager) store(user int, r record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	token, err := json.Marshal(refreshToken{User: user, Session: r.ID})
+	if err != nil {
+		return err
+	}
+
+	ctx := sm.db.Context()
+
+	pipe := sm.db.TxPipeline()
+	pipe.Set(ctx, generateSessionKey(r.Token), fmt.Sprintf("%d:%s", user, r.ID), AccessExpiration)
+	pipe.Set(ctx, generateRefreshTokenKey(r.Refresh), string(token), RefreshExpiration)
+	pipe.HSet(ctx, generateUserSessionsKey(user), r.ID, string(data))
+	_, err = pipe.Exec(ctx)
+
+	return err
+}
+
+func (sm *SessionManager) record(user int, id string) (*record, error) {
+	data, err := sm.db.HGet(sm.db.Context(), generateUserSessionsKey(user), id).Result()
+	if err == redis.Nil {
+		return nil, ErrSessionNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &record{}
+	err = json.Unmarshal([]byte(data), r)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// records returns the stored sessions of a user, sessions that expired are removed.
+func (sm *SessionManager) records(user int) ([]record, error) {
+	ctx := sm.db.Context()
+	key := generateUserSessionsKey(user)
+
+	values, err := sm.db.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]record, 0, len(values))
+	for id, data := range values {
+		r := record{}
+		err := json.Unmarshal([]byte(data), &r)
+		if err != nil {
+			continue
+		}
+
+		// sessions with refresh tokens remain while the access token is expired.
+		active := generateSessionKey(r.Token)
+		if r.Refresh != "" {
+			active = generateRefreshTokenKey(r.Refresh)
+		}
+
+		exists, err := sm.db.Exists(ctx, active).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		if exists == 0 {
+			sm.db.HDel(ctx, key, id)
+			continue
+		}
+
+		records = append(records, r)
+	}
+
+	return records, nil
+}
+
+// IDForToken returns the ID of a session created before refresh tokens from its token.
+func IDForToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
 func generateSessionKey(id string) string {
 	return "session_" + id
 }
+
+func generateRefreshTokenKey(token string) string {
+	return "refresh_token_" + token
+}
+
+func generateUsedRefreshTokenKey(token string) string {
+	return "refresh_token_used_" + token
+}
+
+func generateUserSessionsKey(user int) string {
+	return fmt.Sprintf("user_sessions_%d", user)
+}
@@ -0,0 +1,419 @@
+package sessions_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/sessions"
+)
+
+func newSessionManager(t *testing.T) (*sessions.SessionManager, *miniredis.Miniredis) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	return sessions.NewSessionManager(rdb), mr
+}
+
+func sessionID(t *testing.T, sm *sessions.SessionManager, token string) string {
+	_, id, err := sm.GetSessionForToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+func TestSessionManager_Sessions(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, err := sm.NewSession("foo", 1, sessions.Metadata{Device: "iPhone", Version: "1.2", IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bar, err := sm.NewSession("bar", 1, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 sessions actual %d", len(result))
+	}
+
+	foo := sessionID(t, sm, "foo")
+	for _, s := range result {
+		if s.ID == foo && (s.Device != "iPhone" || s.Version != "1.2" || s.IP != "127.0.0.1") {
+			t.Fatalf("unexpected metadata %v", s)
+		}
+	}
+
+	// sessions are listed until their refresh token expires.
+	mr.Del("session_bar")
+
+	result, err = sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 sessions actual %d", len(result))
+	}
+
+	mr.Del("refresh_token_" + bar.RefreshToken)
+
+	result, err = sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0].ID != foo {
+		t.Fatalf("unexpected sessions %v", result)
+	}
+}
+
+func TestSessionManager_LegacySession(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	// sessions created before refresh tokens only store the user, they are recorded when they are used.
+	err := mr.Set("session_foo", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, id, err := sm.GetSessionForToken("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user != 1 || id != sessions.IDForToken("foo") {
+		t.Fatalf("unexpected session %d %s", user, id)
+	}
+
+	err = sm.Touch("foo", user, id, sessions.Metadata{Device: "iPhone", Version: "1.3", IP: "127.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0].Version != "1.3" || result[0].IP != "127.0.0.2" || result[0].LastSeen == 0 {
+		t.Fatalf("unexpected sessions %v", result)
+	}
+
+	err = sm.CloseAllSessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm.GetUserIDForSession("foo"); err == nil {
+		t.Fatal("expected session to be closed")
+	}
+}
+
+func TestSessionManager_Refresh(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	tokens, err := sm.NewSession("foo", 1, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tokens.AccessToken != "foo" || tokens.RefreshToken == "" || tokens.ExpiresIn != sessions.AccessExpiration {
+		t.Fatalf("unexpected tokens %v", tokens)
+	}
+
+	if ttl := mr.TTL("session_foo"); ttl != sessions.AccessExpiration {
+		t.Fatalf("unexpected access token expiration %v", ttl)
+	}
+
+	id := sessionID(t, sm, "foo")
+
+	rotated, err := sm.Refresh(tokens.RefreshToken, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.AccessToken == tokens.AccessToken || rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatalf("expected tokens to be rotated %v", rotated)
+	}
+
+	if _, err := sm.GetUserIDForSession("foo"); err == nil {
+		t.Fatal("expected previous access token to be invalid")
+	}
+
+	// the session keeps its ID when tokens are rotated.
+	if sessionID(t, sm, rotated.AccessToken) != id {
+		t.Fatal("expected session id to be kept")
+	}
+
+	_, err = sm.Refresh("invalid", sessions.Metadata{})
+	if err != sessions.ErrInvalidRefreshToken {
+		t.Fatalf("expected %v actual %v", sessions.ErrInvalidRefreshToken, err)
+	}
+}
+
+func TestSessionManager_Refresh_Reuse(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	tokens, err := sm.NewSession("foo", 1, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := sm.Refresh(tokens.RefreshToken, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sm.Refresh(tokens.RefreshToken, sessions.Metadata{})
+	if err != sessions.ErrRefreshTokenReused {
+		t.Fatalf("expected %v actual %v", sessions.ErrRefreshTokenReused, err)
+	}
+
+	// reuse closes the whole session.
+	if _, err := sm.GetUserIDForSession(rotated.AccessToken); err == nil {
+		t.Fatal("expected session to be closed")
+	}
+
+	_, err = sm.Refresh(rotated.RefreshToken, sessions.Metadata{})
+	if err != sessions.ErrInvalidRefreshToken {
+		t.Fatalf("expected %v actual %v", sessions.ErrInvalidRefreshToken, err)
+	}
+}
+
+func TestSessionManager_Touch(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, err := sm.NewSession("foo", 1, sessions.Metadata{Version: "1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := sessionID(t, sm, "foo")
+
+	err = sm.Touch("foo", 1, id, sessions.Metadata{Version: "1.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 1 || result[0].Version != "1.3" || time.Since(time.Unix(result[0].LastSeen, 0)) > time.Minute {
+		t.Fatalf("unexpected sessions %v", result)
+	}
+}
+
+func TestSessionManager_TouchClosedSession(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, err := sm.NewSession("foo", 1, sessions.Metadata{Version: "1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := sessionID(t, sm, "foo")
+
+	err = sm.CloseSessionByID(1, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a request that was authenticated before the session was closed does not record it again.
+	err = sm.Touch("foo", 1, id, sessions.Metadata{Version: "1.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mr.Exists("user_sessions_1") {
+		t.Fatal("expected closed session not to be recorded")
+	}
+}
+
+func TestSessionManager_TouchKeepsRefreshedTokens(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	tokens, err := sm.NewSession("foo", 1, sessions.Metadata{Version: "1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := sessionID(t, sm, "foo")
+
+	refreshed, err := sm.Refresh(tokens.RefreshToken, sessions.Metadata{Version: "1.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a request with the previous access token finishes after the refresh.
+	err = sm.Touch("foo", 1, id, sessions.Metadata{Version: "1.3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = sm.Refresh(refreshed.RefreshToken, sessions.Metadata{Version: "1.3"})
+	if err != nil {
+		t.Fatalf("expected refresh to succeed actual %v", err)
+	}
+}
+
+func TestSessionManager_LabelSession(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, err := sm.NewSession("foo", 1, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := sessionID(t, sm, "foo")
+
+	err = sm.LabelSession(1, id, "work phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result[0].Label != "work phone" {
+		t.Fatalf("unexpected label %s", result[0].Label)
+	}
+
+	err = sm.LabelSession(2, id, "work phone")
+	if err != sessions.ErrSessionNotFound {
+		t.Fatalf("expected %v actual %v", sessions.ErrSessionNotFound, err)
+	}
+}
+
+func TestSessionManager_CloseSessions(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	for _, token := range []string{"foo", "bar", "baz"} {
+		_, err := sm.NewSession(token, 1, sessions.Metadata{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := sm.NewSession("other", 2, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sm.CloseSessionByID(1, sessionID(t, sm, "baz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm.GetUserIDForSession("baz"); err == nil {
+		t.Fatal("expected session to be closed")
+	}
+
+	// sessions of other users cannot be closed.
+	err = sm.CloseSessionByID(1, sessionID(t, sm, "other"))
+	if err != sessions.ErrSessionNotFound {
+		t.Fatalf("expected %v actual %v", sessions.ErrSessionNotFound, err)
+	}
+
+	err = sm.CloseOtherSessions(1, sessionID(t, sm, "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm.GetUserIDForSession("bar"); err == nil {
+		t.Fatal("expected session to be closed")
+	}
+
+	if id, _ := sm.GetUserIDForSession("foo"); id != 1 {
+		t.Fatal("expected current session to remain open")
+	}
+
+	err = sm.CloseAllSessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm.GetUserIDForSession("foo"); err == nil {
+		t.Fatal("expected session to be closed")
+	}
+
+	if id, _ := sm.GetUserIDForSession("other"); id != 2 {
+		t.Fatal("expected sessions of other users to remain open")
+	}
+}
+
+func TestSessionManager_IndexLegacySessions(t *testing.T) {
+	sm, mr := newSessionManager(t)
+	defer mr.Close()
+
+	_, err := sm.NewSession("foo", 1, sessions.Metadata{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = mr.Set("session_bar", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexed, err := sm.IndexLegacySessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if indexed != 1 {
+		t.Fatalf("expected 1 indexed session actual %d", indexed)
+	}
+
+	result, err := sm.Sessions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 {
+		t.Fatalf("expected 2 sessions actual %d", len(result))
+	}
+
+	err = sm.CloseOtherSessions(1, sessionID(t, sm, "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sm.GetUserIDForSession("bar"); err == nil {
+		t.Fatal("expected legacy session to be closed")
+	}
+
+	if _, err := sm.GetUserIDForSession("foo"); err != nil {
+		t.Fatal("expected current session to remain")
+	}
+}
@@ -0,0 +1,26 @@
+package sessions
+
+import (
+	"net/http"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+)
+
+// AppVersionHeader is sent by the apps with their version.
+const AppVersionHeader = "X-App-Version"
+
+// Metadata describes the client a session is used from.
+type Metadata struct {
+	Device  string
+	Version string
+	IP      string
+}
+
+// MetadataFromRequest returns the metadata of the client that sent a request.
+func MetadataFromRequest(r *http.Request) Metadata {
+	return Metadata{
+		Device:  r.UserAgent(),
+		Version: r.Header.Get(AppVersionHeader),
+		IP:      httputil.ClientIP(r),
+	}
+}
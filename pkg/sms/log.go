@@ -0,0 +1,26 @@
+package sms
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogSender writes messages to a log instead of sending them, it is used for development and tests.
+type LogSender struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogSender(w io.Writer) *LogSender {
+	return &LogSender{w: w}
+}
+
+func (s *LogSender) Send(to, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := fmt.Fprintf(s.w, "%s to=%s body=%q\n", time.Now().UTC().Format(time.RFC3339), to, body)
+	return err
+}
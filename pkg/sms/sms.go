@@ -0,0 +1,12 @@
+// Package sms sends text messages to phone numbers.
+package sms
+
+import "errors"
+
+// ErrTemporaryFailure is returned when a message was not accepted but sending it again later may succeed.
+var ErrTemporaryFailure = errors.New("temporary failure sending sms")
+
+// Sender delivers text messages, numbers are in E.164 format.
+type Sender interface {
+	Send(to, body string) error
+}
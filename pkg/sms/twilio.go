@@ -0,0 +1,73 @@
+package sms
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TwilioAPI is the base URL of the Twilio REST API.
+const TwilioAPI = "https://api.twilio.com/2010-04-01"
+
+// TwilioSender sends messages using the Twilio messages API.
+type TwilioSender struct {
+	client *http.Client
+
+	// BaseURL is the URL of the API, it can be changed to use a compatible provider.
+	BaseURL string
+
+	account string
+	token   string
+	from    string
+}
+
+func NewTwilioSender(client *http.Client, account, token, from string) *TwilioSender {
+	return &TwilioSender{
+		client:  client,
+		BaseURL: TwilioAPI,
+		account: account,
+		token:   token,
+		from:    from,
+	}
+}
+
+func (s *TwilioSender) Send(to, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", s.from)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", s.BaseURL, s.account)
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(s.account, s.token)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return ErrTemporaryFailure
+	}
+
+	apiErr := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+
+	_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+	return fmt.Errorf("failed to send sms: %d %d %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+}
@@ -0,0 +1,77 @@
+package sms_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/soapboxsocial/soapbox/pkg/sms"
+)
+
+func TestTwilioSender_Send(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/Accounts/AC123/Messages.json" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		account, token, ok := r.BasicAuth()
+		if !ok || account != "AC123" || token != "secret" {
+			t.Errorf("unexpected credentials %s %s", account, token)
+		}
+
+		err := r.ParseForm()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Form.Get("To") != "+14155552671" || r.Form.Get("From") != "+15005550006" || r.Form.Get("Body") != "code" {
+			t.Errorf("unexpected form %v", r.Form)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer server.Close()
+
+	sender := sms.NewTwilioSender(server.Client(), "AC123", "secret", "+15005550006")
+	sender.BaseURL = server.URL
+
+	err := sender.Send("+14155552671", "code")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTwilioSender_SendErrors(t *testing.T) {
+	tests := []struct {
+		status    int
+		temporary bool
+	}{
+		{status: http.StatusBadRequest, temporary: false},
+		{status: http.StatusTooManyRequests, temporary: true},
+		{status: http.StatusServiceUnavailable, temporary: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(`{"code": 21211, "message": "invalid number"}`))
+			}))
+
+			defer server.Close()
+
+			sender := sms.NewTwilioSender(server.Client(), "AC123", "secret", "+15005550006")
+			sender.BaseURL = server.URL
+
+			err := sender.Send("+14155552671", "code")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			if (err == sms.ErrTemporaryFailure) != tt.temporary {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
@@ -150,6 +150,22 @@ func (b *Backend) AddStory(story string, user int, expires, timestamp int64) err
 	return err
 }
 
+// GetStoryOwner returns the ID of the user that posted a story.
+func (b *Backend) GetStoryOwner(story string) (int, error) {
+	stmt, err := b.db.Prepare("SELECT user_id FROM stories WHERE id = $1;")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = stmt.QueryRow(story).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
 func (b *Backend) ReactToStory(story, reaction string, user int) error {
 	stmt, err := b.db.Prepare("INSERT INTO story_reactions (story_id, user_id, reaction) VALUES ($1, $2, $3);")
 	if err != nil {
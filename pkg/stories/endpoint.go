@@ -9,7 +9,9 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 )
 
@@ -25,11 +27,14 @@ func NewEndpoint(backend *Backend, files *FileBackend, queue *pubsub.Queue) *End
 
 func (e *Endpoint) Router() *mux.Router {
 	r := mux.NewRouter()
+	scopes := middlewares.NewScopes()
 
-	r.Path("/upload").Methods("POST").HandlerFunc(e.UploadStory)
-	r.Path("/{id:[0-9]+}").Methods("DELETE").HandlerFunc(e.DeleteStory)
+	scopes.Require(r.Path("/upload").Methods("POST").HandlerFunc(e.UploadStory), accesstokens.ScopeStoriesWrite)
+	scopes.Require(r.Path("/{id:[0-9]+}").Methods("DELETE").HandlerFunc(e.DeleteStory), accesstokens.ScopeStoriesWrite)
 	r.Path("/{id:[0-9]+}/react").Methods("POST").HandlerFunc(e.Reacted)
 
+	r.Use(scopes.Middleware)
+
 	return r
 }
 
@@ -72,13 +77,14 @@ func (e *Endpoint) UploadStory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = e.backend.AddStory(IDFromName(name), userID, expires, timestamp)
+	story := IDFromName(name)
+	err = e.backend.AddStory(story, userID, expires, timestamp)
 	if err != nil {
 		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "no story")
 		return
 	}
 
-	_ = e.queue.Publish(pubsub.StoryTopic, pubsub.NewStoryCreationEvent(userID))
+	_ = e.queue.Publish(pubsub.StoryTopic, pubsub.NewStoryCreationEvent(userID, story))
 
 	// @TODO CLEANUP
 	httputil.JsonSuccess(w)
@@ -138,7 +144,7 @@ func (e *Endpoint) Reacted(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = e.queue.Publish(pubsub.StoryTopic, pubsub.NewStoryReactionEvent(userID))
+	_ = e.queue.Publish(pubsub.StoryTopic, pubsub.NewStoryReactionEvent(userID, id, reaction))
 
 	httputil.JsonSuccess(w)
 }
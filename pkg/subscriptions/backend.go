@@ -0,0 +1,121 @@
+package subscriptions
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Level is how a user is alerted of rooms by a specific creator.
+type Level string
+
+const (
+	// LevelDefault alerts followers of rooms limited by their room frequency.
+	LevelDefault Level = "default"
+
+	// LevelLive alerts of every room the creator goes live in, bypassing the room frequency.
+	LevelLive Level = "live"
+
+	// LevelMuted never alerts of rooms, even when the user follows the creator.
+	LevelMuted Level = "muted"
+)
+
+// ErrInvalidLevel is returned when setting a level that does not exist.
+var ErrInvalidLevel = errors.New("invalid level")
+
+// ErrSelfSubscription is returned when a user subscribes to themselves.
+var ErrSelfSubscription = errors.New("cannot subscribe to self")
+
+// ErrBlocked is returned when subscribing to a creator that blocked the user, or was blocked by them.
+var ErrBlocked = errors.New("user is blocked")
+
+// IsValid returns whether a level exists.
+func IsValid(level Level) bool {
+	switch level {
+	case LevelDefault, LevelLive, LevelMuted:
+		return true
+	default:
+		return false
+	}
+}
+
+type Backend struct {
+	db *sql.DB
+}
+
+func NewBackend(db *sql.DB) *Backend {
+	return &Backend{db: db}
+}
+
+// GetLevel returns the level of the users subscription to a creator.
+func (b *Backend) GetLevel(user, creator int) (Level, error) {
+	stmt, err := b.db.Prepare("SELECT level FROM creator_subscriptions WHERE user_id = $1 AND creator = $2;")
+	if err != nil {
+		return "", err
+	}
+
+	var level Level
+	err = stmt.QueryRow(user, creator).Scan(&level)
+	if err == sql.ErrNoRows {
+		return LevelDefault, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return level, nil
+}
+
+// SetLevel sets the level of the users subscription to a creator, the default level removes the subscription.
+func (b *Backend) SetLevel(user, creator int, level Level) error {
+	if !IsValid(level) {
+		return ErrInvalidLevel
+	}
+
+	if user == creator {
+		return ErrSelfSubscription
+	}
+
+	if level == LevelDefault {
+		stmt, err := b.db.Prepare("DELETE FROM creator_subscriptions WHERE user_id = $1 AND creator = $2;")
+		if err != nil {
+			return err
+		}
+
+		_, err = stmt.Exec(user, creator)
+		return err
+	}
+
+	blocked, err := b.isBlocked(user, creator)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return ErrBlocked
+	}
+
+	stmt, err := b.db.Prepare("INSERT INTO creator_subscriptions (user_id, creator, level) VALUES ($1, $2, $3) ON CONFLICT (user_id, creator) DO UPDATE SET level = $3;")
+	if err != nil {
+		return err
+	}
+
+	_, err = stmt.Exec(user, creator, level)
+	return err
+}
+
+// isBlocked returns whether either user blocked the other.
+func (b *Backend) isBlocked(user, creator int) (bool, error) {
+	stmt, err := b.db.Prepare("SELECT EXISTS (SELECT 1 FROM blocks WHERE (user_id = $1 AND blocked = $2) OR (user_id = $2 AND blocked = $1));")
+	if err != nil {
+		return false, err
+	}
+
+	var blocked bool
+	err = stmt.QueryRow(user, creator).Scan(&blocked)
+	if err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
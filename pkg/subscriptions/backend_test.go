@@ -0,0 +1,132 @@
+package subscriptions_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/soapboxsocial/soapbox/pkg/subscriptions"
+)
+
+func TestBackend_GetLevel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := subscriptions.NewBackend(db)
+
+	mock.ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WithArgs(1, 2).
+		WillReturnRows(mock.NewRows([]string{"level"}).AddRow("live"))
+
+	level, err := backend.GetLevel(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if level != subscriptions.LevelLive {
+		t.Fatalf("expected %s actual %s", subscriptions.LevelLive, level)
+	}
+}
+
+func TestBackend_GetLevel_WithoutSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := subscriptions.NewBackend(db)
+
+	mock.ExpectPrepare("^SELECT (.+)").
+		ExpectQuery().
+		WithArgs(1, 2).
+		WillReturnRows(mock.NewRows([]string{"level"}))
+
+	level, err := backend.GetLevel(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if level != subscriptions.LevelDefault {
+		t.Fatalf("expected %s actual %s", subscriptions.LevelDefault, level)
+	}
+}
+
+func TestBackend_SetLevel(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := subscriptions.NewBackend(db)
+
+	mock.ExpectPrepare("^SELECT EXISTS (.+) blocks (.+)").
+		ExpectQuery().
+		WithArgs(1, 2).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(false))
+
+	mock.ExpectPrepare("^INSERT INTO creator_subscriptions (.+)").
+		ExpectExec().
+		WithArgs(1, 2, subscriptions.LevelMuted).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = backend.SetLevel(1, 2, subscriptions.LevelMuted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectPrepare("^DELETE FROM creator_subscriptions (.+)").
+		ExpectExec().
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = backend.SetLevel(1, 2, subscriptions.LevelDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackend_SetLevel_Blocked(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	backend := subscriptions.NewBackend(db)
+
+	mock.ExpectPrepare("^SELECT EXISTS (.+) blocks (.+)").
+		ExpectQuery().
+		WithArgs(1, 2).
+		WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(true))
+
+	err = backend.SetLevel(1, 2, subscriptions.LevelLive)
+	if err != subscriptions.ErrBlocked {
+		t.Fatalf("expected %v actual %v", subscriptions.ErrBlocked, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackend_SetLevel_Invalid(t *testing.T) {
+	backend := subscriptions.NewBackend(nil)
+
+	if backend.SetLevel(1, 2, "foo") != subscriptions.ErrInvalidLevel {
+		t.Fatal("expected invalid level")
+	}
+
+	if backend.SetLevel(1, 1, subscriptions.LevelLive) != subscriptions.ErrSelfSubscription {
+		t.Fatal("expected self subscription to fail")
+	}
+}
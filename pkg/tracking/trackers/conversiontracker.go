@@ -0,0 +1,56 @@
+package trackers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/analytics"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	"github.com/soapboxsocial/soapbox/pkg/redis"
+)
+
+// conversionWindow is how long after a re-engagement notification returning to the app counts as a conversion.
+const conversionWindow = 72 * time.Hour
+
+// ConversionTracker records re-engagement notifications after which the target became active again.
+type ConversionTracker struct {
+	backend *analytics.Backend
+	timeout *redis.TimeoutStore
+}
+
+func NewConversionTracker(backend *analytics.Backend, timeout *redis.TimeoutStore) *ConversionTracker {
+	return &ConversionTracker{
+		backend: backend,
+		timeout: timeout,
+	}
+}
+
+func (c *ConversionTracker) CanTrack(event *pubsub.Event) bool {
+	return event.Type == pubsub.EventTypeUserHeartbeat
+}
+
+func (c *ConversionTracker) Track(event *pubsub.Event) error {
+	id, err := event.GetInt("id")
+	if err != nil {
+		return err
+	}
+
+	timeoutkey := fmt.Sprintf("conversion_timeout_%d", id)
+	if c.timeout.IsOnTimeout(timeoutkey) {
+		return nil
+	}
+
+	err = c.backend.MarkConverted(id, string(notifications.REENGAGEMENT), conversionWindow)
+	if err != nil {
+		return err
+	}
+
+	err = c.timeout.SetTimeout(timeoutkey, 10*time.Minute)
+	if err != nil {
+		log.Printf("failed to set conversion timeout err: %s", err)
+	}
+
+	return nil
+}
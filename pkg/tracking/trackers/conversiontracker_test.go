@@ -0,0 +1,64 @@
+package trackers_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+
+	"github.com/soapboxsocial/soapbox/pkg/analytics"
+	"github.com/soapboxsocial/soapbox/pkg/pubsub"
+	redisutil "github.com/soapboxsocial/soapbox/pkg/redis"
+	"github.com/soapboxsocial/soapbox/pkg/tracking/trackers"
+)
+
+func TestConversionTracker_Track(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: mr.Addr(),
+	})
+
+	tracker := trackers.NewConversionTracker(analytics.NewBackend(db), redisutil.NewTimeoutStore(rdb))
+
+	id := 10
+	event, err := getRawEvent(pubsub.NewUserHeartbeatEvent(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !tracker.CanTrack(event) {
+		t.Fatal("expected heartbeat to be tracked")
+	}
+
+	mock.
+		ExpectPrepare("^UPDATE notification_analytics SET converted").
+		ExpectExec().
+		WithArgs(id, "REENGAGEMENT", float64(72*60*60)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = tracker.Track(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// heartbeats within the timeout are not tracked again.
+	err = tracker.Track(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -58,8 +58,9 @@ func NewBackend(db *sql.DB) *Backend {
 	}
 }
 
+// GetIDForUsername returns the ID of a user, users scheduled for deletion are not found.
 func (b *Backend) GetIDForUsername(username string) (int, error) {
-	stmt, err := b.db.Prepare("SELECT id FROM users WHERE username = $1;")
+	stmt, err := b.db.Prepare("SELECT id FROM users WHERE username = $1 AND id NOT IN (SELECT user_id FROM account_deletions);")
 	if err != nil {
 		return 0, err
 	}
@@ -74,7 +75,7 @@ func (b *Backend) GetIDForUsername(username string) (int, error) {
 }
 
 func (b *Backend) GetUserByUsername(username string) (*types.User, error) {
-	stmt, err := b.db.Prepare("SELECT id, display_name, image, bio FROM users WHERE username = $1;")
+	stmt, err := b.db.Prepare("SELECT id, display_name, image, bio FROM users WHERE username = $1 AND id NOT IN (SELECT user_id FROM account_deletions);")
 	if err != nil {
 		return nil, err
 	}
@@ -90,11 +91,12 @@ func (b *Backend) GetUserByUsername(username string) (*types.User, error) {
 	return user, nil
 }
 
+// GetUserForSearchEngine returns the search document of a user, users scheduled for deletion are not found.
 func (b *Backend) GetUserForSearchEngine(id int) (*SearchUser, error) {
 	query := `SELECT 
        id, display_name, username, image, bio,
        (SELECT COUNT(*) FROM followers WHERE user_id = id) AS followers, 
-       (SELECT CAST(FLOOR(SUM(EXTRACT(EPOCH FROM (left_time - join_time)))) as INT) FROM user_room_logs WHERE user_id = id AND join_time >= NOW() - INTERVAL '7 DAYS' AND visibility = 'public') FROM users WHERE id = $1;`
+       (SELECT CAST(FLOOR(SUM(EXTRACT(EPOCH FROM (left_time - join_time)))) as INT) FROM user_room_logs WHERE user_id = id AND join_time >= NOW() - INTERVAL '7 DAYS' AND visibility = 'public') FROM users WHERE id = $1 AND id NOT IN (SELECT user_id FROM account_deletions);`
 
 	stmt, err := b.db.Prepare(query)
 	if err != nil {
@@ -160,6 +162,7 @@ func (b *Backend) GetMyProfile(id int) (*Profile, error) {
 	return profile, nil
 }
 
+// ProfileByID returns the profile of a user as seen by another user, users scheduled for deletion are not found.
 func (b *Backend) ProfileByID(id, from int) (*Profile, error) {
 	query := `SELECT 
        id, display_name, username, image, bio,
@@ -167,7 +170,7 @@ func (b *Backend) ProfileByID(id, from int) (*Profile, error) {
        (SELECT COUNT(*) FROM followers WHERE follower = id) AS following,
        (SELECT COUNT(*) FROM followers WHERE follower = id AND user_id = $1) AS followed_by,
        (SELECT COUNT(*) FROM followers WHERE follower = $1 AND user_id = id) AS is_following,
-       (SELECT COUNT(*) FROM blocks WHERE user_id = $1 AND blocked = id) AS is_following FROM users WHERE id = $2;`
+       (SELECT COUNT(*) FROM blocks WHERE user_id = $1 AND blocked = id) AS is_following FROM users WHERE id = $2 AND id NOT IN (SELECT user_id FROM account_deletions);`
 
 	stmt, err := b.db.Prepare(query)
 	if err != nil {
@@ -232,8 +235,29 @@ func (b *Backend) NotificationUserFor(id int) (*NotificationUser, error) {
 	return profile, nil
 }
 
-func (b *Backend) IsAppleIDAccount(email string) (bool, error) {
-	stmt, err := b.db.Prepare("SELECT COUNT(*) FROM apple_authentication WHERE user_id = (SELECT id FROM users WHERE email = $1);")
+// RestoreAccount cancels the scheduled deletion of an account, it returns whether one was scheduled.
+func (b *Backend) RestoreAccount(id int) (bool, error) {
+	stmt, err := b.db.Prepare("DELETE FROM account_deletions WHERE user_id = $1;")
+	if err != nil {
+		return false, err
+	}
+
+	res, err := stmt.Exec(id)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// RequiresIdentity returns whether the account can only log in with a login provider, as its email was not verified.
+func (b *Backend) RequiresIdentity(email string) (bool, error) {
+	stmt, err := b.db.Prepare("SELECT COUNT(*) FROM user_identities WHERE user_id = (SELECT id FROM users WHERE email = $1) AND user_id NOT IN (SELECT user_id FROM email_logins);")
 	if err != nil {
 		return false, err
 	}
@@ -244,17 +268,18 @@ func (b *Backend) IsAppleIDAccount(email string) (bool, error) {
 		return false, err
 	}
 
-	return id == 1, nil
+	return id > 0, nil
 }
 
-func (b *Backend) FindByAppleID(id string) (*types.User, error) {
-	stmt, err := b.db.Prepare("SELECT id, display_name, username, image, bio, email FROM users INNER JOIN apple_authentication ON users.id = apple_authentication.user_id WHERE apple_authentication.apple_user = $1;")
+// FindByIdentity returns the user the identity of a login provider belongs to.
+func (b *Backend) FindByIdentity(provider, subject string) (*types.User, error) {
+	stmt, err := b.db.Prepare("SELECT id, display_name, username, image, bio, email FROM users INNER JOIN user_identities ON users.id = user_identities.user_id WHERE user_identities.provider = $1 AND user_identities.subject = $2;")
 	if err != nil {
 		return nil, err
 	}
 
 	user := &types.User{}
-	err = stmt.QueryRow(id).Scan(&user.ID, &user.DisplayName, &user.Username, &user.Image, &user.Bio, &user.Email)
+	err = stmt.QueryRow(provider, subject).Scan(&user.ID, &user.DisplayName, &user.Username, &user.Image, &user.Bio, &user.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -307,6 +332,54 @@ func (b *Backend) FindByEmail(email string) (*types.User, error) {
 	return user, nil
 }
 
+// FindByPhone returns the user that logs in with the phone number.
+func (b *Backend) FindByPhone(phone string) (*types.User, error) {
+	stmt, err := b.db.Prepare("SELECT id, display_name, username, image, bio, email, phone FROM users WHERE phone = $1;")
+	if err != nil {
+		return nil, err
+	}
+
+	user := &types.User{}
+	err = stmt.QueryRow(phone).Scan(&user.ID, &user.DisplayName, &user.Username, &user.Image, &user.Bio, &user.Email, &user.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// IsPhoneRegistered returns whether a user logs in with the phone number.
+func (b *Backend) IsPhoneRegistered(phone string) (bool, error) {
+	stmt, err := b.db.Prepare("SELECT COUNT(*) FROM users WHERE phone = $1;")
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	err = stmt.QueryRow(phone).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count == 1, nil
+}
+
+// CreateUserWithPhone creates a user that logs in with a phone number, the user has no email.
+func (b *Backend) CreateUserWithPhone(phone, displayName, bio, image, username string) (int, error) {
+	stmt, err := b.db.Prepare("INSERT INTO users (display_name, username, phone, bio, image) VALUES ($1, $2, $3, $4, $5) RETURNING id;")
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = stmt.QueryRow(displayName, strings.ToLower(username), phone, bio, image).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
 func (b *Backend) CreateUser(email, displayName, bio, image, username string) (int, error) {
 	stmt, err := b.db.Prepare("INSERT INTO users (display_name, username, email, bio, image) VALUES ($1, $2, $3, $4, $5) RETURNING id;")
 	if err != nil {
@@ -322,7 +395,8 @@ func (b *Backend) CreateUser(email, displayName, bio, image, username string) (i
 	return id, nil
 }
 
-func (b *Backend) CreateUserWithAppleLogin(email, displayName, bio, image, username, appleID string) (int, error) {
+// CreateUserWithIdentity creates a user that logs in with the identity of a login provider.
+func (b *Backend) CreateUserWithIdentity(email, displayName, bio, image, username, provider, subject string) (int, error) {
 	ctx := context.Background()
 	tx, err := b.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -343,8 +417,8 @@ func (b *Backend) CreateUserWithAppleLogin(email, displayName, bio, image, usern
 
 	_, err = tx.ExecContext(
 		ctx,
-		"INSERT INTO apple_authentication (user_id, apple_user) VALUES ($1, $2);",
-		id, appleID,
+		"INSERT INTO user_identities (user_id, provider, subject) VALUES ($1, $2, $3);",
+		id, provider, subject,
 	)
 
 	if err != nil {
@@ -11,12 +11,15 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/soapboxsocial/soapbox/pkg/accesstokens"
 	"github.com/soapboxsocial/soapbox/pkg/followers"
 	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/http/middlewares"
 	"github.com/soapboxsocial/soapbox/pkg/images"
 	"github.com/soapboxsocial/soapbox/pkg/pubsub"
 	"github.com/soapboxsocial/soapbox/pkg/sessions"
 	"github.com/soapboxsocial/soapbox/pkg/stories"
+	"github.com/soapboxsocial/soapbox/pkg/subscriptions"
 )
 
 type Endpoint struct {
@@ -25,6 +28,7 @@ type Endpoint struct {
 	sm      *sessions.SessionManager
 	ib      *images.Backend
 	stories *stories.Backend
+	subs    *subscriptions.Backend
 
 	queue *pubsub.Queue
 }
@@ -36,6 +40,7 @@ func NewEndpoint(
 	ib *images.Backend,
 	queue *pubsub.Queue,
 	stories *stories.Backend,
+	subs *subscriptions.Backend,
 ) *Endpoint {
 	return &Endpoint{
 		ub:      ub,
@@ -44,22 +49,29 @@ func NewEndpoint(
 		ib:      ib,
 		queue:   queue,
 		stories: stories,
+		subs:    subs,
 	}
 }
 
 func (e *Endpoint) Router() *mux.Router {
 	r := mux.NewRouter()
+	scopes := middlewares.NewScopes()
 
-	r.Path("/{id:[0-9]+}").Methods("GET").HandlerFunc(e.GetUserByID)
-	r.Path("/{username:[a-z0-9_]+}").Methods("GET").HandlerFunc(e.GetUserByUsername)
-	r.Path("/{id:[0-9]+}/followers").Methods("GET").HandlerFunc(e.GetFollowersForUser)
-	r.Path("/{id:[0-9]+}/following").Methods("GET").HandlerFunc(e.GetFollowedByForUser)
+	scopes.Require(r.Path("/{id:[0-9]+}").Methods("GET").HandlerFunc(e.GetUserByID), accesstokens.ScopeProfileRead)
+	scopes.Require(r.Path("/{username:[a-z0-9_]+}").Methods("GET").HandlerFunc(e.GetUserByUsername), accesstokens.ScopeProfileRead)
+	scopes.Require(r.Path("/{id:[0-9]+}/followers").Methods("GET").HandlerFunc(e.GetFollowersForUser), accesstokens.ScopeProfileRead)
+	scopes.Require(r.Path("/{id:[0-9]+}/following").Methods("GET").HandlerFunc(e.GetFollowedByForUser), accesstokens.ScopeProfileRead)
 	r.Path("/{id:[0-9]+}/friends").Methods("GET").HandlerFunc(e.GetFriends)
-	r.Path("/follow").Methods("POST").HandlerFunc(e.FollowUser)
-	r.Path("/unfollow").Methods("POST").HandlerFunc(e.UnfollowUser)
-	r.Path("/multi-follow").Methods("POST").HandlerFunc(e.MultiFollowUsers)
+	scopes.Require(r.Path("/follow").Methods("POST").HandlerFunc(e.FollowUser), accesstokens.ScopeFollow)
+	scopes.Require(r.Path("/unfollow").Methods("POST").HandlerFunc(e.UnfollowUser), accesstokens.ScopeFollow)
+	scopes.Require(r.Path("/multi-follow").Methods("POST").HandlerFunc(e.MultiFollowUsers), accesstokens.ScopeFollow)
 	r.Path("/edit").Methods("POST").HandlerFunc(e.EditUser)
 	r.Path("/{id:[0-9]+}/stories").Methods("GET").HandlerFunc(e.GetStoriesForUser)
+	r.Path("/{id:[0-9]+}/subscription").Methods("GET").HandlerFunc(e.GetSubscription)
+	r.Path("/{id:[0-9]+}/subscription").Methods("POST").HandlerFunc(e.UpdateSubscription)
+	r.Path("/{id:[0-9]+}/subscription").Methods("DELETE").HandlerFunc(e.DeleteSubscription)
+
+	r.Use(scopes.Middleware)
 
 	return r
 }
@@ -294,6 +306,80 @@ func (e *Endpoint) UnfollowUser(w http.ResponseWriter, r *http.Request) {
 	httputil.JsonSuccess(w)
 }
 
+// GetSubscription returns the level at which the caller is alerted of rooms by the user.
+func (e *Endpoint) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	userID, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	level, err := e.subs.GetLevel(userID, id)
+	if err != nil {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to get subscription")
+		return
+	}
+
+	err = httputil.JsonEncode(w, map[string]subscriptions.Level{"level": level})
+	if err != nil {
+		log.Printf("failed to write subscription response: %s\n", err.Error())
+	}
+}
+
+// UpdateSubscription sets whether the caller is alerted of every room by the user or has muted them.
+func (e *Endpoint) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "")
+		return
+	}
+
+	e.setSubscription(w, r, subscriptions.Level(r.Form.Get("level")))
+}
+
+// DeleteSubscription resets the caller to the default room alerts for the user.
+func (e *Endpoint) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	e.setSubscription(w, r, subscriptions.LevelDefault)
+}
+
+func (e *Endpoint) setSubscription(w http.ResponseWriter, r *http.Request, level subscriptions.Level) {
+	params := mux.Vars(r)
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	userID, ok := httputil.GetUserIDFromContext(r.Context())
+	if !ok {
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "invalid id")
+		return
+	}
+
+	err = e.subs.SetLevel(userID, id, level)
+	switch err {
+	case nil:
+		httputil.JsonSuccess(w)
+	case subscriptions.ErrInvalidLevel:
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "invalid level")
+	case subscriptions.ErrSelfSubscription:
+		httputil.JsonError(w, http.StatusBadRequest, httputil.ErrorCodeInvalidRequestBody, "cannot subscribe to self")
+	case subscriptions.ErrBlocked:
+		httputil.JsonError(w, http.StatusForbidden, httputil.ErrorCodeNotAllowed, "user is blocked")
+	default:
+		httputil.JsonError(w, http.StatusInternalServerError, httputil.ErrorCodeInvalidRequestBody, "failed to update subscription")
+	}
+}
+
 func (e *Endpoint) EditUser(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseMultipartForm(10 << 20)
 	if err != nil {
@@ -0,0 +1,168 @@
+package users_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	httputil "github.com/soapboxsocial/soapbox/pkg/http"
+	"github.com/soapboxsocial/soapbox/pkg/subscriptions"
+	"github.com/soapboxsocial/soapbox/pkg/users"
+)
+
+func TestEndpoint_GetSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	endpoint := users.NewEndpoint(nil, nil, nil, nil, nil, nil, subscriptions.NewBackend(db))
+
+	r, err := http.NewRequest("GET", "/2/subscription", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+
+	mock.ExpectPrepare("^SELECT level FROM creator_subscriptions (.+)").ExpectQuery().
+		WithArgs(1, 2).
+		WillReturnRows(mock.NewRows([]string{"level"}).AddRow("live"))
+
+	rr := httptest.NewRecorder()
+	endpoint.Router().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var resp map[string]subscriptions.Level
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp["level"] != subscriptions.LevelLive {
+		t.Fatalf("expected %s actual %s", subscriptions.LevelLive, resp["level"])
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEndpoint_UpdateSubscription(t *testing.T) {
+	var tests = []struct {
+		level   string
+		blocked bool
+		status  int
+	}{
+		{level: "live", status: http.StatusOK},
+		{level: "muted", status: http.StatusOK},
+		{level: "live", blocked: true, status: http.StatusForbidden},
+		{level: "foo", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+			}
+			defer db.Close()
+
+			endpoint := users.NewEndpoint(nil, nil, nil, nil, nil, nil, subscriptions.NewBackend(db))
+
+			r, err := http.NewRequest("POST", "/2/subscription", strings.NewReader("level="+tt.level))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if subscriptions.IsValid(subscriptions.Level(tt.level)) {
+				mock.ExpectPrepare("^SELECT EXISTS (.+) blocks (.+)").ExpectQuery().
+					WithArgs(1, 2).
+					WillReturnRows(mock.NewRows([]string{"exists"}).AddRow(tt.blocked))
+			}
+
+			if tt.status == http.StatusOK {
+				mock.ExpectPrepare("^INSERT INTO creator_subscriptions (.+)").ExpectExec().
+					WithArgs(1, 2, tt.level).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			}
+
+			rr := httptest.NewRecorder()
+			endpoint.Router().ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Fatalf("handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+
+			err = mock.ExpectationsWereMet()
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestEndpoint_UpdateSubscriptionToSelf(t *testing.T) {
+	endpoint := users.NewEndpoint(nil, nil, nil, nil, nil, nil, subscriptions.NewBackend(nil))
+
+	r, err := http.NewRequest("POST", "/1/subscription", strings.NewReader("level=live"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	rr := httptest.NewRecorder()
+	endpoint.Router().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusBadRequest)
+	}
+}
+
+func TestEndpoint_DeleteSubscription(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	endpoint := users.NewEndpoint(nil, nil, nil, nil, nil, nil, subscriptions.NewBackend(db))
+
+	r, err := http.NewRequest("DELETE", "/2/subscription", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := r.WithContext(httputil.WithUserID(r.Context(), 1))
+
+	// resetting a subscription is allowed even when the creator is blocked.
+	mock.ExpectPrepare("^DELETE FROM creator_subscriptions (.+)").ExpectExec().
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	rr := httptest.NewRecorder()
+	endpoint.Router().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	err = mock.ExpectationsWereMet()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -7,4 +7,5 @@ type User struct {
 	Image       string  `json:"image"`
 	Bio         string  `json:"bio"`
 	Email       *string `json:"email,omitempty"`
+	Phone       *string `json:"phone,omitempty"`
 }
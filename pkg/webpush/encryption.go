@@ -0,0 +1,106 @@
+package webpush
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// recordSize is the aes128gcm record size, we only ever send a single record.
+const recordSize = 4096
+
+var (
+	errInvalidPublicKey = errors.New("invalid user agent public key")
+	errPayloadTooLarge  = errors.New("payload too large")
+)
+
+// encrypt encrypts a payload for a user agent using the aes128gcm content encoding, see RFC 8188 and RFC 8291.
+func encrypt(payload, uaPublic, authSecret []byte) ([]byte, error) {
+	// the record needs space for the padding delimiter and the 16 byte tag.
+	if len(payload)+17 > recordSize {
+		return nil, errPayloadTooLarge
+	}
+
+	curve := elliptic.P256()
+
+	x, y := elliptic.Unmarshal(curve, uaPublic)
+	if x == nil {
+		return nil, errInvalidPublicKey
+	}
+
+	private, px, py, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	asPublic := elliptic.Marshal(curve, px, py)
+
+	sx, _ := curve.ScalarMult(x, y, private)
+	secret := pad(sx.Bytes(), 32)
+
+	salt := make([]byte, 16)
+	_, err = rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+
+	ikm, err := expand(secret, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, err := expand(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := expand(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 0x02 marks the last and only record.
+	plaintext := append(append([]byte{}, payload...), 0x02)
+
+	rs := make([]byte, 4)
+	binary.BigEndian.PutUint32(rs, recordSize)
+
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	header = append(header, rs...)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// expand derives a key of length size using HKDF-SHA256.
+func expand(secret, salt, info []byte, size int) ([]byte, error) {
+	key := make([]byte, size)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
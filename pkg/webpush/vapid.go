@@ -0,0 +1,108 @@
+// Package webpush implements notification delivery to browsers using the Web Push protocol.
+package webpush
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var errInvalidPrivateKey = errors.New("invalid vapid private key")
+
+// VAPIDKeys is the application server key pair used to identify ourselves to push services, see RFC 8292.
+type VAPIDKeys struct {
+	private *ecdsa.PrivateKey
+}
+
+// GenerateVAPIDKeys creates a new VAPID key pair.
+func GenerateVAPIDKeys() (*VAPIDKeys, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &VAPIDKeys{private: private}, nil
+}
+
+// NewVAPIDKeys loads a VAPID key pair from a base64url encoded private key.
+func NewVAPIDKeys(private string) (*VAPIDKeys, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(private, "="))
+	if err != nil || len(raw) != 32 {
+		return nil, errInvalidPrivateKey
+	}
+
+	curve := elliptic.P256()
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve},
+		D:         new(big.Int).SetBytes(raw),
+	}
+
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(raw)
+
+	return &VAPIDKeys{private: key}, nil
+}
+
+// PublicKey returns the base64url encoded public key, this is the `applicationServerKey` browsers subscribe with.
+func (k *VAPIDKeys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(
+		elliptic.Marshal(k.private.Curve, k.private.PublicKey.X, k.private.PublicKey.Y),
+	)
+}
+
+// PrivateKey returns the base64url encoded private key.
+func (k *VAPIDKeys) PrivateKey() string {
+	return base64.RawURLEncoding.EncodeToString(pad(k.private.D.Bytes(), 32))
+}
+
+// authorization returns the VAPID `Authorization` header value for a push service endpoint.
+func (k *VAPIDKeys) authorization(endpoint, subject string, expiration time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"aud": fmt.Sprintf("%s://%s", u.Scheme, u.Host),
+		"exp": expiration.Unix(),
+		"sub": subject,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	signature := append(pad(r.Bytes(), 32), pad(s.Bytes(), 32)...)
+	token := unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKey()), nil
+}
+
+func pad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	return append(make([]byte, size-len(b)), b...)
+}
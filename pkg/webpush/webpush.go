@@ -0,0 +1,90 @@
+package webpush
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/soapboxsocial/soapbox/pkg/devices"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+)
+
+// ttl is how long push services should hold on to a notification for an offline browser.
+const ttl = 24 * time.Hour
+
+// topicRegex matches valid `Topic` header values, used to collapse pending notifications.
+var topicRegex = regexp.MustCompile("^[A-Za-z0-9_-]{1,32}$")
+
+// Client sends notifications to Web Push subscriptions.
+type Client struct {
+	client  *http.Client
+	keys    *VAPIDKeys
+	subject string
+}
+
+// NewClient creates a new web push client, the subject is a `mailto:` or `https:` contact URI for the push services.
+func NewClient(client *http.Client, keys *VAPIDKeys, subject string) *Client {
+	return &Client{
+		client:  client,
+		keys:    keys,
+		subject: subject,
+	}
+}
+
+func (c *Client) Send(subscription devices.WebPushSubscription, notification notifications.PushNotification) error {
+	p256dh, auth, err := subscription.Keys()
+	if err != nil {
+		return notifications.ErrSubscriptionExpired
+	}
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	body, err := encrypt(data, p256dh, auth)
+	if err != nil {
+		return err
+	}
+
+	authorization, err := c.keys.authorization(subscription.Endpoint, c.subject, time.Now().Add(12*time.Hour))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", subscription.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(ttl.Seconds())))
+
+	if topicRegex.MatchString(notification.CollapseID) {
+		req.Header.Set("Topic", notification.CollapseID)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	_ = resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return notifications.ErrSubscriptionExpired
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+		return notifications.ErrRetryRequired
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("failed to send code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package webpush_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/soapboxsocial/soapbox/pkg/devices"
+	"github.com/soapboxsocial/soapbox/pkg/notifications"
+	"github.com/soapboxsocial/soapbox/pkg/webpush"
+)
+
+func TestClient_Send(t *testing.T) {
+	curve := elliptic.P256()
+
+	private, x, y, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	public := elliptic.Marshal(curve, x, y)
+
+	secret := make([]byte, 16)
+	_, err = rand.Read(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notification := notifications.PushNotification{
+		Category:   notifications.NEW_FOLLOWER,
+		Alert:      notifications.Alert{Key: "new_follower_notification", Arguments: []string{"foo"}},
+		Arguments:  map[string]interface{}{"id": float64(12)},
+		CollapseID: "abc",
+	}
+
+	var received notifications.PushNotification
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "aes128gcm" {
+			t.Errorf("unexpected encoding %s", r.Header.Get("Content-Encoding"))
+		}
+
+		if r.Header.Get("Topic") != notification.CollapseID {
+			t.Errorf("unexpected topic %s", r.Header.Get("Topic"))
+		}
+
+		verifyAuthorization(t, r.Header.Get("Authorization"), keys.PublicKey())
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		plaintext := decrypt(t, body, private, public, secret)
+		err = json.Unmarshal(plaintext, &received)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client := webpush.NewClient(server.Client(), keys, "mailto:test@example.com")
+
+	err = client.Send(
+		devices.WebPushSubscription{
+			Endpoint: server.URL + "/push/123",
+			P256dh:   base64.RawURLEncoding.EncodeToString(public),
+			Auth:     base64.RawURLEncoding.EncodeToString(secret),
+		},
+		notification,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the collapse ID is sent as the topic header and not part of the payload.
+	expected := notification
+	expected.CollapseID = ""
+
+	if !reflect.DeepEqual(received, expected) {
+		t.Fatalf("expected %v actual %v", expected, received)
+	}
+}
+
+func TestClient_SendWithErrorResponses(t *testing.T) {
+	var tests = []struct {
+		status int
+		err    error
+	}{
+		{http.StatusGone, notifications.ErrSubscriptionExpired},
+		{http.StatusNotFound, notifications.ErrSubscriptionExpired},
+		{http.StatusTooManyRequests, notifications.ErrRetryRequired},
+		{http.StatusBadGateway, notifications.ErrRetryRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			keys, err := webpush.GenerateVAPIDKeys()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			curve := elliptic.P256()
+			_, x, y, err := elliptic.GenerateKey(curve, rand.Reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			client := webpush.NewClient(server.Client(), keys, "mailto:test@example.com")
+
+			err = client.Send(
+				devices.WebPushSubscription{
+					Endpoint: server.URL,
+					P256dh:   base64.RawURLEncoding.EncodeToString(elliptic.Marshal(curve, x, y)),
+					Auth:     base64.RawURLEncoding.EncodeToString(make([]byte, 16)),
+				},
+				notifications.PushNotification{Category: notifications.INFO},
+			)
+
+			if err != tt.err {
+				t.Fatalf("expected %v actual %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNewVAPIDKeys(t *testing.T) {
+	keys, err := webpush.GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := webpush.NewVAPIDKeys(keys.PrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.PublicKey() != keys.PublicKey() {
+		t.Fatalf("expected %s actual %s", keys.PublicKey(), loaded.PublicKey())
+	}
+
+	_, err = webpush.NewVAPIDKeys("foo")
+	if err == nil {
+		t.Fatal("expected error for invalid key")
+	}
+}
+
+func verifyAuthorization(t *testing.T, header, key string) {
+	t.Helper()
+
+	if !strings.HasPrefix(header, "vapid t=") || !strings.HasSuffix(header, ", k="+key) {
+		t.Fatalf("unexpected authorization header %s", header)
+	}
+
+	token := strings.TrimSuffix(strings.TrimPrefix(header, "vapid t="), ", k="+key)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("invalid jwt %s", token)
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x, y := elliptic.Unmarshal(elliptic.P256(), raw)
+	public := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		t.Fatalf("invalid signature %s", parts[2])
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+
+	if !ecdsa.Verify(public, hash[:], r, s) {
+		t.Fatal("failed to verify vapid signature")
+	}
+}
+
+// decrypt implements the user agent side of RFC 8291.
+func decrypt(t *testing.T, body, private, public, secret []byte) []byte {
+	t.Helper()
+
+	salt := body[:16]
+	rs := binary.BigEndian.Uint32(body[16:20])
+	length := int(body[20])
+	asPublic := body[21 : 21+length]
+	ciphertext := body[21+length:]
+
+	if int(rs) < len(ciphertext) {
+		t.Fatalf("record size %d smaller than ciphertext %d", rs, len(ciphertext))
+	}
+
+	curve := elliptic.P256()
+	x, y := elliptic.Unmarshal(curve, asPublic)
+	sx, _ := curve.ScalarMult(x, y, private)
+
+	shared := make([]byte, 32)
+	sx.FillBytes(shared)
+
+	info := append([]byte("WebPush: info\x00"), public...)
+	info = append(info, asPublic...)
+
+	ikm := derive(t, shared, secret, info, 32)
+	cek := derive(t, ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := derive(t, ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plaintext[len(plaintext)-1] != 0x02 {
+		t.Fatal("missing last record delimiter")
+	}
+
+	return plaintext[:len(plaintext)-1]
+}
+
+func derive(t *testing.T, secret, salt, info []byte, size int) []byte {
+	t.Helper()
+
+	key := make([]byte, size)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
@@ -26,6 +26,7 @@ sudo ln -s /vagrant/conf/supervisord/notifications.conf /etc/supervisor/conf.d/n
 sudo ln -s /vagrant/conf/supervisord/indexer.conf /etc/supervisor/conf.d/indexer.conf
 sudo ln -s /vagrant/conf/supervisord/rooms.conf /etc/supervisor/conf.d/rooms.conf
 sudo ln -s /vagrant/conf/supervisord/metadata.conf /etc/supervisor/conf.d/metadata.conf
+sudo ln -s /vagrant/conf/supervisord/exports.conf /etc/supervisor/conf.d/exports.conf
 
 echo 'export GOPATH="/home/vagrant/go"' >> /home/vagrant/.bashrc
 echo 'export PATH="$PATH:${GOPATH//://bin:}/bin"' >> /home/vagrant/.bashrc
@@ -83,6 +84,10 @@ sudo mkdir -p /cdn/stories/
 sudo chown nginx:nginx -R /cdn/stories
 sudo chmod -R 0777 /cdn/stories
 
+sudo mkdir -p /cdn/exports/
+sudo chown nginx:nginx -R /cdn/exports
+sudo chmod -R 0777 /cdn/exports
+
 echo "building soapbox... (manually (ssh this))"
 cd $GOPATH/src/github.com/soapboxsocial/soapbox && sudo go build -o /usr/local/bin/soapbox main.go
 echo "building indexer..."
@@ -91,6 +96,10 @@ echo "building rooms..."
 cd $GOPATH/src/github.com/soapboxsocial/soapbox/cmd/rooms && sudo go build -o /usr/local/bin/rooms main.go
  echo "building stories..."
 cd $GOPATH/src/github.com/soapboxsocial/soapbox/cmd/stories && sudo go build -o /usr/local/bin/stories main.go
+echo "building accounts..."
+cd $GOPATH/src/github.com/soapboxsocial/soapbox/cmd/accounts && sudo go build -o /usr/local/bin/accounts main.go
+echo "building exports..."
+cd $GOPATH/src/github.com/soapboxsocial/soapbox/cmd/exports && sudo go build -o /usr/local/bin/exports main.go
 
 echo "done building!"
 crontab /vagrant/conf/crontab